@@ -6,6 +6,7 @@ import (
 
 	"github.com/antony-jr/ham/internal/banner"
 	"github.com/antony-jr/ham/internal/cli/build_cli"
+	"github.com/antony-jr/ham/internal/cmd/build"
 )
 
 /*
@@ -17,6 +18,7 @@ var GitCommit = "Unknown"
 
 func main() {
 	banner.Header(AppVersion, GitCommit)
+	build.AppVersion, build.GitCommit = AppVersion, GitCommit
 	if err := build_cli.Run(); err != nil {
 		banner.Error(fmt.Sprint(err))
 		os.Exit(1)
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/antony-jr/ham/internal/banner"
 	"github.com/antony-jr/ham/internal/cli"
+	"github.com/antony-jr/ham/internal/helpers"
 )
 
 /*
@@ -18,6 +20,18 @@ var GitCommit = "Unknown"
 func main() {
 	banner.Header(AppVersion, GitCommit)
 	if err := cli.Run(); err != nil {
+		var exitErr *helpers.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.Fatal {
+				banner.Error(exitErr.Message)
+			} else if helpers.CIMode() == helpers.CI_JSON {
+				helpers.CIEvent("exit", exitErr.Message, map[string]interface{}{"code": exitErr.Code})
+			} else {
+				fmt.Println(exitErr.Message)
+			}
+			os.Exit(exitErr.Code)
+		}
+
 		banner.Error(fmt.Sprint(err))
 		os.Exit(1)
 	}
@@ -2,31 +2,42 @@ package banner
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/antony-jr/ham/internal/helpers"
+	"github.com/charmbracelet/glamour"
 	"github.com/fatih/color"
 	"github.com/kyokomi/emoji/v2"
 )
 
 func Header(Version string, Commit string) {
+	// Keep the output clean for scripts which read it, the
+	// header is printed before the flags are parsed.
+	var w io.Writer = os.Stdout
+	if !helpers.IsTerminal(os.Stdout) || helpers.HasCIFlag(os.Args[1:]) {
+		w = os.Stderr
+	}
+
 	cliName := color.New(color.FgRed).Add(color.Bold)
-	cliName.Print("Ham ", emoji.Sprint(":hamster:"))
+	cliName.Fprint(w, "Ham ", emoji.Sprint(":hamster:"))
 
-	fmt.Print("(v")
+	fmt.Fprint(w, "(v")
 	ver := color.New(color.Bold)
-	ver.Print(Version)
-	fmt.Print(" commit-")
+	ver.Fprint(w, Version)
+	fmt.Fprint(w, " commit-")
 	commit := color.New(color.FgYellow).Add(color.Bold)
-	commit.Print(Commit)
-	fmt.Print("),")
+	commit.Fprint(w, Commit)
+	fmt.Fprint(w, "),")
 
 	tagLine := color.New(color.FgGreen).Add(color.Bold)
-	tagLine.Println(" Hetzner Android Make.")
+	tagLine.Fprintln(w, " Hetzner Android Make.")
 
 	copyright := color.New(color.FgCyan).Add(color.Bold)
-	copyright.Println("Copyright (c) 2022, D. Antony J.R <antonyjr@pm.me>.")
-	copyright.Println("The BSD 3-Clause \"New\" or \"Revised\" License.")
-	fmt.Print("\n")
+	copyright.Fprintln(w, "Copyright (c) 2022, D. Antony J.R <antonyjr@pm.me>.")
+	copyright.Fprintln(w, "The BSD 3-Clause \"New\" or \"Revised\" License.")
+	fmt.Fprint(w, "\n")
 }
 
 func Usage() {
@@ -37,7 +48,27 @@ func Usage() {
 	fmt.Println("Run help command to get more information")
 }
 
+// Renders the markdown of a banner with glamour, in CI mode the
+// markdown is printed as is or as a event.
+func render(in string, style string) {
+	switch helpers.CIMode() {
+	case helpers.CI_JSON:
+		helpers.CIEvent("banner", strings.TrimSpace(in), nil)
+	case helpers.CI_PLAIN:
+		fmt.Println(strings.TrimSpace(in))
+		fmt.Println()
+	default:
+		out, _ := glamour.Render(in, style)
+		fmt.Print(out)
+	}
+}
+
 func Error(Message string) {
+	if helpers.CIMode() == helpers.CI_JSON {
+		helpers.CIEvent("error", Message, nil)
+		return
+	}
+
 	c := color.New(color.FgRed).Add(color.Bold)
 	c.Print("Fatal: ", Message)
 
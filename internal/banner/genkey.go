@@ -3,7 +3,6 @@ package banner
 import (
 	"fmt"
 
-	"github.com/charmbracelet/glamour"
 	"github.com/fatih/color"
 	"github.com/kyokomi/emoji/v2"
 )
@@ -21,8 +20,7 @@ func GenKeyStartBanner(c, s, l, o, ou, cn, e string, ks int) {
 
 	in = fmt.Sprintf(in, c, s, l, o, ou, cn, e, ks)
 
-	out, _ := glamour.Render(in, "dark")
-	fmt.Print(out)
+	render(in, "dark")
 }
 
 func GenKeyFinishBanner() {
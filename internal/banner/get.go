@@ -3,7 +3,6 @@ package banner
 import (
 	"fmt"
 
-	"github.com/charmbracelet/glamour"
 	"github.com/fatih/color"
 	"github.com/kyokomi/emoji/v2"
 )
@@ -11,8 +10,7 @@ import (
 func GetStartBanner() {
 	in := `# Get Build`
 
-	out, _ := glamour.Render(in, "dark")
-	fmt.Print(out)
+	render(in, "dark")
 }
 
 func GetBuildFailedBanner(serverName string) {
@@ -27,8 +25,7 @@ func GetBuildFailedBanner(serverName string) {
 
 	in = fmt.Sprintf(in, serverName)
 
-	out, _ := glamour.Render(in, "auto")
-	fmt.Print(out)
+	render(in, "auto")
 }
 
 func GetConnectFailBanner(serverName string) {
@@ -43,8 +40,7 @@ func GetConnectFailBanner(serverName string) {
 
 	in = fmt.Sprintf(in, serverName)
 
-	out, _ := glamour.Render(in, "auto")
-	fmt.Print(out)
+	render(in, "auto")
 }
 
 func GetMalformedJSONBanner(serverName string) {
@@ -59,8 +55,19 @@ func GetMalformedJSONBanner(serverName string) {
 
 	in = fmt.Sprintf(in, serverName)
 
-	out, _ := glamour.Render(in, "auto")
-	fmt.Print(out)
+	render(in, "auto")
+}
+
+func GetRecipeMismatchBanner(serverName string, details string) {
+	in := "# Recipe Changed\n"
+	in += "A build server **%s** is already running for this recipe, but it was **not started from the same recipe**"
+	in += " you have now.\n\n"
+	in += "```\n%s\n```\n"
+	in += "Attaching to it would track a build of the old recipe.\n\n"
+
+	in = fmt.Sprintf(in, serverName, details)
+
+	render(in, "auto")
 }
 
 func GetRecipeBanner(name string, ver string, hash string) {
@@ -69,14 +76,12 @@ func GetRecipeBanner(name string, ver string, hash string) {
 	in += "**SHA-256**: %s"
 	in = fmt.Sprintf(in, name, ver, hash)
 
-	out, _ := glamour.Render(in, "auto")
-	fmt.Print(out)
+	render(in, "auto")
 }
 
 func GetCmdProgressBanner() {
 	in := "# Progress\n"
-	out, _ := glamour.Render(in, "auto")
-	fmt.Print(out)
+	render(in, "auto")
 }
 
 func GetServerPriceInformationBanner(name string, price float64) {
@@ -92,14 +97,20 @@ func GetServerPriceInformationBanner(name string, price float64) {
 	in += " after each ```ham get``` run and you are responsible to check for any active servers running."
 	in = fmt.Sprintf(in, name, price, price*8.0, price*24.0)
 
-	out, _ := glamour.Render(in, "auto")
-	fmt.Print(out)
+	render(in, "auto")
+}
+
+func GetServerCountBanner(count int, price float64) {
+	in := "**%d** servers will be created, one for each build, so the price is **%f** euros/hour"
+	in += " for all of them together.\n"
+	in = fmt.Sprintf(in, count, price*float64(count))
+
+	render(in, "auto")
 }
 
 func GetQuestionBanner() {
 	in := "# Quesions\n"
-	out, _ := glamour.Render(in, "auto")
-	fmt.Print(out)
+	render(in, "auto")
 }
 
 func GetRecipeNotExistsBanner() {
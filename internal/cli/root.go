@@ -4,10 +4,13 @@ import (
 	"github.com/mkideal/cli"
 	"os"
 
+	"github.com/antony-jr/ham/internal/cmd/answers"
 	"github.com/antony-jr/ham/internal/cmd/clean"
 	"github.com/antony-jr/ham/internal/cmd/genkey"
 	"github.com/antony-jr/ham/internal/cmd/get"
 	"github.com/antony-jr/ham/internal/cmd/initialize"
+	"github.com/antony-jr/ham/internal/cmd/schedule"
+	"github.com/antony-jr/ham/internal/cmd/search"
 )
 
 type rootT struct {
@@ -35,7 +38,21 @@ func Run() error {
 		cli.Tree(help),
 		cli.Tree(initialize.NewCommand()),
 		cli.Tree(get.NewCommand()),
+		cli.Tree(get.NewLogCommand()),
+		cli.Tree(get.NewHaltCommand()),
 		cli.Tree(clean.NewCommand()),
 		cli.Tree(genkey.NewCommand()),
+		cli.Tree(search.NewCommand()),
+		cli.Tree(search.NewRecipesCommand()),
+		cli.Tree(answers.NewCommand(),
+			cli.Tree(answers.NewInitCommand()),
+		),
+		cli.Tree(schedule.NewCommand(),
+			cli.Tree(schedule.NewAddCommand()),
+			cli.Tree(schedule.NewListCommand()),
+			cli.Tree(schedule.NewRemoveCommand()),
+			cli.Tree(schedule.NewLogCommand()),
+		),
+		cli.Tree(schedule.NewDaemonCommand()),
 	).Run(os.Args[1:])
 }
@@ -0,0 +1,96 @@
+package answers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/antony-jr/ham/internal/core"
+	"github.com/antony-jr/ham/internal/helpers"
+	"github.com/mkideal/cli"
+)
+
+type answersT struct {
+	cli.Helper
+}
+
+type answersInitT struct {
+	cli.Helper
+	Output string `cli:"o,output" usage:"Write the Answers File to the given path instead of stdout."`
+	Json   bool   `cli:"j,json" usage:"Generate a JSON Answers File instead of YAML."`
+	Force  bool   `cli:"f,force" usage:"Overwrite the Output File if it exists."`
+}
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name: "answers",
+		Desc: "Manage Answers Files used to Answer Recipe Questions",
+		Text: `
+Syntax: ham answers init [RECIPE LOCATION]
+
+Generate a Answers File Template:
+   ham answers init ~@gh/enchilada_los18.1 -o answers.yml
+   ham answers init --json ./examples/enchilada-los18.1 -o answers.json`,
+		Argv: func() interface{} { return new(answersT) },
+		Fn: func(ctx *cli.Context) error {
+			ctx.WriteUsage()
+			return nil
+		},
+	}
+}
+
+func NewInitCommand() *cli.Command {
+	return &cli.Command{
+		Name: "init",
+		Desc: "Generate a Answers File Template for the Questions of a Recipe",
+		Argv: func() interface{} { return new(answersInitT) },
+		NumArg: func(n int) bool {
+			return n == 1
+		},
+		Fn: func(ctx *cli.Context) error {
+			argv := ctx.Argv().(*answersInitT)
+			args := ctx.Args()
+			if len(args) != 1 {
+				return nil
+			}
+
+			recipe, err := core.OpenRecipeSource(args[0])
+			if err != nil {
+				return err
+			}
+			defer recipe.Remove()
+
+			hf, err := core.NewHAMFile(recipe.Dir)
+			if err != nil {
+				return err
+			}
+
+			template, err := hf.AnswersTemplate(argv.Json)
+			if err != nil {
+				return err
+			}
+
+			if len(argv.Output) == 0 {
+				fmt.Print(template)
+				return nil
+			}
+
+			exists, err := helpers.FileExists(argv.Output)
+			if err != nil {
+				return err
+			}
+
+			if exists && !argv.Force {
+				return errors.New("Answers File Already Exists, Run with -f flag.")
+			}
+
+			err = os.WriteFile(argv.Output, []byte(template), 0600)
+			if err != nil {
+				return errors.New("Cannot Write: " + argv.Output)
+			}
+
+			fmt.Printf("Wrote Answers File for %d Questions to %s\n", len(hf.Args), argv.Output)
+			return nil
+		},
+	}
+}
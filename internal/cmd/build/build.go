@@ -2,11 +2,15 @@ package build
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/antony-jr/ham/internal/banner"
@@ -21,10 +25,20 @@ import (
 
 type buildT struct {
 	cli.Helper
-	Sum        string `cli:"*s,sum" usage:"SHA256 Hash of the main ham.yaml file"`
+	Sum        string `cli:"*s,sum" usage:"SHA256 Hash of the Recipe"`
 	RecipePath string `cli:"*r,recipe" usage:"Recipe file path which has the ham.yaml"`
 	VarsPath   string `cli:"*a,vars" usage:"JSON file path containing all required build variables prompted"`
+	Resolved   string `cli:"resolved" usage:"Recipe resolved by ham get, for recipes with include or extends"`
 	KeepServer bool   `cli:"k,keep-server" usage:"Don't Destroy the Remote Server on any error."`
+	Variant    string `cli:"variant" usage:"Build only this Variant of the Recipe Matrix"`
+	Durations  string `cli:"durations" usage:"JSON file with the seconds every step took in previous builds"`
+	Heads      string `cli:"heads" usage:"JSON file with the commits of the watched remotes the build is started with"`
+}
+
+type variantStatusT struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Percentage int    `json:"percentage"`
 }
 
 type statusT struct {
@@ -33,6 +47,80 @@ type statusT struct {
 	Title      string
 	Error      error
 	Percentage int
+	Variant    string
+	Variants   []variantStatusT
+
+	// Steps of the variant being built, which is VariantIndex
+	// of VariantCount. Started is when the first step started.
+	mutex        sync.Mutex
+	Steps        []stepStatusT
+	VariantIndex int
+	VariantCount int
+	Started      time.Time
+
+	// The terminal of the running step, stopped when the build is
+	// halted. KeepServer is set if the halt asked to keep it.
+	terminal   *Terminal
+	KeepServer bool
+
+	// The hooks of the recipe which ran as the build ended.
+	recipeHooks *core.HAMHooks
+	Hooks       []hookStatusT
+
+	// Where the steps run if the recipe has a container.
+	container *buildContainer
+
+	// The manifests written so far, pushed to streaming clients
+	// since the server is gone soon after the build.
+	manifests []builtManifestT
+}
+
+// The status server reads the state from it's own goroutines, so
+// what it reads is only changed with the mutex held.
+func (state *statusT) setStatus(status string, title string) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.Status = status
+	state.Title = title
+}
+
+func (state *statusT) setTitle(title string) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.Title = title
+}
+
+// Runs change with the mutex held, for changes to more than the
+// status.
+func (state *statusT) update(change func()) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	change()
+}
+
+// True once a client asked the build to stop.
+func (state *statusT) quitting() bool {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	return state.Quit
+}
+
+func (state *statusT) keepServer() bool {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	return state.KeepServer
+}
+
+func (state *statusT) err() error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	return state.Error
 }
 
 func NewCommand() *cli.Command {
@@ -46,7 +134,13 @@ func NewCommand() *cli.Command {
 				return errors.New("OS Not Supported.")
 			}
 
-			hf, err := core.NewHAMFile(argv.RecipePath)
+			var hf core.HAMFile
+			var err error
+			if len(argv.Resolved) != 0 {
+				hf, err = core.NewResolvedHAMFile(argv.RecipePath, argv.Resolved)
+			} else {
+				hf, err = core.NewHAMFile(argv.RecipePath)
+			}
 			if err != nil {
 				return err
 			}
@@ -61,10 +155,21 @@ func NewCommand() *cli.Command {
 				return errors.New("SHA256 Mismatch, Bad File.")
 			}
 
+			// A single variant of the matrix is built on it's
+			// own server, named by the sum of the variant.
+			serverSum := hf.SHA256Sum
+			if len(argv.Variant) != 0 {
+				variant, err := hf.Variant(argv.Variant)
+				if err != nil {
+					return err
+				}
+				serverSum = variant.SHA256Sum
+			}
+
 			// We assume the current server name at hetzner to
 			// be this and we use this assumption to destroy
 			// the server when the build is done.
-			serverName := helpers.ServerNameFromSHA256(hf.SHA256Sum)
+			serverName := helpers.ServerNameFromSHA256(serverSum)
 			fmt.Printf("Build Server: %s\n", serverName)
 
 			keepArg := ""
@@ -89,6 +194,19 @@ func NewCommand() *cli.Command {
 					argv.Sum},
 			}
 
+			if len(argv.Resolved) != 0 {
+				dctx.Args = append(dctx.Args, "--resolved", argv.Resolved)
+			}
+			if len(argv.Variant) != 0 {
+				dctx.Args = append(dctx.Args, "--variant", argv.Variant)
+			}
+			if len(argv.Durations) != 0 {
+				dctx.Args = append(dctx.Args, "--durations", argv.Durations)
+			}
+			if len(argv.Heads) != 0 {
+				dctx.Args = append(dctx.Args, "--heads", argv.Heads)
+			}
+
 			d, err := dctx.Reborn()
 			if err != nil {
 				return err
@@ -106,30 +224,66 @@ func NewCommand() *cli.Command {
 			// the TCP server responds with this
 			// status string when asked
 			status := statusT{
-				false,
-				"Running",
-				"",
-				nil,
-				0,
+				Status:      "Running",
+				recipeHooks: &hf.HAMHooks,
+			}
+
+			// The output of the steps is looked at for the
+			// progress of the command they run.
+			patterns, err := hf.ProgressPatterns()
+			if err != nil {
+				return err
+			}
+			buildLog.onLine = func(text string) {
+				progress, ok := core.ParseProgress(patterns, text)
+				if ok {
+					status.setStepProgress(progress)
+				}
 			}
 
 			go statusServer(&status)
+			go buildLog.flushEvery(time.Second)
+
+			// The vars are read first, so the notify sinks of
+			// the recipe can use the args.
+			vars, varsErr := helpers.ReadVarsJsonFile(argv.VarsPath)
+			env := map[string]string{}
+			for varName, varValue := range vars {
+				env[core.ArgEnvName(varName)] = varValue
+			}
+			notifier := core.NewNotifier(&hf, serverName, env, buildLog)
+			defer notifier.Close()
+
+			// Without durations every step weighs the same.
+			durations := core.StepDurations{}
+			if len(argv.Durations) != 0 {
+				source, err := os.ReadFile(argv.Durations)
+				if err == nil {
+					_ = json.Unmarshal(source, &durations)
+				}
+			}
 
 			config, err := core.GetConfiguration()
 			if err != nil {
-				return checkErrorStatus(&status, err)
+				return checkErrorStatus(&status, notifier, err)
 			}
 			client := hcloud.NewClient(hcloud.WithToken(config.APIKey))
 
+			notifier.Notify(core.BuildEvent{
+				Event:   core.EVENT_SERVER_CREATED,
+				Message: serverInfo(client, serverName),
+			})
+
 			// Destroy server
 			// on close.
-			if !argv.KeepServer {
-				defer destroyCurrentServer(client, hf.SHA256Sum)
-			}
+			defer func() {
+				if !argv.KeepServer && !status.keepServer() {
+					destroyCurrentServer(client, serverSum, notifier)
+				}
+			}()
 
-			vars, err := helpers.ReadVarsJsonFile(argv.VarsPath)
-			if err != nil {
-				return checkErrorStatus(&status, err)
+			if varsErr != nil {
+				return checkErrorStatus(&status, notifier, varsErr)
 			}
 
 			// Get ham ssh key
@@ -138,14 +292,14 @@ func NewCommand() *cli.Command {
 				"ham-ssh-key",
 			)
 			if err != nil {
-				return checkErrorStatus(&status, err)
+				return checkErrorStatus(&status, notifier, err)
 			}
 
 			// Set Label to Indicate Progress of
 			// this build.
 			hamSSHKey, err = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "inprogress")
 			if err != nil {
-				return checkErrorStatus(&status, err)
+				return checkErrorStatus(&status, notifier, err)
 			}
 
 			// Install Dependencies for LineageOS build/AOSP
@@ -153,216 +307,199 @@ func NewCommand() *cli.Command {
 				term, err := NewTerminal(hf.SHA256Sum + "-prebuild")
 				if err != nil {
 					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-					return checkErrorStatus(&status, err)
+					return checkErrorStatus(&status, notifier, err)
 				}
 
-				// We are tracking stable LTS release of Ubuntu
-				// Ubuntu 20.04 (Focal)
-				deps := []string{
-					"bc",
-					"bison",
-					"build-essential",
-					"ccache",
-					"curl",
-					"flex",
-					"g++-multilib",
-					"gcc-multilib",
-					"git",
-					"gnupg",
-					"gperf",
-					"imagemagick",
-					"lib32ncurses5-dev",
-					"lib32readline-dev",
-					"lib32z1-dev",
-					"libelf-dev",
-					"liblz4-tool",
-					"libncurses5",
-					"libncurses5-dev",
-					"libsdl1.2-dev",
-					"libssl-dev",
-					"libxml2",
-					"libxml2-utils",
-					"lzop",
-					"pngcrush",
-					"rsync",
-					"schedtool",
-					"squashfs-tools",
-					"xsltproc",
-					"zip",
-					"zlib1g-dev",
-					"android-sdk-platform-tools",
-					"git-lfs",
-				}
-
-				// The user can also install their own deps
-				// from the yaml file too. This just makes life
-				// so much easier when building a striaght forward
-				// build from lineage.
-				dep_install_command := fmt.Sprintf("DEBIAN_FRONTEND=noninteractive apt install -y -qq %s",
-					strings.Join(deps, " "))
-
-				commands := []string{
-					"export DEBIAN_FRONTEND=noninteractive",
-					"apt update -y -qq",
-					"apt upgrade -y -qq",
-					dep_install_command,
-					"curl https://storage.googleapis.com/git-repo-downloads/repo > /usr/bin/repo",
-					"chmod a+x /usr/bin/repo",
-					"git config --global user.email \"ham@antonyjr.in\"",
-					"git config --global user.name \"Hetzner Android Make\"",
-					"echo 'export USE_CCACHE=1' >> ~/.bashrc",
-					"echo 'export USE_CCACHE=1' >> ~/.profile",
-					"echo 'export CCACHE_EXEC=/usr/bin/ccache' >> ~/.bashrc",
-					"echo 'export CCACHE_EXEC=/usr/bin/ccache' >> ~/.profile",
-					"ccache -M 50G",
-					"ccache -o compression=true",
+				// The default packages depend on the Ubuntu
+				// version of the server, the recipe can add
+				// it's own or only install it's own.
+				release := ubuntuRelease()
+				packages := hf.Dependencies.AptPackages(release)
+				if hf.Container != nil {
+					if _, err := detectContainerRuntime(); err != nil {
+						packages = append(packages, "docker.io")
+					}
 				}
+				fmt.Printf("Installing %d Packages on Ubuntu %s\n", len(packages), release)
+				commands, install := prebuildCommands(&hf.Dependencies, packages)
 
 				for varName, varValue := range vars {
-					varName = strings.ToUpper(varName)
-					varName = strings.ReplaceAll(varName, " ", "_")
-					varName = strings.ReplaceAll(varName, "-", "_")
+					varName = core.ArgEnvName(varName)
 					varValue = strings.ReplaceAll(varValue, "\"", "\\\"")
 					cmd := fmt.Sprintf("echo 'export %s=\"%s\"' >> ~/.bashrc", varName, varValue)
 					commands = append(commands, cmd)
 				}
 
-				status.Status = "Installing Dependencies"
-				status.Title = "Installing Dependencies"
+				status.setStatus("Installing Dependencies", "Installing Dependencies")
+				buildLog.mark("Installing Dependencies")
+				status.setTerminal(&term)
 
 				for indx, com := range commands {
-					if status.Quit {
-						hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-						time.Sleep(time.Minute * time.Duration(1))
-						return errors.New("User Quit the Build")
+					if status.quitting() {
+						break
 					}
 
 					err := term.ExecTerminal(indx, com)
-					if err != nil {
-						hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-						return checkErrorStatus(&status, errors.New("Prebuild Failed ("+err.Error()+")"))
+					if err == nil {
+						err = term.WaitTerminal(indx)
 					}
-
-					err = term.WaitTerminal(indx)
-					if err != nil {
+					if err != nil && !status.quitting() {
+						if indx == install {
+							err = aptInstallError(packages, release)
+						}
 						hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-						return checkErrorStatus(&status, errors.New("Prebuild Failed ("+err.Error()+")"))
+						return checkErrorStatus(&status, notifier, errors.New("Prebuild Failed ("+err.Error()+")"))
 					}
-
 				}
 
+				status.setTerminal(nil)
 				term.CloseTerminal()
+				if status.quitting() {
+					hamSSHKey = cancelBuild(&status, notifier, &client.SSHKey, hamSSHKey, []string{serverName})
+					return errors.New("Build Cancelled")
+				}
+
+				notifier.Notify(core.BuildEvent{
+					Event:   core.EVENT_INIT_DONE,
+					Message: "Installed Dependencies",
+				})
 			}
 
-			// Start Executing Recipe Commands.
-			terminal, err := NewTerminal(hf.SHA256Sum)
-			if err != nil {
-				hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-				return checkErrorStatus(&status, err)
+			// Steps, post build and hooks run in the container
+			// of the recipe, with the args in their env.
+			if hf.Container != nil {
+				status.setTitle("Starting Container")
+				buildLog.mark("Starting Container")
+
+				containerEnv := []string{}
+				for varName, varValue := range vars {
+					containerEnv = append(containerEnv, core.ArgEnvName(varName)+"="+varValue)
+				}
+
+				runtime, err := detectContainerRuntime()
+				if err == nil {
+					status.container, err = startContainer(runtime, hf.Container, serverName, containerEnv)
+				}
+				if err != nil {
+					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
+					return checkErrorStatus(&status, notifier, errors.New("Cannot Start Container ("+err.Error()+")"))
+				}
+
+				// A kept server keeps it's container to look
+				// around in.
+				defer func() {
+					if !argv.KeepServer && !status.keepServer() {
+						_ = status.container.remove()
+					}
+				}()
 			}
-			defer terminal.CloseTerminal()
 
-			// Change directory to /ham-build
-			err = terminal.ExecTerminal(-1, "mkdir -p /ham-build; cd /ham-build")
-			if err != nil {
-				hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-				return checkErrorStatus(&status, errors.New("Cannot Change to /ham-build Directory"))
+			variants, err := hf.Variants()
+			if len(argv.Variant) != 0 {
+				var variant core.HAMVariant
+				variant, err = hf.Variant(argv.Variant)
+				variants = []core.HAMVariant{variant}
 			}
-			err = terminal.WaitTerminal(-1)
 			if err != nil {
 				hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-				return checkErrorStatus(&status, errors.New("Cannot Change to /ham-build Directory"))
+				return checkErrorStatus(&status, notifier, err)
 			}
 
-			buildLen := len(hf.Build)
-			for index, el := range hf.Build {
-				if status.Quit {
-					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-					time.Sleep(time.Minute * time.Duration(1))
-					return errors.New("User Quit the Build")
+			status.update(func() {
+				status.VariantCount = len(variants)
+				status.Variants = make([]variantStatusT, len(variants))
+				for index, variant := range variants {
+					status.Variants[index] = variantStatusT{
+						Name:   variant.Name,
+						Status: "Waiting",
+					}
 				}
-
-				status.Status = "Building"
-				status.Title = el.Title
-				if status.Error != nil {
-					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-					return checkErrorStatus(&status, status.Error)
+			})
+
+			// Variants are built one after the other in the same
+			// /ham-build, so they share the synced source and ccache.
+			failed := []string{}
+			manifest := newManifest(client, serverName, &hf)
+			for index := range variants {
+				variant := &variants[index]
+				variantLabel := helpers.ServerNameFromSHA256(variant.SHA256Sum)
+
+				status.update(func() {
+					status.Variant = variant.Name
+					status.VariantIndex = index
+					status.Variants[index].Status = "Building"
+				})
+				if variantLabel != serverName {
+					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, variantLabel, "inprogress")
 				}
 
-				err := checkErrorStatus(&status, terminal.ExecTerminal(index, el.Cmd))
-				if err != nil {
-					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-					return err
+				err = buildVariant(&status, notifier, durations, vars, variant, manifest)
+				if status.quitting() {
+					status.update(func() { status.Variants[index].Status = "Cancelled" })
+					hamSSHKey = cancelBuild(&status, notifier, &client.SSHKey, hamSSHKey, []string{variantLabel, serverName})
+					return errors.New("Build Cancelled")
 				}
 
-				err = checkErrorStatus(&status, terminal.WaitTerminal(index))
 				if err != nil {
-					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-					return err
+					status.update(func() { status.Variants[index].Status = "Failed" })
+					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, variantLabel, "failed")
+					if len(variants) == 1 {
+						hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
+						return checkErrorStatus(&status, notifier, err)
+					}
+
+					// A broken variant should not stop the others.
+					fmt.Printf("Variant %s Failed (%s)\n", variant.Name, err.Error())
+					notifier.Notify(core.BuildEvent{
+						Event:   core.EVENT_FAILED,
+						Variant: variant.Name,
+						Message: err.Error(),
+					})
+					failed = append(failed, variant.Name)
+					continue
 				}
 
-				// Avoid Premature Close When Tracking
-				percent := int((float32(index) * 100.0) / float32(buildLen))
-				if percent >= 1.0 {
-					percent = percent - 1.0
+				status.update(func() {
+					status.Variants[index].Status = "Successful"
+					status.Variants[index].Percentage = 100
+				})
+				if variantLabel != serverName {
+					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, variantLabel, "successful")
 				}
-				status.Percentage = percent
 			}
 
-			status.Percentage = 99
-			status.Status = "Finished"
-			status.Title = "Build Finished"
-			fmt.Println("Built Successfully.")
-			fmt.Println("Running Post Build Script... ")
-
-			status.Status = "Post Build"
-			status.Title = "Running Post Build"
-
-			pbTerminal, err := NewTerminal(hf.SHA256Sum + "-postbuild")
-			if err != nil {
+			if len(failed) != 0 {
 				hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-				return checkErrorStatus(&status, err)
+				return checkErrorStatus(&status, notifier, errors.New(fmt.Sprintf("%d of %d Variants Failed (%s)",
+					len(failed),
+					len(variants),
+					strings.Join(failed, ", "))))
 			}
-			defer pbTerminal.CloseTerminal()
 
-			// Change directory to /ham-build
-			err = pbTerminal.ExecTerminal(-1, "cd /ham-build")
-			if err != nil {
-				hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-				return checkErrorStatus(&status, errors.New("Cannot Change to /ham-build Directory"))
-			}
-			err = pbTerminal.WaitTerminal(-1)
-			if err != nil {
-				hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-				return checkErrorStatus(&status, errors.New("Cannot Change to /ham-build Directory"))
-			}
+			status.runHooks(status.recipeHooks, "successful", "")
 
-			for index, cmd := range hf.PostBuild {
-				if status.Quit {
-					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-					time.Sleep(time.Minute * time.Duration(1))
-					return errors.New("User Quit the Build")
-				}
-
-				err := pbTerminal.ExecTerminal(index, cmd)
-				if err != nil {
-					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-					return checkErrorStatus(&status, errors.New("Postbuild Failed ("+err.Error()+")"))
-				}
-
-				err = pbTerminal.WaitTerminal(index)
-				if err != nil {
-					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "failed")
-					return checkErrorStatus(&status, errors.New("Postbuild Failed ("+err.Error()+")"))
+			// Kept at Hetzner for ham get --if-changed, even if no
+			// one is watching the build finish.
+			if len(argv.Heads) != 0 {
+				heads := map[string]string{}
+				source, err := os.ReadFile(argv.Heads)
+				if err == nil && json.Unmarshal(source, &heads) == nil {
+					hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, core.WatchHeadsLabel(serverName), core.WatchHeadsSum(heads))
 				}
 			}
 
 			hamSSHKey, _ = helpers.UpdateSSHKeyLabel(&client.SSHKey, hamSSHKey, serverName, "successful")
-			status.Percentage = 100
-			status.Status = "Finished"
-			status.Title = "Completed"
+			status.update(func() {
+				status.Percentage = 100
+				status.Status = "Finished"
+				status.Title = "Completed"
+			})
 
 			fmt.Println("Finished Build")
+			notifier.Notify(core.BuildEvent{
+				Event:   core.EVENT_SUCCEEDED,
+				Message: "Build Successful",
+			})
 
 			// Give Some Time for Clients to Fetch this Status
 			time.Sleep(time.Minute * time.Duration(1))
@@ -371,7 +508,193 @@ func NewCommand() *cli.Command {
 	}
 }
 
-func checkErrorStatus(state *statusT, err error) error {
+// Runs the build steps and the post build of a single variant,
+// durations weigh the steps in the progress and the args in vars
+// decide which steps run. The manifest is written to the output of
+// the variant once it's built.
+func buildVariant(status *statusT, notifier *core.Notifier, durations core.StepDurations, vars map[string]string, variant *core.HAMVariant, manifest core.BuildManifest) error {
+	setup := []string{"mkdir -p /ham-build", "cd /ham-build"}
+	for _, env := range variant.Env() {
+		parts := strings.SplitN(env, "=", 2)
+		setup = append(setup, fmt.Sprintf("export %s='%s'", parts[0], parts[1]))
+	}
+	if len(variant.Name) != 0 {
+		setup = append(setup, "mkdir -p \"$HAM_OUTPUT\"")
+		fmt.Printf("Building Variant %s\n", variant.Name)
+	}
+
+	terminal, err := status.newTerminal(variant.SHA256Sum)
+	if err != nil {
+		return err
+	}
+	defer terminal.CloseTerminal()
+	status.setTerminal(&terminal)
+	defer status.setTerminal(nil)
+
+	// Change directory to /ham-build
+	err = terminal.ExecTerminal(-1, strings.Join(setup, " && "))
+	if err != nil {
+		return errors.New("Cannot Change to /ham-build Directory")
+	}
+	err = terminal.WaitTerminal(-1)
+	if err != nil {
+		return errors.New("Cannot Change to /ham-build Directory")
+	}
+
+	status.setSteps(variant.Build, durations)
+
+	index, count := 0, 0
+	status.update(func() {
+		index, count = status.VariantIndex, status.VariantCount
+	})
+	buildLen := len(variant.Build)
+	for stepIndex, el := range variant.Build {
+		if status.quitting() {
+			return errors.New("User Quit the Build")
+		}
+
+		title := el.Title
+		if len(variant.Name) != 0 {
+			title = fmt.Sprintf("[%s] %s", variant.Name, el.Title)
+		}
+		status.setStatus("Building", title)
+		if err := status.err(); err != nil {
+			return err
+		}
+
+		if !stepRuns(el, variant, vars, &terminal) {
+			fmt.Printf("Skipping %s (if: %s)\n", el.Title, el.If)
+			buildLog.mark(fmt.Sprintf("[%d/%d] %s (Skipped)", stepIndex+1, buildLen, title))
+			status.skipStep(stepIndex)
+			continue
+		}
+
+		step := core.BuildEvent{
+			Variant:   variant.Name,
+			Step:      el.Title,
+			StepIndex: stepIndex + 1,
+			Steps:     buildLen,
+		}
+		step.Event = core.EVENT_STEP_STARTED
+		notifier.Notify(step)
+		buildLog.mark(fmt.Sprintf("[%d/%d] %s", stepIndex+1, buildLen, title))
+		status.startStep(stepIndex)
+
+		err := terminal.ExecTerminal(stepIndex, stepCommand(el))
+		if err == nil {
+			err = terminal.WaitTerminal(stepIndex)
+		}
+		status.finishStep(stepIndex, err)
+		if err != nil {
+			return err
+		}
+
+		step.Event = core.EVENT_STEP_FINISHED
+		notifier.Notify(step)
+
+		// Avoid Premature Close When Tracking
+		_, fraction, _, _ := status.progress()
+		percent := int(fraction * 100)
+		if percent >= 1.0 {
+			percent = percent - 1.0
+		}
+		status.update(func() {
+			status.Variants[index].Percentage = percent
+			status.Percentage = (index*100 + percent) / count
+		})
+	}
+
+	status.update(func() {
+		status.Percentage = ((index+1)*100 - 1) / count
+		status.Status = "Finished"
+		status.Title = "Build Finished"
+	})
+	fmt.Println("Built Successfully.")
+
+	// A missing manifest should not fail a good build.
+	manifestPath, err := writeManifest(manifest, status, variant, vars)
+	if err != nil {
+		fmt.Printf("Cannot Write Build Manifest (%s)\n", err.Error())
+	} else {
+		fmt.Printf("Wrote Build Manifest to %s\n", manifestPath)
+	}
+
+	fmt.Println("Running Post Build Script... ")
+
+	title := "Running Post Build"
+	if len(variant.Name) != 0 {
+		title = fmt.Sprintf("[%s] %s", variant.Name, title)
+	}
+	status.setStatus("Post Build", title)
+	buildLog.mark(title)
+
+	pbTerminal, err := status.newTerminal(variant.SHA256Sum + "-postbuild")
+	if err != nil {
+		return err
+	}
+	defer pbTerminal.CloseTerminal()
+	status.setTerminal(&pbTerminal)
+
+	// Change directory to /ham-build
+	err = pbTerminal.ExecTerminal(-1, strings.Join(setup, " && "))
+	if err != nil {
+		return errors.New("Cannot Change to /ham-build Directory")
+	}
+	err = pbTerminal.WaitTerminal(-1)
+	if err != nil {
+		return errors.New("Cannot Change to /ham-build Directory")
+	}
+
+	for cmdIndex, cmd := range variant.PostBuild {
+		if status.quitting() {
+			return errors.New("User Quit the Build")
+		}
+
+		err := pbTerminal.ExecTerminal(cmdIndex, cmd)
+		if err != nil {
+			return errors.New("Postbuild Failed (" + err.Error() + ")")
+		}
+
+		err = pbTerminal.WaitTerminal(cmdIndex)
+		if err != nil {
+			return errors.New("Postbuild Failed (" + err.Error() + ")")
+		}
+	}
+
+	return nil
+}
+
+// Runs the on_cancel hook of the recipe after the build was halted
+// and records the build as cancelled for the labels given.
+func cancelBuild(state *statusT, notifier *core.Notifier, sshKeyClient *hcloud.SSHKeyClient, hamSSHKey *hcloud.SSHKey, labels []string) *hcloud.SSHKey {
+	state.setStatus("Cancelling", "Cancelling Build")
+	state.runHooks(state.recipeHooks, "cancelled", "Build Halted")
+
+	for _, label := range labels {
+		hamSSHKey, _ = helpers.UpdateSSHKeyLabel(sshKeyClient, hamSSHKey, label, "cancelled")
+	}
+
+	variant := ""
+	state.update(func() { variant = state.Variant })
+	notifier.Notify(core.BuildEvent{
+		Event:   core.EVENT_CANCELLED,
+		Variant: variant,
+		Message: "Build Halted",
+	})
+
+	state.update(func() {
+		state.Status = "Cancelled"
+		state.Title = "Build Cancelled"
+		state.Percentage = 100
+	})
+
+	// Give Some Time for Clients to Fetch this Status
+	fmt.Println("Build Cancelled")
+	time.Sleep(time.Minute * time.Duration(1))
+	return hamSSHKey
+}
+
+func checkErrorStatus(state *statusT, notifier *core.Notifier, err error) error {
 	// Set Build to Error
 	// We will wait for 2 mins before we exit setting
 	// the status of the build at hetzner labels.
@@ -379,26 +702,59 @@ func checkErrorStatus(state *statusT, err error) error {
 		return nil
 	}
 
-	state.Status = "Build Failed"
-	state.Title = err.Error()
-	state.Error = err
-	state.Percentage = 100
+	// The hooks of the recipe may upload what's left of the build
+	// before the server is gone.
+	state.runHooks(state.recipeHooks, "failed", err.Error())
+
+	notifier.Notify(core.BuildEvent{
+		Event:   core.EVENT_FAILED,
+		Message: err.Error(),
+	})
+
+	state.update(func() {
+		state.Status = "Build Failed"
+		state.Title = err.Error()
+		state.Error = err
+		state.Percentage = 100
+	})
 
 	time.Sleep(time.Minute * time.Duration(2))
 	return err
 }
 
-func destroyCurrentServer(client *hcloud.Client, UniqueID string) {
+func destroyCurrentServer(client *hcloud.Client, UniqueID string, notifier *core.Notifier) {
 	serverName := helpers.ServerNameFromSHA256(UniqueID)
 	fmt.Println("Destroying ", serverName)
 
+	// Nothing can be sent once the server is gone.
+	notifier.Notify(core.BuildEvent{
+		Event:   core.EVENT_DESTROYED,
+		Message: "Destroying Build Server",
+	})
+	notifier.Close()
+
 	helpers.TryDeleteServer(client, serverName, 20, 5)
 }
 
+// Describes the build server for the server created event.
+func serverInfo(client *hcloud.Client, serverName string) string {
+	server, _, err := client.Server.GetByName(context.Background(), serverName)
+	if err != nil || server == nil {
+		return "Build Server " + serverName
+	}
+
+	return fmt.Sprintf("Build Server %s (%s at %s) Created at %s",
+		serverName,
+		server.ServerType.Name,
+		server.Datacenter.Location.Name,
+		server.Created.UTC().Format(time.RFC3339))
+}
+
 func statusServer(state *statusT) {
-	listener, err := net.Listen("tcp", "0.0.0.0:1695")
+	// Clients reach it through SSH, it's not for the world.
+	listener, err := net.Listen("tcp", "127.0.0.1:1695")
 	if err != nil {
-		state.Error = err
+		state.update(func() { state.Error = err })
 		return
 	}
 
@@ -414,6 +770,74 @@ func statusServer(state *statusT) {
 	}
 }
 
+// The current status of the build as sent to clients.
+func statusResponse(state *statusT) statusResponseT {
+	// A snapshot, progress and hooks take the mutex themselves.
+	state.mutex.Lock()
+	resp := statusResponseT{
+		Percentage: state.Percentage,
+		Status:     state.Status,
+		Progress:   state.Title,
+	}
+
+	// Only matrix builds have named variants.
+	if len(state.Variants) > 1 {
+		resp.Variant = state.Variant
+		resp.Variants = append([]variantStatusT{}, state.Variants...)
+	}
+
+	hasSteps := len(state.Steps) != 0
+	index, count := state.VariantIndex, state.VariantCount
+	err := state.Error
+	state.mutex.Unlock()
+
+	if hasSteps {
+		var fraction float64
+		resp.Steps, fraction, resp.Elapsed, resp.ETA = state.progress()
+
+		// The percentage moves while a step runs, but only up to
+		// where the step would finish.
+		if resp.Status == "Building" && err == nil {
+			percent := int(fraction * 100)
+			if percent > 99 {
+				percent = 99
+			}
+
+			overall := (index*100 + percent) / max(count, 1)
+			if overall > resp.Percentage {
+				resp.Percentage = overall
+			}
+			if len(resp.Variants) > index && percent > resp.Variants[index].Percentage {
+				resp.Variants[index].Percentage = percent
+			}
+		}
+	}
+
+	resp.Hooks = state.hooks()
+
+	if err != nil {
+		resp.Error = true
+		resp.Message = err.Error()
+		resp.Status = ""
+		resp.Progress = ""
+	}
+	return resp
+}
+
+type statusResponseT struct {
+	Error      bool             `json:"error"`
+	Message    string           `json:"message,omitempty"`
+	Status     string           `json:"status,omitempty"`
+	Progress   string           `json:"progress,omitempty"`
+	Percentage int              `json:"percentage"`
+	Variant    string           `json:"variant,omitempty"`
+	Variants   []variantStatusT `json:"variants,omitempty"`
+	Steps      []stepStatusT    `json:"steps,omitempty"`
+	Elapsed    int64            `json:"elapsed,omitempty"`
+	ETA        int64            `json:"eta"`
+	Hooks      []hookStatusT    `json:"hooks,omitempty"`
+}
+
 func handleRequest(state *statusT, conn net.Conn) {
 	buf := make([]byte, 1024)
 	rLen, err := conn.Read(buf)
@@ -422,23 +846,53 @@ func handleRequest(state *statusT, conn net.Conn) {
 	}
 
 	request := strings.ToLower(string(buf[:rLen]))
-	var resp string
-	if state.Error != nil {
-		resp = fmt.Sprintf("{ \"error\": true, \"message\": \"%s\" }\n",
-			state.Error)
-	} else if request == "status" {
-		resp = fmt.Sprintf("{ \"error\": false, \"status\": \"%s\", \"progress\": \"%s\", \"percentage\": %d }\n",
-			state.Status, state.Title, state.Percentage)
-	} else if request == "quit" {
-		resp = fmt.Sprintf("{ \"error\": false, \"status\": \"Stopping\", \"progress\": \"Stopping\", \"percentage\": %d }\n",
-			state.Percentage)
-		state.Status = "Stopping Build"
-		state.Title = "Stopping Build"
-		state.Quit = true
-	} else {
-		resp = fmt.Sprintf("{ \"error\": true, \"message\": \"Unknown command\" }\n")
+	if request == "stream" {
+		streamBuild(state, conn)
+		return
+	}
+
+	// quit can give the seconds the running step has before it's
+	// killed, and keep to keep the server.
+	fields := strings.Fields(request)
+	if len(fields) != 0 {
+		request = fields[0]
+	}
+
+	resp := statusResponse(state)
+	if !resp.Error && request == "quit" {
+		grace := DEFAULT_HALT_GRACE
+		if len(fields) > 1 {
+			seconds, err := strconv.Atoi(fields[1])
+			if err == nil && seconds >= 0 {
+				grace = time.Second * time.Duration(seconds)
+			}
+		}
+
+		resp.Status = "Stopping"
+		resp.Progress = "Stopping"
+		state.mutex.Lock()
+		if !state.Quit {
+			state.Status = "Stopping Build"
+			state.Title = "Stopping Build"
+			state.KeepServer = len(fields) > 2 && fields[2] == "keep"
+			state.Quit = true
+			go state.halt(grace)
+		}
+		state.mutex.Unlock()
+	} else if !resp.Error && request != "status" {
+		resp = statusResponseT{
+			Error:      true,
+			Message:    "Unknown command",
+			Percentage: resp.Percentage,
+		}
+	}
+
+	out, err := json.Marshal(resp)
+	if err != nil {
+		conn.Close()
+		return
 	}
 
-	conn.Write([]byte(resp))
+	conn.Write(append(out, '\n'))
 	conn.Close()
 }
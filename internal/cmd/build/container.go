@@ -0,0 +1,198 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/antony-jr/ham/internal/core"
+)
+
+// Runs containers on the build server, docker and podman take the
+// same arguments.
+type containerRuntime interface {
+	Name() string
+	Pull(image string) error
+	Build(tag string, dockerfile string, context string) error
+
+	// Starts the container in the background with the directories
+	// mounted and the env given as NAME=value.
+	Start(name string, image string, mounts []string, env []string) error
+
+	// Returns the command for a interactive bash in the container.
+	Shell(name string) *exec.Cmd
+
+	// Runs the command in the container and returns it's output.
+	Exec(name string, args ...string) ([]byte, error)
+
+	// Returns the ID of the image and it's digest at the registry,
+	// a image built from a Dockerfile has no digest.
+	ImageID(image string) (string, string, error)
+	Remove(name string) error
+}
+
+type cliRuntime struct {
+	binary string
+}
+
+// Returns docker or podman, whichever is installed.
+func detectContainerRuntime() (containerRuntime, error) {
+	for _, binary := range []string{"docker", "podman"} {
+		_, err := exec.LookPath(binary)
+		if err == nil {
+			return &cliRuntime{binary: binary}, nil
+		}
+	}
+	return nil, errors.New("Neither docker nor podman is Installed")
+}
+
+func (r *cliRuntime) Name() string {
+	return r.binary
+}
+
+// Runs the runtime with it's output in the build log.
+func (r *cliRuntime) run(env []string, args ...string) error {
+	cmd := exec.Command(r.binary, args...)
+	cmd.Stdout = buildLog
+	cmd.Stderr = buildLog
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	err := cmd.Run()
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s %s Failed (%s)", r.binary, args[0], err.Error()))
+	}
+	return nil
+}
+
+func (r *cliRuntime) Pull(image string) error {
+	return r.run(nil, "pull", image)
+}
+
+func (r *cliRuntime) Build(tag string, dockerfile string, context string) error {
+	return r.run(nil, "build", "-t", tag, "-f", dockerfile, context)
+}
+
+// The container only sleeps, the steps run in it with exec. The
+// values of the env are given to the runtime and not in the
+// arguments, so they are not seen in ps.
+func (r *cliRuntime) Start(name string, image string, mounts []string, env []string) error {
+	args := []string{
+		"run", "-d",
+		"--name", name,
+		"--network", "host",
+		"--workdir", "/ham-build",
+		"--entrypoint", "sleep",
+	}
+	for _, mount := range mounts {
+		args = append(args, "-v", mount+":"+mount)
+	}
+	for _, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		args = append(args, "-e", name)
+	}
+	args = append(args, image, "infinity")
+
+	return r.run(env, args...)
+}
+
+func (r *cliRuntime) Shell(name string) *exec.Cmd {
+	return exec.Command(r.binary, "exec", "-i", "-t", "--workdir", "/ham-build", name, "bash")
+}
+
+func (r *cliRuntime) Exec(name string, args ...string) ([]byte, error) {
+	return exec.Command(r.binary, append([]string{"exec", name}, args...)...).Output()
+}
+
+func (r *cliRuntime) ImageID(image string) (string, string, error) {
+	out, err := exec.Command(r.binary, "image", "inspect", "--format",
+		"{{.Id}} {{range .RepoDigests}}{{.}} {{end}}", image).Output()
+	if err != nil {
+		return "", "", err
+	}
+
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", "", errors.New("No Image ID for " + image)
+	}
+	if len(fields) == 1 {
+		return fields[0], "", nil
+	}
+	return fields[0], fields[1], nil
+}
+
+func (r *cliRuntime) Remove(name string) error {
+	return r.run(nil, "rm", "-f", name)
+}
+
+// The container the steps of the recipe run in.
+type buildContainer struct {
+	runtime containerRuntime
+	name    string
+	image   string
+}
+
+// Pulls or builds the image of the recipe and starts it's container,
+// named after the build server.
+func startContainer(runtime containerRuntime, container *core.HAMContainer, name string, env []string) (*buildContainer, error) {
+	image := container.Image
+	if len(container.Dockerfile) != 0 {
+		image = name
+		dockerfile := container.DockerfilePath()
+		fmt.Printf("Building Container Image from %s with %s\n", dockerfile, runtime.Name())
+		err := runtime.Build(image, dockerfile, filepath.Dir(dockerfile))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fmt.Printf("Pulling Container Image %s with %s\n", image, runtime.Name())
+		err := runtime.Pull(image)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mounts := append([]string{}, core.CONTAINER_MOUNTS...)
+	mounts = append(mounts, TERMINAL_STATUS_DIR)
+	for _, mount := range mounts {
+		err := os.MkdirAll(mount, 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A container left by a previous build on a kept server.
+	_ = runtime.Remove(name)
+
+	err := runtime.Start(name, image, mounts, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return &buildContainer{
+		runtime: runtime,
+		name:    name,
+		image:   image,
+	}, nil
+}
+
+func (c *buildContainer) shell() *exec.Cmd {
+	return c.runtime.Shell(c.name)
+}
+
+func (c *buildContainer) remove() error {
+	return c.runtime.Remove(c.name)
+}
+
+// Returns a terminal for steps and hooks, which is in the container
+// of the recipe if it has one.
+func (state *statusT) newTerminal(UniqueID string) (Terminal, error) {
+	if state.container != nil {
+		return NewContainerTerminal(UniqueID, state.container)
+	}
+	return NewTerminal(UniqueID)
+}
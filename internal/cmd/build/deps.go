@@ -0,0 +1,104 @@
+package build
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/antony-jr/ham/internal/core"
+)
+
+// Returns the Ubuntu version of the build server, like 24.04.
+func ubuntuRelease() string {
+	file, err := os.Open("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if found && key == "VERSION_ID" {
+			return strings.Trim(value, "\"")
+		}
+	}
+	return ""
+}
+
+// Returns the commands which set up the build server, install is
+// the index of the one installing the packages.
+func prebuildCommands(deps *core.HAMDependencies, packages []string) ([]string, int) {
+	commands := []string{
+		"export DEBIAN_FRONTEND=noninteractive",
+		"apt update -y -qq",
+	}
+	if deps.ShouldUpgrade() {
+		commands = append(commands, "apt upgrade -y -qq")
+	}
+
+	install := -1
+	if len(packages) != 0 {
+		install = len(commands)
+		commands = append(commands, fmt.Sprintf("DEBIAN_FRONTEND=noninteractive apt install -y -qq %s",
+			strings.Join(packages, " ")))
+	}
+
+	commands = append(commands, fmt.Sprintf("curl -fsSL %s > /usr/bin/repo", deps.RepoURL()))
+	if len(deps.RepoSHA256) != 0 {
+		commands = append(commands, fmt.Sprintf("echo '%s  /usr/bin/repo' | sha256sum -c -", deps.RepoSHA256))
+	}
+	commands = append(commands,
+		"chmod a+x /usr/bin/repo",
+		"git config --global user.email \"ham@antonyjr.in\"",
+		"git config --global user.name \"Hetzner Android Make\"",
+	)
+
+	// ccache is not there if the recipe skips the default
+	// packages and does not ask for it.
+	for _, pkg := range packages {
+		if pkg == "ccache" || strings.HasPrefix(pkg, "ccache=") {
+			commands = append(commands,
+				"echo 'export USE_CCACHE=1' >> ~/.bashrc",
+				"echo 'export USE_CCACHE=1' >> ~/.profile",
+				"echo 'export CCACHE_EXEC=/usr/bin/ccache' >> ~/.bashrc",
+				"echo 'export CCACHE_EXEC=/usr/bin/ccache' >> ~/.profile",
+				"ccache -M 50G",
+				"ccache -o compression=true",
+			)
+			break
+		}
+	}
+	return commands, install
+}
+
+// Finds the package that can't be installed after apt install of
+// all of them failed, apt only says it failed. Every package is
+// tried on it's own without installing anything.
+func aptInstallError(packages []string, release string) error {
+	for _, pkg := range packages {
+		cmd := exec.Command("apt-get", "install", "-y", "-qq", "--simulate", pkg)
+		cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			continue
+		}
+
+		reason := ""
+		for _, line := range strings.Split(string(out), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "E:") {
+				reason = strings.TrimSpace(strings.TrimPrefix(line, "E:"))
+				break
+			}
+		}
+		if len(reason) == 0 {
+			reason = err.Error()
+		}
+		return errors.New(fmt.Sprintf("Cannot Install Package '%s' on Ubuntu %s (%s)", pkg, release, reason))
+	}
+	return errors.New(fmt.Sprintf("Cannot Install Packages on Ubuntu %s", release))
+}
@@ -3,12 +3,19 @@ package build
 import (
 	"fmt"
 	"github.com/mkideal/cli"
+	"io"
 	"net"
 	"time"
 )
 
+// Seconds the running step has to stop after SIGINT, when the halt
+// does not say.
+const DEFAULT_HALT_GRACE = time.Second * time.Duration(30)
+
 type buildHaltT struct {
 	cli.Helper
+	Grace      int  `cli:"g,grace" usage:"Seconds the running step has to stop after SIGINT before it's killed" dft:"30"`
+	KeepServer bool `cli:"k,keep-server" usage:"Don't Destroy the Server after the Build is Cancelled"`
 }
 
 // Get clean output with:
@@ -17,9 +24,9 @@ func NewHaltCommand() *cli.Command {
 	return &cli.Command{
 		Name: "build-halt",
 		Desc: "Halt or Stop Build that is currently running in the Build Machine (*Run in Build Machine) (Private)",
-		Argv: func() interface{} { return new(buildStatusT) },
+		Argv: func() interface{} { return new(buildHaltT) },
 		Fn: func(ctx *cli.Context) error {
-			_ = ctx.Argv().(*buildStatusT)
+			argv := ctx.Argv().(*buildHaltT)
 
 			conn, err := net.Dial("tcp", "127.0.0.1:1695")
 			if err != nil {
@@ -31,14 +38,20 @@ func NewHaltCommand() *cli.Command {
 				return err
 			}
 
-			// Request Status from Server
-			_, err = conn.Write([]byte("quit"))
+			// Ask the build to stop, the server is kept only
+			// if asked to.
+			request := fmt.Sprintf("quit %d", argv.Grace)
+			if argv.KeepServer {
+				request += " keep"
+			}
+			_, err = conn.Write([]byte(request))
 			if err != nil {
 				return err
 			}
 
-			recvBuf := make([]byte, 1024)
-			_, err = conn.Read(recvBuf[:])
+			// The server closes the connection once the
+			// whole status is sent.
+			recvBuf, err := io.ReadAll(conn)
 			if err != nil {
 				return err
 			}
@@ -48,3 +61,29 @@ func NewHaltCommand() *cli.Command {
 		},
 	}
 }
+
+// The terminal running the current step, which is stopped when the
+// build is halted.
+func (state *statusT) setTerminal(term *Terminal) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.terminal = term
+}
+
+// Stops the running step, the build sees state.quitting() once the
+// step is stopped.
+func (state *statusT) halt(grace time.Duration) {
+	state.mutex.Lock()
+	term := state.terminal
+	state.mutex.Unlock()
+
+	if term == nil {
+		return
+	}
+
+	err := term.HaltTerminal(grace)
+	if err != nil {
+		fmt.Printf("Cannot Halt the Running Step (%s)\n", err.Error())
+	}
+}
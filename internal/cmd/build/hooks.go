@@ -0,0 +1,163 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/antony-jr/ham/internal/core"
+)
+
+const (
+	HOOK_RUNNING   = "running"
+	HOOK_DONE      = "done"
+	HOOK_FAILED    = "failed"
+	HOOK_TIMED_OUT = "timed_out"
+)
+
+// A hook of the recipe that ran or is running, Elapsed is in
+// seconds.
+type hookStatusT struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Elapsed int64  `json:"elapsed"`
+	Error   string `json:"error,omitempty"`
+
+	started time.Time
+}
+
+// Runs the hooks for how the build ended, result is one of
+// successful, failed or cancelled and message is the error of the
+// build if any. always runs after the others even if they fail.
+func (state *statusT) runHooks(hooks *core.HAMHooks, result string, message string) {
+	if hooks == nil {
+		return
+	}
+
+	env := []string{
+		"HAM_BUILD_RESULT=" + result,
+		"HAM_BUILD_ERROR=" + message,
+	}
+
+	switch result {
+	case "failed":
+		state.runHook("on_failure", hooks.OnFailure, hooks.Timeout(), env)
+	case "cancelled":
+		state.runHook("on_cancel", hooks.OnCancel, hooks.Timeout(), env)
+	}
+	state.runHook("always", hooks.Always, hooks.Timeout(), env)
+}
+
+// Runs the commands of a hook in /ham-build, one after the other in
+// a terminal of it's own. A hook that fails or hangs is only logged,
+// it's stopped after the timeout so the server is still destroyed.
+func (state *statusT) runHook(name string, commands []string, timeout time.Duration, env []string) {
+	if len(commands) == 0 {
+		return
+	}
+
+	title := "Running " + name + " Hook"
+	state.setTitle(title)
+	fmt.Println(title)
+	buildLog.mark(title)
+	index := state.startHook(name)
+
+	err := state.execHook(name, commands, timeout, env)
+	state.finishHook(index, err)
+	if err != nil {
+		fmt.Printf("%s Hook Failed (%s)\n", name, err.Error())
+	}
+}
+
+func (state *statusT) execHook(name string, commands []string, timeout time.Duration, env []string) error {
+	term, err := state.newTerminal("hook-" + name)
+	if err != nil {
+		return err
+	}
+	defer term.CloseTerminal()
+
+	setup := []string{"mkdir -p /ham-build", "cd /ham-build"}
+	for _, variable := range env {
+		parts := strings.SplitN(variable, "=", 2)
+		setup = append(setup, fmt.Sprintf("export %s='%s'", parts[0], strings.ReplaceAll(parts[1], "'", "'\\''")))
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := term.ExecTerminal(-1, strings.Join(setup, " && "))
+		if err == nil {
+			err = term.WaitTerminal(-1)
+		}
+
+		for index, cmd := range commands {
+			if err != nil {
+				break
+			}
+
+			err = term.ExecTerminal(index, cmd)
+			if err == nil {
+				err = term.WaitTerminal(index)
+			}
+		}
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-time.After(timeout):
+		_ = term.HaltTerminal(time.Second * time.Duration(10))
+		return errTimedOut{timeout}
+	}
+}
+
+type errTimedOut struct {
+	timeout time.Duration
+}
+
+func (e errTimedOut) Error() string {
+	return fmt.Sprintf("Timed Out after %s", e.timeout)
+}
+
+func (state *statusT) startHook(name string) int {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.Hooks = append(state.Hooks, hookStatusT{
+		Name:    name,
+		Status:  HOOK_RUNNING,
+		started: time.Now(),
+	})
+	return len(state.Hooks) - 1
+}
+
+func (state *statusT) finishHook(index int, err error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	hook := &state.Hooks[index]
+	hook.Status = HOOK_DONE
+	hook.Elapsed = int64(time.Since(hook.started).Seconds())
+	if err != nil {
+		hook.Status = HOOK_FAILED
+		if _, ok := err.(errTimedOut); ok {
+			hook.Status = HOOK_TIMED_OUT
+		}
+		hook.Error = err.Error()
+	}
+}
+
+// Returns the hooks as of now.
+func (state *statusT) hooks() []hookStatusT {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	hooks := make([]hookStatusT, len(state.Hooks))
+	copy(hooks, state.Hooks)
+	for i := range hooks {
+		if hooks[i].Status == HOOK_RUNNING {
+			hooks[i].Elapsed = int64(time.Since(hooks[i].started).Seconds())
+		}
+	}
+	return hooks
+}
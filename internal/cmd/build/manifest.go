@@ -0,0 +1,277 @@
+package build
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+
+	"github.com/antony-jr/ham/internal/core"
+)
+
+// Set by main, so the manifest tells which ham built it.
+var AppVersion = "Unknown"
+var GitCommit = "Unknown"
+
+// Where ham get puts the identity of the recipe on the build server.
+const RECIPE_IDENTITY_PATH = "/ham-files/recipe.json"
+
+// How deep in /ham-build repo checkouts are looked for.
+const REPO_SEARCH_DEPTH = 3
+
+// The manifest of a variant as it was written.
+type builtManifestT struct {
+	variant string
+	source  []byte
+}
+
+func (state *statusT) addManifest(variant string, source []byte) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.manifests = append(state.manifests, builtManifestT{
+		variant: variant,
+		source:  source,
+	})
+}
+
+// Returns the manifests written after the first from.
+func (state *statusT) manifestsFrom(from int) []builtManifestT {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if from >= len(state.manifests) {
+		return nil
+	}
+	return append([]builtManifestT{}, state.manifests[from:]...)
+}
+
+// Returns the part of the manifest which is the same for every
+// variant built on the server.
+func newManifest(client *hcloud.Client, serverName string, hf *core.HAMFile) core.BuildManifest {
+	manifest := core.BuildManifest{
+		Recipe: core.ManifestRecipe{
+			Title:     hf.Title,
+			Version:   hf.Version,
+			SHA256Sum: hf.SHA256Sum,
+		},
+		Ham: core.ManifestHam{
+			Version:   AppVersion,
+			GitCommit: GitCommit,
+		},
+		Server: core.ManifestServer{
+			Name: serverName,
+		},
+	}
+
+	if hf.Container != nil {
+		manifest.Container = &core.ManifestContainer{
+			Image:      hf.Container.Image,
+			Dockerfile: hf.Container.Dockerfile,
+		}
+	}
+
+	source, err := os.ReadFile(RECIPE_IDENTITY_PATH)
+	if err == nil {
+		id := core.RecipeIdentity{}
+		if json.Unmarshal(source, &id) == nil {
+			manifest.Recipe.TreeSHA256Sum = id.TreeSHA256Sum
+			manifest.Recipe.GitCommit = id.GitCommit
+		}
+	}
+
+	server, _, err := client.Server.GetByName(context.Background(), serverName)
+	if err == nil && server != nil {
+		manifest.Server.Type = server.ServerType.Name
+		manifest.Server.Location = server.Datacenter.Location.Name
+		if server.Image != nil {
+			manifest.Server.Image = server.Image.Name
+		}
+	}
+	return manifest
+}
+
+// Writes the manifest of the variant to it's output, after it's
+// steps and before the post build so the post build can upload it
+// with the rest of the output.
+func writeManifest(manifest core.BuildManifest, status *statusT, variant *core.HAMVariant, vars map[string]string) (string, error) {
+	manifest.Variant = variant.Name
+	if len(variant.Name) != 0 {
+		manifest.Matrix = variant.Values
+	}
+	manifest.Server.Ubuntu = ubuntuRelease()
+	manifest.Apt = installedPackages(status.container)
+
+	// The checkouts in /ham-build are the same in the container.
+	manifest.RepoManifests = repoManifests("/ham-build")
+
+	if status.container != nil && manifest.Container != nil {
+		container := *manifest.Container
+		container.Runtime = status.container.runtime.Name()
+		id, digest, err := status.container.runtime.ImageID(status.container.image)
+		if err != nil {
+			fmt.Printf("Cannot Get Container Image ID (%s)\n", err.Error())
+		}
+		container.ImageID, container.Digest = id, digest
+		manifest.Container = &container
+	}
+	manifest.Env = envNames(variant, vars)
+
+	steps, _, _, _ := status.progress()
+	manifest.Steps = make([]core.ManifestStep, len(steps))
+	for i, step := range steps {
+		manifest.Steps[i] = core.ManifestStep{
+			Name:    step.Name,
+			Status:  step.Status,
+			Seconds: step.Elapsed,
+		}
+	}
+
+	path := core.ManifestPath(variant.Name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return path, err
+	}
+
+	manifest.Outputs, err = hashOutputs(filepath.Dir(path))
+	if err != nil {
+		return path, err
+	}
+	manifest.Finished = time.Now().UTC()
+
+	source, err := json.MarshalIndent(manifest, "", "   ")
+	if err != nil {
+		return path, err
+	}
+
+	err = os.WriteFile(path, source, 0644)
+	if err != nil {
+		return path, err
+	}
+	status.addManifest(variant.Name, source)
+	return path, nil
+}
+
+// Returns the version of every installed package, of the container
+// if the steps run in one.
+func installedPackages(container *buildContainer) map[string]string {
+	packages := map[string]string{}
+	args := []string{"dpkg-query", "-W", "-f", "${Package}=${Version}\n"}
+
+	var out []byte
+	var err error
+	if container != nil {
+		out, err = container.runtime.Exec(container.name, args...)
+	} else {
+		out, err = exec.Command(args[0], args[1:]...).Output()
+	}
+	if err != nil {
+		// Images not based on Debian have no dpkg.
+		fmt.Printf("Cannot List Installed Packages (%s)\n", err.Error())
+		return packages
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		name, version, found := strings.Cut(line, "=")
+		if found {
+			packages[name] = version
+		}
+	}
+	return packages
+}
+
+// Returns repo manifest -r of every repo checkout in the directory,
+// with the exact commit of every project.
+func repoManifests(root string) map[string]string {
+	manifests := map[string]string{}
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		if d.Name() == ".repo" {
+			cmd := exec.Command("repo", "manifest", "-r")
+			cmd.Dir = filepath.Dir(path)
+			out, err := cmd.Output()
+			if err != nil {
+				fmt.Printf("Cannot Get Repo Manifest of %s (%s)\n", cmd.Dir, err.Error())
+			} else {
+				manifests[cmd.Dir] = string(out)
+			}
+			return fs.SkipDir
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		if rel != "." && strings.Count(rel, string(os.PathSeparator)) >= REPO_SEARCH_DEPTH-1 {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	return manifests
+}
+
+// Returns the names of the environmental variables the variant is
+// built with, without their values.
+func envNames(variant *core.HAMVariant, vars map[string]string) []string {
+	seen := map[string]bool{}
+	for name := range vars {
+		seen[core.ArgEnvName(name)] = true
+	}
+	for _, variable := range variant.Env() {
+		name, _, _ := strings.Cut(variable, "=")
+		seen[name] = true
+	}
+	for _, step := range variant.Build {
+		for name := range step.Env {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the SHA256 sum of every file in the output, the manifest
+// of the output is left out.
+func hashOutputs(dir string) (map[string]string, error) {
+	sums := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || d.Name() == core.MANIFEST_FILE_NAME {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		hasher := sha256.New()
+		_, err = io.Copy(hasher, file)
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+		sums[rel] = fmt.Sprintf("%x", hasher.Sum(nil))
+		return nil
+	})
+	return sums, err
+}
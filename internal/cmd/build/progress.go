@@ -0,0 +1,202 @@
+package build
+
+import (
+	"time"
+
+	"github.com/antony-jr/ham/internal/core"
+)
+
+const (
+	STEP_PENDING = "pending"
+	STEP_RUNNING = "running"
+	STEP_DONE    = "done"
+	STEP_FAILED  = "failed"
+	STEP_SKIPPED = "skipped"
+)
+
+// A step of the variant being built, times are in seconds. ETA is
+// -1 when it's not known, which is when the step was never built
+// before.
+type stepStatusT struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Weight  float64 `json:"weight"`
+	Elapsed int64   `json:"elapsed"`
+	ETA     int64   `json:"eta"`
+
+	// What the command of the step says about it's progress, like
+	// the lines Ninja prints.
+	Progress *core.StepProgress `json:"progress,omitempty"`
+
+	// Seconds the step took in previous builds, 0 if unknown.
+	expected      int64
+	started       time.Time
+	progressSince time.Time
+}
+
+// Sets the steps of the variant that is built next.
+func (state *statusT) setSteps(steps []core.HAMBuildStep, durations core.StepDurations) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	weights := core.StepWeights(steps, durations)
+	state.Steps = make([]stepStatusT, len(steps))
+	for i, step := range steps {
+		state.Steps[i] = stepStatusT{
+			Name:     step.Title,
+			Status:   STEP_PENDING,
+			Weight:   weights[i],
+			ETA:      -1,
+			expected: durations[step.Title],
+		}
+	}
+}
+
+func (state *statusT) startStep(index int) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if state.Started.IsZero() {
+		state.Started = time.Now()
+	}
+	state.Steps[index].Status = STEP_RUNNING
+	state.Steps[index].started = time.Now()
+}
+
+func (state *statusT) finishStep(index int, err error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	step := &state.Steps[index]
+	step.Status = STEP_DONE
+	if err != nil {
+		step.Status = STEP_FAILED
+	}
+	step.Elapsed = int64(time.Since(step.started).Seconds())
+	step.ETA = 0
+}
+
+// The if of the step was false, it counts as done.
+func (state *statusT) skipStep(index int) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if state.Started.IsZero() {
+		state.Started = time.Now()
+	}
+	state.Steps[index].Status = STEP_SKIPPED
+	state.Steps[index].ETA = 0
+}
+
+// Sets the progress of the running step from it's output. The
+// progress starts over when it goes back, Soong runs Ninja more than
+// once in a single build.
+func (state *statusT) setStepProgress(progress core.StepProgress) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	for i := range state.Steps {
+		step := &state.Steps[i]
+		if step.Status != STEP_RUNNING {
+			continue
+		}
+
+		if step.Progress == nil || progress.Percent < step.Progress.Percent {
+			step.progressSince = time.Now()
+		}
+		step.Progress = &progress
+		return
+	}
+}
+
+// Returns the steps as of now, with how far the whole build is. A
+// running step counts by the progress in it's output or by how long
+// it took before, so a long step moves the percentage while it runs.
+func (state *statusT) progress() ([]stepStatusT, float64, int64, int64) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	steps := make([]stepStatusT, len(state.Steps))
+	copy(steps, state.Steps)
+
+	total, done := 0.0, 0.0
+	remaining, all := int64(0), int64(0)
+	known, allKnown := true, true
+	for i := range steps {
+		step := &steps[i]
+		total += step.Weight
+		all += step.expected
+		if step.expected == 0 {
+			allKnown = false
+		}
+
+		switch step.Status {
+		case STEP_DONE, STEP_FAILED, STEP_SKIPPED:
+			done += step.Weight
+		case STEP_RUNNING:
+			step.Elapsed = int64(time.Since(step.started).Seconds())
+
+			// The output of the step tells best how far it is,
+			// otherwise it's guessed from the previous builds.
+			fraction := 0.0
+			if step.Progress != nil && step.Progress.Percent >= 1 {
+				fraction = step.Progress.Percent / 100
+				since := time.Since(step.progressSince).Seconds()
+				step.ETA = int64(since * (100 - step.Progress.Percent) / step.Progress.Percent)
+			} else if step.expected > 0 {
+				fraction = float64(step.Elapsed) / float64(step.expected)
+				step.ETA = step.expected - step.Elapsed
+				if step.ETA < 0 {
+					step.ETA = 0
+				}
+			}
+
+			if fraction > 0.99 {
+				fraction = 0.99
+			}
+			done += step.Weight * fraction
+
+			if step.ETA >= 0 {
+				remaining += step.ETA
+			} else {
+				known = false
+			}
+		case STEP_PENDING:
+			if step.expected > 0 {
+				step.ETA = step.expected
+				remaining += step.expected
+			} else {
+				known = false
+			}
+		}
+	}
+
+	fraction := 0.0
+	if total > 0 {
+		fraction = done / total
+	}
+
+	count := state.VariantCount
+	if count < 1 {
+		count = 1
+	}
+	overall := (float64(state.VariantIndex) + fraction) / float64(count)
+
+	elapsed := int64(0)
+	if !state.Started.IsZero() {
+		elapsed = int64(time.Since(state.Started).Seconds())
+	}
+
+	// Every step was built before, so the rest of the build takes
+	// as long as it did then. Otherwise the rest goes as fast as
+	// the build did so far.
+	eta := int64(-1)
+	rest := int64(count - state.VariantIndex - 1)
+	if known && len(steps) != 0 && (rest == 0 || allKnown) {
+		eta = remaining + rest*all
+	} else if overall >= 0.01 {
+		eta = int64(float64(elapsed) * (1 - overall) / overall)
+	}
+
+	return steps, fraction, elapsed, eta
+}
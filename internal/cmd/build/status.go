@@ -3,6 +3,7 @@ package build
 import (
 	"fmt"
 	"github.com/mkideal/cli"
+	"io"
 	"net"
 	"time"
 )
@@ -37,8 +38,9 @@ func NewStatusCommand() *cli.Command {
 				return err
 			}
 
-			recvBuf := make([]byte, 1024)
-			_, err = conn.Read(recvBuf[:])
+			// The server closes the connection once the
+			// whole status is sent.
+			recvBuf, err := io.ReadAll(conn)
 			if err != nil {
 				return err
 			}
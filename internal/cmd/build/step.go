@@ -0,0 +1,111 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/antony-jr/ham/internal/core"
+)
+
+// Returns the command written to the shared bash for the step. A
+// step with it's own working directory, env or shell runs in a
+// subshell, so it sees what the steps before it did but what it
+// does stays in there.
+func stepCommand(step core.HAMBuildStep) string {
+	if !step.Isolated() {
+		return step.Cmd
+	}
+
+	lines := []string{"("}
+	names := make([]string, 0, len(step.Env))
+	for name := range step.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, "export "+name+"="+doubleQuote(step.Env[name]))
+	}
+
+	// Relative directories are in /ham-build, wherever the steps
+	// before cd-ed into.
+	if len(step.WorkingDirectory) != 0 {
+		dir := step.WorkingDirectory
+		if !filepath.IsAbs(dir) && !strings.HasPrefix(dir, "$") && !strings.HasPrefix(dir, "~") {
+			dir = filepath.Join("/ham-build", dir)
+		}
+		lines = append(lines, "cd "+doubleQuote(dir))
+	}
+
+	cmd := strings.TrimSuffix(step.Cmd, "\n")
+	if step.Shell == core.STEP_SHELL_SH {
+		cmd = "sh -e -c '" + strings.ReplaceAll(cmd, "'", "'\\''") + "'"
+	}
+	lines = append(lines, cmd, ")")
+	return strings.Join(lines, "\n")
+}
+
+// Quotes the value so variables in it are still expanded by bash.
+func doubleQuote(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, "\"", "\\\"")
+	return "\"" + value + "\""
+}
+
+// Returns true if the step has to run, the condition sees the args
+// of the build, the matrix values of the variant and the env the
+// step would run with. The env is what the shell of the steps dumped
+// after the last step, so what the steps before exported is seen.
+func stepRuns(step core.HAMBuildStep, variant *core.HAMVariant, vars map[string]string, terminal *Terminal) bool {
+	condition, err := step.Condition()
+	if err != nil || condition == nil {
+		// The condition was checked when the recipe was read.
+		return true
+	}
+
+	shell := map[string]string{}
+	if terminal != nil {
+		shell, err = terminal.ShellEnv()
+		if err != nil {
+			fmt.Printf("Cannot Read the Env of the Shell for %s (%s)\n", step.Title, err.Error())
+		}
+	}
+
+	env := map[string]string{}
+	for _, variable := range variant.Env() {
+		parts := strings.SplitN(variable, "=", 2)
+		env[parts[0]] = parts[1]
+	}
+	for name, value := range vars {
+		env[core.ArgEnvName(name)] = value
+	}
+
+	// Like bash would expand them in the shell, not in ham.
+	lookup := func(name string) string {
+		if value, ok := shell[name]; ok {
+			return value
+		}
+		return env[name]
+	}
+
+	return condition.Eval(func(scope string, name string) string {
+		switch scope {
+		case "args":
+			if value, ok := vars[name]; ok {
+				return value
+			}
+			return vars[core.ArgEnvName(name)]
+		case "matrix":
+			return variant.Values[name]
+		}
+
+		// The env of the step wins over the shell, it's set for the
+		// step on top of it.
+		if value, ok := step.Env[name]; ok {
+			return os.Expand(value, lookup)
+		}
+		return lookup(name)
+	})
+}
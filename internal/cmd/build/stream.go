@@ -0,0 +1,206 @@
+package build
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antony-jr/ham/internal/helpers"
+)
+
+// Number of lines a client gets when it starts streaming, so it
+// has something to show right away.
+const STREAM_BACKLOG_LINES = 20
+
+// Fans out the output of the terminals to the clients streaming the
+// build, line by line.
+type logHub struct {
+	mutex        sync.Mutex
+	partial      string
+	partialSince time.Time
+	seq          int64
+	backlog      []logLineT
+	clients      map[chan logLineT]bool
+
+	// Called with every line, must not block.
+	onLine func(text string)
+}
+
+// Lines are numbered, so a client which connects again can tell
+// the ones it already has.
+type logLineT struct {
+	seq  int64
+	text string
+}
+
+var buildLog = &logHub{
+	clients: map[chan logLineT]bool{},
+}
+
+func (h *logHub) Write(p []byte) (int, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.partial) == 0 {
+		h.partialSince = time.Now()
+	}
+	h.partial += string(p)
+
+	for {
+		i := strings.IndexByte(h.partial, '\n')
+		if i < 0 {
+			break
+		}
+		h.publish(h.partial[:i])
+		h.partial = h.partial[i+1:]
+		h.partialSince = time.Now()
+	}
+	return len(p), nil
+}
+
+// Sends lines which did not end for a while, like a prompt or a
+// progress bar, so they are not held back.
+func (h *logHub) flushEvery(d time.Duration) {
+	for {
+		time.Sleep(d)
+
+		h.mutex.Lock()
+		if len(h.partial) != 0 && time.Since(h.partialSince) >= d {
+			h.publish(h.partial)
+			h.partial = ""
+		}
+		h.mutex.Unlock()
+	}
+}
+
+// Marks the start of a step in the log.
+func (h *logHub) mark(title string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.partial) != 0 {
+		h.publish(h.partial)
+		h.partial = ""
+	}
+	h.publish(helpers.LOG_STEP_MARKER + title)
+}
+
+// Must be called with the mutex locked.
+func (h *logHub) publish(text string) {
+	// A pty redraws the line after a \r, only the last one counts.
+	text = strings.TrimRight(text, "\r")
+	if i := strings.LastIndexByte(text, '\r'); i >= 0 {
+		text = text[i+1:]
+	}
+
+	if h.onLine != nil {
+		h.onLine(text)
+	}
+
+	h.seq++
+	line := logLineT{seq: h.seq, text: text}
+	h.backlog = append(h.backlog, line)
+	if len(h.backlog) > STREAM_BACKLOG_LINES {
+		h.backlog = h.backlog[len(h.backlog)-STREAM_BACKLOG_LINES:]
+	}
+
+	for client := range h.clients {
+		// A slow client misses lines instead of holding up
+		// the build.
+		select {
+		case client <- line:
+		default:
+		}
+	}
+}
+
+func (h *logHub) subscribe() (chan logLineT, []logLineT) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	client := make(chan logLineT, 256)
+	h.clients[client] = true
+	return client, append([]logLineT{}, h.backlog...)
+}
+
+func (h *logHub) unsubscribe(client chan logLineT) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.clients, client)
+}
+
+// A message pushed to a streaming client, one JSON object on each
+// line.
+type streamMessageT struct {
+	Type     string           `json:"type"`
+	Status   *statusResponseT `json:"status,omitempty"`
+	Log      string           `json:"log,omitempty"`
+	Seq      int64            `json:"seq,omitempty"`
+	Variant  string           `json:"variant,omitempty"`
+	Manifest json.RawMessage  `json:"manifest,omitempty"`
+}
+
+// Pushes the status of the build when it changes, every line of the
+// log and every manifest written until the client goes away. The status is sent again every
+// few seconds even without a change, so the client knows the
+// connection is alive.
+func streamBuild(state *statusT, conn net.Conn) {
+	defer conn.Close()
+
+	lines, backlog := buildLog.subscribe()
+	defer buildLog.unsubscribe(lines)
+
+	encoder := json.NewEncoder(conn)
+	send := func(msg streamMessageT) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(time.Second * time.Duration(30)))
+		return encoder.Encode(msg) == nil
+	}
+
+	for _, line := range backlog {
+		if !send(streamMessageT{Type: "log", Log: line.text, Seq: line.seq}) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(time.Millisecond * time.Duration(500))
+	defer ticker.Stop()
+
+	last := ""
+	lastSent := time.Time{}
+	manifests := 0
+	for {
+		select {
+		case line := <-lines:
+			if !send(streamMessageT{Type: "log", Log: line.text, Seq: line.seq}) {
+				return
+			}
+		case <-ticker.C:
+			// Before the status, so the client has the manifest
+			// when it sees the build finished.
+			for _, manifest := range state.manifestsFrom(manifests) {
+				if !send(streamMessageT{Type: "manifest", Variant: manifest.variant, Manifest: manifest.source}) {
+					return
+				}
+				manifests++
+			}
+
+			resp := statusResponse(state)
+			out, err := json.Marshal(resp)
+			if err != nil {
+				return
+			}
+
+			if string(out) == last && time.Since(lastSent) < time.Second*time.Duration(10) {
+				continue
+			}
+			if !send(streamMessageT{Type: "status", Status: &resp}) {
+				return
+			}
+			last = string(out)
+			lastSent = time.Now()
+		}
+	}
+}
@@ -9,30 +9,64 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
 )
 
+// Where the shell of a terminal writes how it's command went, it's
+// mounted into the container of the recipe so shells in there can
+// write it too.
+const TERMINAL_STATUS_DIR = "/tmp/ham-terminal"
+
 type Terminal struct {
 	term  *os.File
 	index int
 	uid   string
+	pid   int
+
+	// The shell runs in the container of the recipe, the commands
+	// in it can't be signaled from here.
+	container bool
+
+	// Set once the terminal is halted, nothing waits for the
+	// command after that.
+	halted *atomic.Bool
 }
 
 func NewTerminal(UniqueID string) (Terminal, error) {
-	t := Terminal{}
+	return newTerminal(UniqueID, exec.Command("bash"), false)
+}
+
+// Returns a terminal with a bash in the container of the recipe.
+func NewContainerTerminal(UniqueID string, container *buildContainer) (Terminal, error) {
+	return newTerminal(UniqueID, container.shell(), true)
+}
+
+func newTerminal(UniqueID string, cmd *exec.Cmd, container bool) (Terminal, error) {
+	t := Terminal{
+		halted:    &atomic.Bool{},
+		container: container,
+	}
 
 	t.uid = UniqueID
 
-	cmd := exec.Command("bash")
+	err := os.MkdirAll(TERMINAL_STATUS_DIR, 0777)
+	if err != nil {
+		return t, err
+	}
+
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
 		return t, err
 	}
 	t.term = ptmx
+	t.pid = cmd.Process.Pid
 
-	file, err := os.Create(fmt.Sprintf("/tmp/%s.ham.command.status", UniqueID))
+	file, err := os.Create(t.statusPath())
 	if err != nil {
 		return t, err
 	}
@@ -43,8 +77,8 @@ func NewTerminal(UniqueID string) (Terminal, error) {
 	t.term.Write([]byte("set -e \n"))
 	t.term.Write([]byte("export HAM_CMD_INDEX=0 \n"))
 	// t.term.Write([]byte(fmt.Sprintf("trap \"echo $HAM_CMD_INDEX' failed' > /tmp/%s.ham.command.status\" ERR \n", UniqueID)))
-	t.term.Write([]byte(fmt.Sprintf("trap \"env | grep HAM_CMD_INDEX | cut -d'=' -f2 | sed 's/$/ failed/'|cat > /tmp/%s.ham.command.status\" ERR \n", UniqueID)))
-	t.term.Write([]byte(fmt.Sprintf("trap \"env | grep HAM_CMD_INDEX | cut -d'=' -f2 | sed 's/$/ failed/'|cat > /tmp/%s.ham.command.status\" EXIT \n", UniqueID)))
+	t.term.Write([]byte(fmt.Sprintf("trap \"env | grep HAM_CMD_INDEX | cut -d'=' -f2 | sed 's/$/ failed/'|cat > %s\" ERR \n", t.statusPath())))
+	t.term.Write([]byte(fmt.Sprintf("trap \"env | grep HAM_CMD_INDEX | cut -d'=' -f2 | sed 's/$/ failed/'|cat > %s\" EXIT \n", t.statusPath())))
 
 	// Copy pty stdout to a log file for debugging.
 	go func() {
@@ -53,7 +87,8 @@ func NewTerminal(UniqueID string) (Terminal, error) {
 		if err == nil {
 			size := int64(0)
 			for {
-				written, err := io.CopyN(logFile, t.term, 1024)
+				// The log is streamed to clients too.
+				written, err := io.CopyN(io.MultiWriter(logFile, buildLog), t.term, 1024)
 				if err != nil {
 					break
 				}
@@ -82,6 +117,10 @@ func (Term *Terminal) WaitTerminal(Index int) error {
 	for {
 		time.Sleep(1 * time.Second)
 
+		if Term.halted.Load() {
+			return errors.New(fmt.Sprintf("Halted at Entry %d", Index))
+		}
+
 		// Timeout if a we wait for a single command
 		// more than 8 hours.
 		now := time.Now().In(loc)
@@ -93,7 +132,7 @@ func (Term *Terminal) WaitTerminal(Index int) error {
 			return errors.New(fmt.Sprintf("Commad Timeout at Entry %d", Index))
 		}
 
-		status, err := ioutil.ReadFile(fmt.Sprintf("/tmp/%s.ham.command.status", Term.uid))
+		status, err := ioutil.ReadFile(Term.statusPath())
 		if err != nil {
 			return err
 		}
@@ -118,7 +157,7 @@ func (Term *Terminal) WaitTerminal(Index int) error {
 }
 
 func (Term *Terminal) ExecTerminal(Index int, Command string) error {
-	status, err := ioutil.ReadFile(fmt.Sprintf("/tmp/%s.ham.command.status", Term.uid))
+	status, err := ioutil.ReadFile(Term.statusPath())
 	if err != nil {
 		return err
 	}
@@ -145,10 +184,12 @@ func (Term *Terminal) ExecTerminal(Index int, Command string) error {
 	Term.term.Write([]byte(fmt.Sprintf("export HAM_CMD_INDEX=%d \n", Index)))
 
 	Command = strings.TrimSuffix(Command, "\n")
-	Term.term.Write([]byte(fmt.Sprintf("%s ; echo $HAM_CMD_INDEX' success' > /tmp/%s.ham.command.status\n", Command, Term.uid)))
+	// The env is dumped before the status, so it's there once the
+	// command is seen as done.
+	Term.term.Write([]byte(fmt.Sprintf("%s ; env -0 > %s ; echo $HAM_CMD_INDEX' success' > %s\n", Command, Term.envPath(), Term.statusPath())))
 
 	time.Sleep(1 * time.Second)
-	status, err = ioutil.ReadFile(fmt.Sprintf("/tmp/%s.ham.command.status", Term.uid))
+	status, err = ioutil.ReadFile(Term.statusPath())
 	if err != nil {
 		return err
 	}
@@ -169,8 +210,105 @@ func (Term *Terminal) ExecTerminal(Index int, Command string) error {
 	return nil
 }
 
+// Stops the command running in the terminal. Bash runs every command
+// in it's own process group, which gets SIGINT first and SIGKILL if
+// it's still there after the grace period.
+func (Term *Terminal) HaltTerminal(grace time.Duration) error {
+	Term.halted.Store(true)
+
+	if Term.container {
+		return Term.haltContainerTerminal(grace)
+	}
+
+	conn, err := Term.term.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	pgrp := 0
+	err = conn.Control(func(fd uintptr) {
+		pgrp, err = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Bash itself is in the foreground when nothing runs.
+	if pgrp <= 0 || pgrp == Term.pid {
+		return nil
+	}
+
+	err = syscall.Kill(-pgrp, syscall.SIGINT)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		if syscall.Kill(-pgrp, 0) != nil {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	fmt.Printf("Killing Process Group %d after %s\n", pgrp, grace)
+	return syscall.Kill(-pgrp, syscall.SIGKILL)
+}
+
+// The command in a container is stopped with a Ctrl+C through the
+// pty, the bash in there fails the command and exits. If it does not
+// the shell is killed, which hangs up the command.
+func (Term *Terminal) haltContainerTerminal(grace time.Duration) error {
+	_, err := Term.term.Write([]byte{0x03})
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		status, err := ioutil.ReadFile(Term.statusPath())
+		if err == nil && strings.Contains(string(status), "failed") {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	fmt.Printf("Killing Container Shell %d after %s\n", Term.pid, grace)
+	return syscall.Kill(Term.pid, syscall.SIGKILL)
+}
+
+// Returns the exported variables of the shell as they were after the
+// last command which went through, so what the commands before
+// exported is seen too.
+func (Term *Terminal) ShellEnv() (map[string]string, error) {
+	vars := map[string]string{}
+	source, err := os.ReadFile(Term.envPath())
+	if err != nil {
+		return vars, err
+	}
+
+	for _, variable := range strings.Split(string(source), "\x00") {
+		name, value, found := strings.Cut(variable, "=")
+		if found {
+			vars[name] = value
+		}
+	}
+	return vars, nil
+}
+
+func (Term *Terminal) statusPath() string {
+	return fmt.Sprintf("%s/%s.ham.command.status", TERMINAL_STATUS_DIR, Term.uid)
+}
+
+// The shell dumps it's env in here after every command, next to the
+// status.
+func (Term *Terminal) envPath() string {
+	return fmt.Sprintf("%s/%s.ham.env", TERMINAL_STATUS_DIR, Term.uid)
+}
+
 func (Term *Terminal) CloseTerminal() error {
-	err := os.Remove(fmt.Sprintf("/tmp/%s.ham.command.status", Term.uid))
+	os.Remove(Term.envPath())
+	err := os.Remove(Term.statusPath())
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,323 @@
+package get
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/antony-jr/ham/internal/helpers"
+)
+
+// The status of a build as given by ham build-status.
+type buildStatusT struct {
+	Error      bool    `json:"error"`
+	Message    string  `json:"message"`
+	Status     string  `json:"status"`
+	Progress   string  `json:"progress"`
+	Percentage float64 `json:"percentage"`
+	Elapsed    int64   `json:"elapsed"`
+	ETA        int64   `json:"eta"`
+	Steps      []struct {
+		Name     string `json:"name"`
+		Status   string `json:"status"`
+		Elapsed  int64  `json:"elapsed"`
+		Progress *struct {
+			Percent float64 `json:"percent"`
+			Done    int64   `json:"done"`
+			Total   int64   `json:"total"`
+		} `json:"progress"`
+	} `json:"steps"`
+	Variants []struct {
+		Name       string  `json:"name"`
+		Status     string  `json:"status"`
+		Percentage float64 `json:"percentage"`
+	} `json:"variants"`
+	Hooks []struct {
+		Name    string `json:"name"`
+		Status  string `json:"status"`
+		Elapsed int64  `json:"elapsed"`
+		Error   string `json:"error"`
+	} `json:"hooks"`
+}
+
+// Prints a step that is done, as a info event in CI mode.
+func printDone(format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	if helpers.IsCI() {
+		helpers.CIEvent("info", message, nil)
+		return
+	}
+	fmt.Printf(" %s %s\n", checkMark, message)
+}
+
+// Prints a line, as a info event in CI mode.
+func printInfo(format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	if helpers.IsCI() {
+		helpers.CIEvent("info", strings.TrimSpace(message), nil)
+		return
+	}
+	fmt.Println(message)
+}
+
+// Prints something that went wrong but is not fatal, as a warning
+// event in CI mode.
+func printFailed(format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	if helpers.IsCI() {
+		helpers.CIEvent("warning", message, nil)
+		return
+	}
+	fmt.Printf(" %s %s\n", crossMark, message)
+}
+
+// Returns the exit code of ham get for the way tracking ended.
+func (code SSHShellCode) ExitCode() int {
+	switch code {
+	case SSH_SHELL_CANNOT_GET_CLIENT:
+		return helpers.EXIT_CANNOT_GET_CLIENT
+	case SSH_SHELL_CANNOT_GET_SESSION:
+		return helpers.EXIT_CANNOT_GET_SESSION
+	case SSH_SHELL_CANNOT_CONNECT:
+		return helpers.EXIT_CANNOT_CONNECT
+	case SSH_SHELL_MALFORMED_JSON:
+		return helpers.EXIT_MALFORMED_JSON
+	case SSH_SHELL_HAM_STATUS_ERRORED:
+		return helpers.EXIT_BUILD_FAILED
+	}
+	return 1
+}
+
+// Questions can't be asked without a terminal, optional ones are
+// left to their default.
+func ciQuestionError(resp *ResponseT, question string) error {
+	if !resp.required {
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Cannot Ask '%s' without a Terminal, Answer it in the Answers File (--answers).", question))
+}
+
+// Parses the build status pushed by the daemon, the code tells how
+// tracking has to end if it has to.
+func parseBuildStatus(out string) (buildStatusT, SSHShellCode, error) {
+	status := buildStatusT{}
+
+	err := json.Unmarshal([]byte(out), &status)
+	if err != nil {
+		return status, SSH_SHELL_MALFORMED_JSON, err
+	}
+
+	if status.Error {
+		return status, SSH_SHELL_HAM_STATUS_ERRORED, nil
+	}
+	return status, SSH_SHELL_NO_ERROR, nil
+}
+
+// Prints the progress of the build whenever it changes.
+type progressPrinter struct {
+	target   string
+	last     string
+	variants map[string]string
+	steps    map[string]string
+	hooks    map[string]string
+}
+
+func (p *progressPrinter) print(status buildStatusT) {
+	fields := map[string]interface{}{
+		"percentage": int(status.Percentage),
+	}
+	if len(p.target) != 0 {
+		fields["target"] = p.target
+	}
+	if status.Elapsed > 0 {
+		fields["elapsed"] = status.Elapsed
+	}
+	if status.ETA > 0 {
+		fields["eta"] = status.ETA
+	}
+
+	// The progress the running step prints, like Ninja does.
+	stepPercent := -1
+	for _, step := range status.Steps {
+		if step.Status == "running" && step.Progress != nil {
+			stepPercent = int(step.Progress.Percent)
+			fields["step_percentage"] = stepPercent
+			if step.Progress.Total > 0 {
+				fields["step_done"] = step.Progress.Done
+				fields["step_total"] = step.Progress.Total
+			}
+		}
+	}
+
+	current := fmt.Sprintf("%s %d %d", status.Progress, int(status.Percentage), stepPercent)
+	if current != p.last {
+		p.last = current
+		helpers.CIEvent("progress", status.Progress, fields)
+	}
+
+	if p.steps == nil {
+		p.steps = map[string]string{}
+	}
+	for _, step := range status.Steps {
+		if step.Status == "pending" || p.steps[step.Name] == step.Status {
+			continue
+		}
+		p.steps[step.Name] = step.Status
+
+		stepFields := map[string]interface{}{
+			"status":  step.Status,
+			"elapsed": step.Elapsed,
+		}
+		if len(p.target) != 0 {
+			stepFields["target"] = p.target
+		}
+		helpers.CIEvent("build_step", step.Name, stepFields)
+	}
+
+	if p.variants == nil {
+		p.variants = map[string]string{}
+	}
+	for _, variant := range status.Variants {
+		if p.variants[variant.Name] == variant.Status {
+			continue
+		}
+		p.variants[variant.Name] = variant.Status
+
+		variantFields := map[string]interface{}{
+			"variant": variant.Name,
+			"status":  variant.Status,
+		}
+		if len(p.target) != 0 {
+			variantFields["target"] = p.target
+		}
+		helpers.CIEvent("variant", "", variantFields)
+	}
+
+	if p.hooks == nil {
+		p.hooks = map[string]string{}
+	}
+	for _, hook := range status.Hooks {
+		if p.hooks[hook.Name] == hook.Status {
+			continue
+		}
+		p.hooks[hook.Name] = hook.Status
+
+		hookFields := map[string]interface{}{
+			"status":  hook.Status,
+			"elapsed": hook.Elapsed,
+		}
+		if len(hook.Error) != 0 {
+			hookFields["error"] = hook.Error
+		}
+		if len(p.target) != 0 {
+			hookFields["target"] = p.target
+		}
+		helpers.CIEvent("hook", hook.Name, hookFields)
+	}
+}
+
+// Tracks the remote build with plain or JSON lines, the log of
+// the build is printed as it comes.
+func runProgressCI(stream *BuildStream) error {
+	helpers.CIEvent("info", "Tracking Remote Build...", nil)
+
+	printer := &progressPrinter{}
+	for {
+		select {
+		case <-stream.Done:
+			if err := stream.Err(); err != nil {
+				helpers.CIEvent("warning", "Cannot Get Progress from Remote. ("+err.Error()+")", nil)
+			}
+			return nil
+		case line := <-stream.Log:
+			if helpers.CIMode() == helpers.CI_JSON {
+				helpers.CIEvent("log", "", map[string]interface{}{"data": line})
+			} else {
+				fmt.Println(line)
+			}
+		case out := <-stream.Status:
+			status, code, err := parseBuildStatus(out)
+			if code == SSH_SHELL_NO_ERROR {
+				printer.print(status)
+				if status.Status == "Cancelled" {
+					helpers.CIEvent("warning", "Remote Build Cancelled", nil)
+				} else if int(status.Percentage) != 100 {
+					continue
+				} else {
+					helpers.CIEvent("info", "Remote Build Completed", nil)
+				}
+			} else if code == SSH_SHELL_HAM_STATUS_ERRORED {
+				helpers.CIEvent("error", status.Message, nil)
+			} else if err != nil {
+				helpers.CIEvent("warning", "Cannot Get Progress from Remote. ("+err.Error()+")", nil)
+			}
+
+			stream.SetCode(code)
+			return nil
+		}
+	}
+}
+
+// Tracks several remote builds at once with plain or JSON lines,
+// the logs are left out since they would be mixed up.
+func runMultiProgressCI(titles []string, streams []*BuildStream) error {
+	helpers.CIEvent("info", "Tracking Remote Builds...", nil)
+
+	printers := make([]*progressPrinter, len(titles))
+	done := make([]bool, len(titles))
+	for i, title := range titles {
+		printers[i] = &progressPrinter{target: title}
+	}
+
+	for {
+		finished := true
+		for _, d := range done {
+			finished = finished && d
+		}
+		if finished {
+			return nil
+		}
+
+		time.Sleep(time.Second * time.Duration(2))
+		for i, stream := range streams {
+			if done[i] {
+				continue
+			}
+
+			fields := map[string]interface{}{"target": titles[i]}
+			var out string
+			select {
+			case <-stream.Done:
+				if err := stream.Err(); err != nil {
+					helpers.CIEvent("warning", "Cannot Get Progress from Remote. ("+err.Error()+")", fields)
+				}
+				done[i] = true
+				continue
+			case out = <-stream.Status:
+			default:
+				continue
+			}
+
+			status, code, err := parseBuildStatus(out)
+			if code == SSH_SHELL_NO_ERROR {
+				printers[i].print(status)
+				if status.Status == "Cancelled" {
+					helpers.CIEvent("warning", "Remote Build Cancelled", fields)
+				} else if int(status.Percentage) != 100 {
+					continue
+				} else {
+					helpers.CIEvent("info", "Remote Build Completed", fields)
+				}
+			} else if code == SSH_SHELL_HAM_STATUS_ERRORED {
+				helpers.CIEvent("error", status.Message, fields)
+			} else if err != nil {
+				helpers.CIEvent("warning", "Cannot Get Progress from Remote. ("+err.Error()+")", fields)
+			}
+
+			stream.SetCode(code)
+			done[i] = true
+		}
+	}
+}
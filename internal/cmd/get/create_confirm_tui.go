@@ -1,9 +1,11 @@
 package get
 
 import (
+	"errors"
 	"fmt"
 	"io"
 
+	"github.com/antony-jr/ham/internal/helpers"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -103,6 +105,11 @@ func (m choiceModel) View() string {
 }
 
 func runConfirmCreateTeaProgram(confirm *bool) error {
+	if helpers.IsCI() {
+		*confirm = false
+		return errors.New("Cannot Confirm to Create a Server without a Terminal, Use --no-confirm.")
+	}
+
 	items := []list.Item{
 		item("No Don't Create a Server"),
 		item("Proceed and Create a New Server"),
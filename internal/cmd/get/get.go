@@ -2,16 +2,14 @@ package get
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/mkideal/cli"
 
 	"github.com/charmbracelet/lipgloss"
@@ -24,13 +22,30 @@ import (
 
 const (
 	HAM_LINUX_BINARY_URL string = "https://github.com/antony-jr/ham/releases/download/stable/ham-build-linux-amd64"
+
+	// Recipes with include or extends are built from this file.
+	RESOLVED_RECIPE_PATH = "/ham-files/ham.resolved.yml"
+
+	// How long the steps took in previous builds, to weigh them.
+	DURATIONS_PATH = "/ham-files/durations.json"
+
+	// The heads of the watched remotes the build is started with.
+	HEADS_PATH = "/ham-files/heads.json"
+)
+
+// Choices given to the user when the running build server was
+// started from a different recipe.
+const (
+	RECIPE_MISMATCH_ABORT   = "Abort"
+	RECIPE_MISMATCH_RESTART = "Destroy the Build Server and Start a New Build"
+	RECIPE_MISMATCH_ATTACH  = "Attach to the Running Build Anyway"
 )
 
 type getT struct {
 	cli.Helper
 
 	NoConfirm               bool   `cli:"n,no-confirm" usage:"Auto Confirm 'Yes' to all questions for the user. (Use with Caution)"`
-	Answers                 string `cli:"a,answers" usage:"Path to answers.json to Answer required Questions."`
+	Answers                 string `cli:"a,answers" usage:"Path to a Answers File (JSON or YAML) to Answer required Questions."`
 	KeepServer              bool   `cli:"k,keep-server" usage:"Don't Destroy the Remote Server on any error."`
 	KeepServerOnConnectFail bool   `cli:"s,keep-server-conn-fail" usage:"Don't Destroy the Remote Server even if we can't SSH into it."`
 	KeepServerOnTrackFail   bool   `cli:"t,keep-server-track-fail" usage:"Don't Destroy the Remote Server even if Tracking Fails."`
@@ -38,63 +53,14 @@ type getT struct {
 	TestingBinary           string `cli:"e,testing-binary" usage:"Path to ham-build binary to use in the Remote Server during Testing. (Developer)"`
 	TestingSSHIP            string `cli:"i,testing-ssh-ip" usage:"Run a Test Run without Creating Servers and Use the given IP as Build Server. (Developer)"`
 	Force                   bool   `cli:"f,force" usage:"Force start a build even if the recipe was built Already."`
-}
-
-func ParseGitRemoteString(remote string) (string, string) {
-	urlSlice := strings.Split(remote, "://")
-
-	if len(urlSlice) == 2 {
-		remote = urlSlice[1]
-	}
-
-	slice := strings.Split(remote, "/")
-
-	branchSlice := strings.Split(remote, ":")
-	branch := ""
-	url := ""
-
-	if len(branchSlice) == 2 {
-		url = branchSlice[0]
-		branch = branchSlice[1]
-	} else {
-		url = remote
-	}
-
-	if len(urlSlice) == 2 {
-		url = fmt.Sprintf("%s://%s", urlSlice[0], url)
-	}
-
-	if len(slice) != 2 {
-		return url, branch
-	}
-
-	user := slice[0]
-	userSlice := strings.Split(user, "@")
-
-	if len(userSlice) != 2 {
-		return url, branch
-	}
-
-	uname := userSlice[0]
-	host := userSlice[1]
-
-	if strings.ToLower(host) != "gh" {
-		return url, branch
-	}
-
-	repo := slice[1]
-	repoSlice := strings.Split(repo, ":")
-
-	if len(repoSlice) == 2 {
-		repo = repoSlice[0]
-	}
-
-	// Official HAM Recipes.
-	if uname == "~" {
-		return fmt.Sprintf("https://github.com/ham-community/%s", repo), branch
-	}
-
-	return fmt.Sprintf("https://github.com/%s/%s", uname, repo), branch
+	CloneOnServer           bool   `cli:"g,clone-on-server" usage:"Clone git recipes again at the Remote Server instead of Uploading the local Clone."`
+	Registry                string `cli:"r,registry" usage:"URL or Path of the Recipe Registry Index used to find Recipes by Name."`
+	Parallel                bool   `cli:"p,parallel" usage:"Build each Variant of the Recipe Matrix on it's own Server."`
+	Batch                   string `cli:"batch" usage:"Path to a File with a Recipe Location on each Line to Build along with the given Recipes."`
+	IfChanged               bool   `cli:"c,if-changed" usage:"Only Build if the Recipe or the Remotes it Watches Changed since the Last Successful Build."`
+	MaxServers              int    `cli:"m,max-servers" usage:"Maximum Number of Servers in the Hetzner Project, Builds which Exceed it are not Started. No Limit by Default."`
+	CI                      bool   `cli:"ci" usage:"Print Plain Lines instead of Spinners and Progress Bars, Used when there is no Terminal."`
+	Json                    bool   `cli:"json" usage:"Print a JSON Object on each Line for Scripts, Implies --ci."`
 }
 
 func NewCommand() *cli.Command {
@@ -102,7 +68,7 @@ func NewCommand() *cli.Command {
 		Name: "get",
 		Desc: "Get a build of AOSP from community recipe or locally using your Hetzner Cloud",
 		Text: `
-Syntax: ham get [RECIPE LOCATION]
+Syntax: ham get [RECIPE LOCATION]...
 
 Recipe from Ham Community:
    ham get ~@gh/enchilada_los18.1
@@ -113,26 +79,56 @@ Recipe from Github:
    ham get antony-jr@gh/enchilada_los18.1
    ham get antony-jr@gh/ecnhilada_los18.1:dev
 
+Recipe from Gitlab or Codeberg:
+   ham get user@gl/repo:branch
+   ham get user@cb/repo
+
+Pinned to a Tag or Commit:
+   ham get ~@gh/enchilada_los18.1@v1.0.0
+   ham get antony-jr@gh/enchilada_los18.1:dev@3f2a9c1
+
 Recipe from Git:
    ham get https://antonyjr.in/enchilada_los181.git
+   ham get git@github.com:user/private-recipe.git:main
+
+Private Recipes:
+   Set HAM_GIT_TOKEN (and optionally HAM_GIT_USERNAME) to clone
+   over HTTPS with a token, SSH remotes use your SSH agent.
 
 Local Recipe:
-   ham get ./examples/enchilada_los18.1`,
+   ham get ./examples/enchilada_los18.1
+
+Recipe from the Registry (See ham search):
+   ham get enchilada-los19.1
+
+Recipe with a Matrix, Every Variant on it's own Server:
+   ham get --parallel ./examples/oneplus_los19.1
+
+Only Build when the Watched Sources Changed:
+   ham get --if-changed ~@gh/enchilada_los18.1
+
+Several Recipes at Once, Each on it's own Server:
+   ham get ~@gh/enchilada_los18.1 ~@gh/fajita_los18.1
+   ham get --batch recipes.txt`,
 		Argv: func() interface{} { return new(getT) },
 		NumArg: func(n int) bool {
-			if n != 1 {
-				return false
-			}
 			return true
 		},
 		Fn: func(ctx *cli.Context) error {
 			argv := ctx.Argv().(*getT)
-			args := ctx.Args()
-			if len(args) != 1 {
-				return nil
+			helpers.SetCIMode(helpers.DetectCIMode(argv.CI, argv.Json))
+
+			recipe_srcs := ctx.Args()
+			if len(argv.Batch) != 0 {
+				batch, err := readBatchFile(argv.Batch)
+				if err != nil {
+					return err
+				}
+				recipe_srcs = append(recipe_srcs, batch...)
+			}
+			if len(recipe_srcs) == 0 {
+				return errors.New("No Recipe Given, See ham get --help.")
 			}
-			recipe_src := args[0]
-			dir := recipe_src
 			testingRun := len(argv.TestingSSHIP) != 0
 			tuiSpinnerMsg := NewTUISpinnerMessenger()
 			defer tuiSpinnerMsg.StopMessage()
@@ -154,80 +150,34 @@ Local Recipe:
 
 			banner.GetStartBanner()
 
-			tuiSpinnerMsg.ShowMessage(fmt.Sprintf("Parsing %s...", recipe_src))
-
-			//fmt.Printf(" %s Parsing %s...\n", checkMark, recipe_src)
-			remove := false
-			usedGit := false
-			gitUrl := ""
-			gitBranch := ""
-			if _, err := os.Stat(recipe_src); os.IsNotExist(err) {
-				// Recipe is not local, so use git to clone the
-				// the recipe requested by the user.
-				// banner.GetRecipeNotExistsBanner()
-				tuiSpinnerMsg.ShowMessage("Recipe Does not Exists, Cloning.. ")
-
-				// Parse the string
-				git_url, git_branch := ParseGitRemoteString(recipe_src)
-
-				gitUrl = git_url
-				gitBranch = git_branch
-
-				if git_branch == "" {
-					git_branch = "Default"
-				}
-
-				_ = tuiSpinnerMsg.StopMessage()
-
-				fmt.Printf(" %s Git URL: %s\n", checkMark, git_url)
-				fmt.Printf(" %s Git Branch: %s\n", checkMark, git_branch)
-
-				uniqueTempDir, err := os.MkdirTemp(os.TempDir(), "*-ham-recipe")
-				if err != nil {
-					return err
-				}
-				dir = uniqueTempDir
-				remove = true
-				usedGit = true
+			if testingRun {
+				printInfo(" ! RUNNING IN TESTING MODE ! ")
+			}
 
-				tuiSpinnerMsg.ShowMessage(fmt.Sprintf("Cloning Into %s...", dir))
+			type loadedRecipe struct {
+				source   string
+				hf       *core.HAMFile
+				recipe   *core.RecipeSource
+				recipeId core.RecipeIdentity
+			}
 
-				if gitBranch == "" {
-					_, err = git.PlainClone(dir, false, &git.CloneOptions{
-						URL: gitUrl,
-					})
-				} else {
-					_, err = git.PlainClone(dir, false, &git.CloneOptions{
-						URL:           gitUrl,
-						ReferenceName: plumbing.NewBranchReferenceName(gitBranch),
-					})
+			recipes := []loadedRecipe{}
+			for _, recipe_src := range recipe_srcs {
+				hf, recipe, recipeId, err := loadRecipe(recipe_src, argv.Registry, tuiSpinnerMsg)
+				if recipe != nil {
+					defer recipe.Remove()
 				}
-
 				if err != nil {
-					_ = os.RemoveAll(dir)
 					return err
 				}
 
-				_ = tuiSpinnerMsg.StopMessage()
-			}
-
-			if remove {
-				defer os.RemoveAll(dir)
-			}
-
-			if testingRun {
-				fmt.Printf(" ! RUNNING IN TESTING MODE ! \n")
-			}
-
-			// Parse recipe file for meta information
-			// and args information.
-			hf, err := core.NewHAMFile(dir)
-			if err != nil {
-				return err
+				recipes = append(recipes, loadedRecipe{
+					source:   recipe_src,
+					hf:       hf,
+					recipe:   recipe,
+					recipeId: recipeId,
+				})
 			}
-			serverName := helpers.ServerNameFromSHA256(hf.SHA256Sum)
-
-			banner.GetRecipeBanner(hf.Title, hf.Version, hf.SHA256Sum)
 
 			tuiSpinnerMsg.ShowMessage("Reading Configuration...")
 			config, err := core.GetConfiguration()
@@ -235,12 +185,12 @@ Local Recipe:
 				return err
 			}
 			_ = tuiSpinnerMsg.StopMessage()
-			fmt.Printf(" %s Read Configuration\n", checkMark)
+			printDone("Read Configuration")
 
 			tuiSpinnerMsg.ShowMessage("Connecting to Hetzner...")
 			client := hcloud.NewClient(hcloud.WithToken(config.APIKey))
 			_ = tuiSpinnerMsg.StopMessage()
-			fmt.Printf(" %s Connected with Hetzner Cloud API\n", checkMark)
+			printDone("Connected with Hetzner Cloud API")
 
 			tuiSpinnerMsg.ShowMessage("Checking SSH Keys... ")
 			sshkeys, err := client.SSHKey.All(
@@ -281,7 +231,7 @@ Local Recipe:
 				return nil
 			}
 
-			fmt.Printf(" %s Verified SSH Keys\n", checkMark)
+			printDone("Verified SSH Keys")
 
 			tuiSpinnerMsg.ShowMessage("Destroying Dead Servers...")
 			// Destroy all dead servers
@@ -303,118 +253,113 @@ Local Recipe:
 				return err
 			}
 
-			var currentBuildServer *hcloud.Server
-			serverRunning := false
-			for _, server := range servers {
-				if server.Name == serverName {
-					_ = tuiSpinnerMsg.StopMessage()
-					// Track status instead of creating a new one.
-					currentBuildServer = server
-					fmt.Printf(" %s Active Build Found\n", checkMark)
-					serverRunning = true
-					break
-				}
+			session := &getSession{
+				argv:       argv,
+				client:     client,
+				config:     config,
+				spinner:    tuiSpinnerMsg,
+				testingRun: testingRun,
+				labels:     ham_labels,
+				servers:    servers,
+				answers:    map[*core.HAMFile]answeredVars{},
+				args:       map[string]bool{},
 			}
+			defer session.cleanup()
 
-			previousBuildStatus := ""
-			tuiSpinnerMsg.ShowMessage("Checking Previous Builds...")
-			for key, status := range ham_labels {
-				if key == serverName && !argv.Force {
-					previousBuildStatus = status
-					break
+			for _, r := range recipes {
+				for _, arg := range r.hf.Args {
+					session.args[arg.ID] = true
 				}
 			}
-			_ = tuiSpinnerMsg.StopMessage()
-
-			fmt.Printf(" %s Checked Previous Builds\n", checkMark)
-
-			if previousBuildStatus != "" && !argv.Force {
-				if previousBuildStatus == "failed" ||
-					previousBuildStatus == "successful" {
-					estr := fmt.Sprintf("A %s build had run before with this recipe, Run with -f flag to force build.",
-						previousBuildStatus)
-					return errors.New(estr)
 
+			// A matrix is built on a single server one variant
+			// after the other unless asked to use a server for
+			// each variant.
+			targets := []*buildTarget{}
+			for _, r := range recipes {
+				if argv.Parallel && r.hf.HasMatrix() {
+					variants, err := r.hf.Variants()
+					if err != nil {
+						return err
+					}
+					for i := range variants {
+						targets = addBuildTarget(targets, newBuildTarget(r.source, r.hf, r.recipe, r.recipeId, &variants[i]))
+					}
+				} else {
+					targets = addBuildTarget(targets, newBuildTarget(r.source, r.hf, r.recipe, r.recipeId, nil))
 				}
 			}
 
-			// This is a safety net.
-			destroyServer := !argv.KeepServer
-			defer deferDeleteServer(client, &destroyServer, serverName)
-
-			// Hmm... My ISP and mostly a lot of dumb ISP's don't support IPv6
-			// and tunnel is a waste of time. Also IPv4 cost a little extra on
-			// hetzner. We have no choice but to use IPv4 to support all kinds
-			// of client devices even android phones.
-			// var ip6Addr string
-			var ipAddr string
-			if testingRun {
-				ipAddr = argv.TestingSSHIP
-			} else {
-				if currentBuildServer != nil {
-					ipAddr = fmt.Sprintf("%s:22", currentBuildServer.PublicNet.IPv4.IP.String())
-				} else {
-					ipAddr = "127.1:22"
-				}
+			if testingRun && len(targets) != 1 {
+				return errors.New("Testing Run can only Build a Single Recipe without --parallel.")
 			}
 
-			if !serverRunning && !testingRun {
-				// Create a new build server.
+			targets, err = session.checkWatched(targets)
+			if err != nil {
+				return err
+			}
+			if len(targets) == 0 {
+				return helpers.NewExitError(helpers.EXIT_NO_CHANGES, "No Changes since the Last Successful Build, Nothing to Build.")
+			}
 
-				// Before that we need to get variables from the user
-				// such as special files, env vars required for the
-				// build from the user. This might be crucial secrets
-				// so transport it with SSH to stay secure.
-				varsFilePath, fileUploads, err := askQuestions(&hf, serverName, argv.Answers, argv.NoConfirm)
+			for _, target := range targets {
+				err = session.findServer(target)
 				if err != nil {
 					return err
 				}
-				defer os.Remove(varsFilePath)
-
-				tuiSpinnerMsg.ShowMessage("Getting Server Information... ")
+			}
+			_ = tuiSpinnerMsg.StopMessage()
 
-				// Get Suitable Server and Price
-				price, serverType, err := GrossServerPriceForServerWithHighestPerformance(client)
+			tuiSpinnerMsg.ShowMessage("Checking Previous Builds...")
+			for _, target := range targets {
+				err = session.checkPreviousBuild(target)
 				if err != nil {
+					_ = tuiSpinnerMsg.StopMessage()
 					return err
 				}
-				_ = tuiSpinnerMsg.StopMessage()
-				banner.GetServerPriceInformationBanner(strings.ToUpper(serverType.Name), price)
+			}
+			_ = tuiSpinnerMsg.StopMessage()
 
-				confirmCreate := argv.NoConfirm
+			printDone("Checked Previous Builds")
 
-				if !argv.NoConfirm {
-					err = runConfirmCreateTeaProgram(&confirmCreate)
-					if err != nil {
-						return err
-					}
+			// This is a safety net. Only the servers created by us are
+			// destroyed on error (createServers arms them), a build
+			// we attached to is never destroyed because another
+			// recipe could not be started.
+			for _, target := range targets {
+				defer deferDeleteServer(client, &target.destroy, target.serverName)
+			}
+
+			// Hmm... My ISP and mostly a lot of dumb ISP's don't support IPv6
+			// and tunnel is a waste of time. Also IPv4 cost a little extra on
+			// hetzner. We have no choice but to use IPv4 to support all kinds
+			// of client devices even android phones.
+			toCreate := []*buildTarget{}
+			for _, target := range targets {
+				if !target.running && !testingRun {
+					toCreate = append(toCreate, target)
 				}
+			}
 
-				// Keep this condition simple since this is an important
-				// decision by the user.
-				if confirmCreate == false {
-					return errors.New("User Declined to Create a New Server.")
-				} else {
-					/* NOTE: Important Section. */
-					tuiSpinnerMsg.ShowMessage("Creating Server... ")
-					server, err := core.CreateServer(client, serverType, serverName)
+			if len(toCreate) != 0 {
+				for _, target := range toCreate {
+					_, _, err = session.askOnce(target)
 					if err != nil {
-						destroyServer = !argv.KeepServer
 						return err
 					}
-					currentBuildServer = server
-					ipAddr = fmt.Sprintf("%s:22", currentBuildServer.PublicNet.IPv4.IP.String())
-					_ = tuiSpinnerMsg.StopMessage()
-					fmt.Printf(" %s Created Server\n", checkMark)
 				}
 
-				volDevice, err := helpers.GetVolumeLinuxDeviceForServer(client, serverName)
+				err = session.checkServerLimit(len(toCreate))
+				if err != nil {
+					return err
+				}
+
+				err = session.confirmServers(len(toCreate))
 				if err != nil {
 					return err
 				}
-				fmt.Printf(" %s Volume Device: %s\n", checkMark, volDevice)
 
-				err = doInitialize(ipAddr, config.SSHPrivateKey, volDevice, varsFilePath, fileUploads, usedGit, gitUrl, gitBranch, dir, argv.TestingBinary)
+				err = session.createServers(toCreate)
 				if err != nil {
 					return err
 				}
@@ -424,263 +369,19 @@ Local Recipe:
 
 			// Check if build is running on the remote server
 			// if not then start it now.
-
-			{
-				tuiSpinnerMsg.ShowMessage("Checking Build Process... ")
-				sshClient, err := GetSSHClient(ipAddr, config.SSHPrivateKey)
-				tries := 0
-				for {
-					tries++
-					if err != nil {
-						if tries > 20 {
-							return err
-						}
-						time.Sleep(time.Second * time.Duration(2))
-						sshClient, err = GetSSHClient(ipAddr, config.SSHPrivateKey)
-						continue
-					}
-					break
-				}
-				tries = 0
-				defer sshClient.Close()
-
-				shell, err := GetSSHShell(sshClient)
-				for {
-					tries++
-					if err != nil {
-						if tries > 20 {
-							return err
-						}
-						time.Sleep(time.Second * time.Duration(2))
-						shell, err = GetSSHShell(sshClient)
-						continue
-					}
-					break
-				}
-				tries = 0
-
-				tryExec := func(cmd string) (string, error) {
-					out, err := shell.Exec(cmd)
-					try := 0
-					for {
-						try++
-						if err != nil {
-							if try > 20 {
-								return "", err
-							}
-							time.Sleep(time.Second * time.Duration(2))
-							out, err = shell.Exec(cmd)
-							continue
-						}
-						break
-					}
-					return out, err
-				}
-
-				processExists, _ := shell.Exec("ps -ef | grep \"[h]am build\"")
+			for _, target := range targets {
+				err = session.startBuild(target)
 				if err != nil {
 					return err
 				}
-
-				if strings.Contains(processExists, "ham build") {
-					_ = tuiSpinnerMsg.StopMessage()
-					fmt.Printf(" %s Build Process Running\n", checkMark)
-				} else {
-					keep := ""
-					if argv.KeepServer || argv.KeepServerOnBuildFail {
-						keep = "--keep-server"
-					}
-					buildCommand := fmt.Sprintf("ham build %s --sum %s --recipe /ham-recipe --vars /ham-files/vars.json",
-						keep,
-						hf.SHA256Sum)
-					// check if initialized first
-					out, _ := shell.Exec("ls /tmp/ | grep ham.init.finished")
-					if !strings.Contains(out, "ham.init.finished") {
-						_ = tuiSpinnerMsg.StopMessage()
-						fmt.Println(" Server is not Initialized Properly")
-						fmt.Println(" Please Answer All Questions to Initialize Properly")
-						varsFilePath, fileUploads, err := askQuestions(&hf, serverName, argv.Answers, argv.NoConfirm)
-						tries = 0
-						for {
-							tries++
-							if err != nil {
-								if tries > 4 {
-									return err
-								}
-								time.Sleep(time.Second * time.Duration(1))
-								varsFilePath, fileUploads, err = askQuestions(&hf, serverName, argv.Answers, argv.NoConfirm)
-								continue
-							}
-							break
-						}
-						tries = 0
-						defer os.Remove(varsFilePath)
-
-						volDevice, err := helpers.GetVolumeLinuxDeviceForServer(client, serverName)
-						if err != nil {
-							return err
-						}
-						fmt.Printf(" %s Volume Device: %s\n", checkMark, volDevice)
-
-						err = doInitialize(ipAddr, config.SSHPrivateKey, volDevice, varsFilePath, fileUploads, usedGit, gitUrl, gitBranch, dir, argv.TestingBinary)
-						if err != nil {
-							return err
-						}
-
-						time.Sleep(time.Second * time.Duration(2))
-					}
-
-					// Cleanup any previous builds
-					_, err = tryExec("rm -rf /tmp/*.ham.command.status")
-					_, err = tryExec("rm -rf /tmp/*.ham.stdout")
-					if err != nil {
-						return err
-					}
-
-					_, err = tryExec(buildCommand)
-					if err != nil {
-						return err
-					}
-					_ = tuiSpinnerMsg.StopMessage()
-					time.Sleep(time.Second * time.Duration(2))
-				}
 			}
 
 			banner.GetCmdProgressBanner()
 
-			tries := 0
-			for {
-				outputChannel := TailRemoteStdout(ipAddr, config.SSHPrivateKey, hf.SHA256Sum)
-				sshCode, err := trackRemoteServerProgress(ipAddr, config.SSHPrivateKey, outputChannel)
-
-				// Check for SSH Shell Code for More
-				// accurate errors.
-				if sshCode != SSH_SHELL_NO_ERROR {
-					if sshCode == SSH_SHELL_CANNOT_GET_CLIENT ||
-						sshCode == SSH_SHELL_CANNOT_GET_SESSION ||
-						sshCode == SSH_SHELL_CANNOT_CONNECT {
-						tries++
-						if tries >= 3 {
-							if argv.KeepServer || argv.KeepServerOnConnectFail {
-								destroyServer = false
-								banner.GetConnectFailBanner(serverName)
-								return errors.New(
-									"Cannot Get SSH Client (" + err.Error() + "), But Server is Kept and Still Running.")
-							}
-
-							delErr := helpers.TryDeleteServer(client, serverName, 20, 5)
-							if delErr != nil {
-								banner.GetConnectFailBanner(serverName)
-								return delErr
-							}
-
-							destroyServer = false
-							return errors.New("Cannnot Get SSH Client (" + err.Error() + "). Destroyed Server.")
-						}
-
-						time.Sleep(time.Second * time.Duration(5))
-						continue
-					} else if sshCode == SSH_SHELL_MALFORMED_JSON {
-						tries++
-						if tries < 20 {
-							time.Sleep(time.Second * time.Duration(10))
-							continue
-						}
-
-						if argv.KeepServer || argv.KeepServerOnTrackFail {
-							destroyServer = false
-							banner.GetMalformedJSONBanner(serverName)
-							return errors.New("Malformed JSON from Build Server, But Server is Kept and Still Running.")
-						}
-
-						delErr := helpers.TryDeleteServer(client, serverName, 20, 5)
-						if delErr != nil {
-							banner.GetMalformedJSONBanner(serverName)
-							return delErr
-						}
-
-						destroyServer = false
-						return errors.New("Malformed JSON from Build Server. Destroyed Server")
-					} else if sshCode == SSH_SHELL_HAM_STATUS_ERRORED {
-						if argv.KeepServer || argv.KeepServerOnBuildFail {
-							destroyServer = false
-							banner.GetBuildFailedBanner(serverName)
-							return errors.New("Remote Build Failed, But Server is Kept and Still Running.")
-						}
-
-						delErr := helpers.TryDeleteServer(client, serverName, 20, 5)
-						if delErr != nil {
-							banner.GetBuildFailedBanner(serverName)
-							return delErr
-						}
-
-						destroyServer = false
-						return errors.New("Remote Build Failed. Destroyed Server.")
-					} else {
-						tries++
-						if tries >= 3 {
-							delErr := helpers.TryDeleteServer(client, serverName, 20, 5)
-							if delErr != nil {
-								return delErr
-							}
-
-							destroyServer = false
-							return errors.New("Unknown Build Error. Destroyed Server.")
-						}
-					}
-				} else {
-					if err != nil {
-						return err
-					}
-
-					destroyServer = false
-					break
-				}
-
+			if len(targets) == 1 {
+				return session.trackTarget(targets[0])
 			}
-
-			tuiSpinnerMsg.ShowMessage("Fetching Build Status... ")
-			statusTries := 0
-			for statusTries < 20 {
-				statusTries++
-
-				targetSSHKey, _, err := client.SSHKey.Get(
-					context.Background(),
-					"ham-ssh-key",
-				)
-				if err != nil {
-					time.Sleep(time.Second * time.Duration(10))
-					continue
-				}
-
-				if targetSSHKey == nil {
-					destroyServer = !argv.KeepServer
-					return errors.New("HAM SSH Key not found at Hetzner Project.")
-				}
-
-				labels := targetSSHKey.Labels
-				for serv, buildStatus := range labels {
-					if serv == serverName {
-						_ = tuiSpinnerMsg.StopMessage()
-						if buildStatus == "successful" {
-							destroyServer = !argv.KeepServer
-							fmt.Println("Build Successful")
-						} else if buildStatus == "inprogress" {
-							fmt.Println("Build in Progress")
-						} else {
-							destroyServer = !argv.KeepServer || !argv.KeepServerOnBuildFail
-						}
-						return nil
-					}
-				}
-
-				destroyServer = !argv.KeepServer
-				_ = tuiSpinnerMsg.StopMessage()
-				break
-			}
-
-			banner.GetMalformedJSONBanner(serverName)
-			return errors.New("Cannot Get Status of Build.")
+			return session.trackTargets(targets)
 		},
 	}
 }
@@ -696,15 +397,114 @@ func deferDeleteServer(client *hcloud.Client, destroy *bool, serverName string)
 	}
 }
 
+// Resolves, clones and parses a recipe given to ham get, the recipe
+// is returned even on error so it can be removed.
+func loadRecipe(recipe_src string, registry string, tuiSpinnerMsg *TUISpinnerMessenger) (*core.HAMFile, *core.RecipeSource, core.RecipeIdentity, error) {
+	tuiSpinnerMsg.ShowMessage(fmt.Sprintf("Parsing %s...", recipe_src))
+
+	//fmt.Printf(" %s Parsing %s...\n", checkMark, recipe_src)
+	recipe_src, entry, err := core.ResolveRecipeLocation(recipe_src, registry)
+	if err != nil {
+		_ = tuiSpinnerMsg.StopMessage()
+		return nil, nil, core.RecipeIdentity{}, err
+	}
+	if entry != nil {
+		_ = tuiSpinnerMsg.StopMessage()
+		printDone("Registry Recipe: %s (%s by %s)", entry.Name, entry.Title, entry.Maintainer)
+	}
+
+	recipe := core.NewLocalRecipeSource(recipe_src)
+	if core.IsRemoteRecipe(recipe_src) {
+		// Recipe is not local, so use git to clone the
+		// the recipe requested by the user.
+		// banner.GetRecipeNotExistsBanner()
+		tuiSpinnerMsg.ShowMessage("Recipe Does not Exists, Cloning.. ")
+
+		// Parse the string
+		remote, err := core.ParseGitRemote(recipe_src)
+		if err != nil {
+			_ = tuiSpinnerMsg.StopMessage()
+			return nil, nil, core.RecipeIdentity{}, err
+		}
+
+		gitBranch := remote.Branch
+		if gitBranch == "" {
+			gitBranch = "Default"
+		}
+
+		_ = tuiSpinnerMsg.StopMessage()
+
+		printDone("Git URL: %s", remote.URL)
+		printDone("Git Branch: %s", gitBranch)
+		if remote.Rev != "" {
+			printDone("Git Tag or Commit: %s", remote.Rev)
+		}
+
+		tuiSpinnerMsg.ShowMessage("Cloning Recipe...")
+
+		cloned, err := core.CloneRecipeSource(recipe_src)
+		if err != nil {
+			return nil, nil, core.RecipeIdentity{}, err
+		}
+		recipe = cloned
+
+		_ = tuiSpinnerMsg.StopMessage()
+		printDone("Git Commit: %s", recipe.GitCommit)
+	}
+
+	dir := recipe.Dir
+
+	// Parse recipe file for meta information
+	// and args information.
+	hf, err := core.NewHAMFile(dir)
+	if err != nil {
+		return nil, recipe, core.RecipeIdentity{}, err
+	}
+	recipeId, err := core.NewRecipeIdentity(dir, &hf)
+	if err != nil {
+		return nil, recipe, core.RecipeIdentity{}, err
+	}
+
+	banner.GetRecipeBanner(hf.Title, hf.Version, hf.SHA256Sum)
+	for _, source := range hf.Sources {
+		printDone("Included: %s", source)
+	}
+
+	return &hf, recipe, recipeId, nil
+}
+
+// Reads the recipe locations of a batch file, one on each line.
+// Empty lines and lines starting with # are skipped.
+func readBatchFile(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	recipes := []string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		recipes = append(recipes, line)
+	}
+
+	if len(recipes) == 0 {
+		return nil, errors.New(fmt.Sprintf("Batch File %s has no Recipes.", path))
+	}
+	return recipes, nil
+}
+
 func doInitialize(ipAddr string,
 	privateKey string,
 	volumeLinuxDevice string,
 	varsFilePath string,
 	fileUploads map[string]string,
-	usedGit bool,
-	gitUrl string,
-	gitBranch string,
-	dir string,
+	hf *core.HAMFile,
+	recipeId core.RecipeIdentity,
+	recipe *core.RecipeSource,
+	cloneOnServer bool,
 	testingBin string) error {
 	spinnerMsg := NewTUISpinnerMessenger()
 	defer spinnerMsg.StopMessage()
@@ -788,7 +588,7 @@ func doInitialize(ipAddr string,
 		for {
 			tries++
 			if err != nil {
-				fmt.Println("Retrying Exec Error: ", err.Error())
+				printInfo("Retrying Exec Error: %s", err.Error())
 				if tries > 20 {
 					return "", err
 				}
@@ -801,22 +601,20 @@ func doInitialize(ipAddr string,
 		return out, nil
 	}
 
+	// The build server upgrades before the steps if the recipe
+	// wants it, see dependencies.upgrade.
 	spinnerMsg.ShowMessage("Updating Environment... ")
 	_, err = tryExec("apt-get update -y -qq")
 	if err != nil {
 		return err
 	}
-	_, err = tryExec("apt-get upgrade -y -qq")
-	if err != nil {
-		return err
-	}
 	_, err = tryExec("apt-get install -y -qq git wget curl")
 	if err != nil {
 		return err
 	}
 
 	_ = spinnerMsg.StopMessage()
-	fmt.Printf(" %s Updated Environment\n", checkMark)
+	printDone("Updated Environment")
 
 	spinnerMsg.ShowMessage("Installing HAM Binary... ")
 	if testingBin != "" {
@@ -833,7 +631,7 @@ func doInitialize(ipAddr string,
 	}
 
 	_ = spinnerMsg.StopMessage()
-	fmt.Printf(" %s Installed HAM to Remote Server\n", checkMark)
+	printDone("Installed HAM to Remote Server")
 
 	// Copy HAM Configuration File
 	spinnerMsg.ShowMessage("Copying Configuration... ")
@@ -847,7 +645,7 @@ func doInitialize(ipAddr string,
 		return err
 	}
 
-	fmt.Printf(" %s Copied Configuration to Remote Server\n", checkMark)
+	printDone("Copied Configuration to Remote Server")
 
 	spinnerMsg.ShowMessage("Making Required Directories... ")
 	// Make required directories
@@ -870,58 +668,35 @@ func doInitialize(ipAddr string,
 
 	// Upload recipe repo (with SCP) or make the server download it.
 	spinnerMsg.ShowMessage("Uploading Recipe to Remote Server... ")
-	if usedGit {
-		_, err = tryExec("rm -rf /ham-recipe")
+	if recipe.UsedGit && cloneOnServer {
+		err = cloneRecipeOnRemote(shell, sftpClient, recipe)
 		if err != nil {
 			return err
 		}
-		if gitBranch != "" {
-			_, err = tryExec(fmt.Sprintf("git clone --branch %s %s /ham-recipe", gitBranch, gitUrl))
-			if err != nil {
-				return err
-			}
-		} else {
-			_, err = tryExec(fmt.Sprintf("git clone %s /ham-recipe", gitUrl))
-			if err != nil {
-				return err
-			}
-		}
 	} else {
-		// TODO: Make sure that it does not depend on trailing / for
-		// local recipes
-		_, err = tryExec("rm -rf /ham-recipe")
+		err = uploadRecipeTar(shell, recipe, spinnerMsg)
 		if err != nil {
 			return err
 		}
-		rootDir := dir
-
-		walker := func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			destFile := strings.ReplaceAll(path, rootDir, "")
-
-			if info.IsDir() {
-				return sftpClient.MkdirAll(fmt.Sprintf("/ham-recipe/%s", destFile))
-			}
-
-			return helpers.SFTPCopyFileToRemote(sftpClient, fmt.Sprintf("/ham-recipe/%s", destFile), path)
-		}
+	}
 
-		err = filepath.Walk(rootDir, walker)
+	spinnerMsg.ShowMessage("Uploading User Data... ")
+	// Upload files and directories from vars.json to server
+	// using SFTP securely.
+	for srcFilePath, destFilePath := range fileUploads {
+		info, err := os.Stat(srcFilePath)
 		if err != nil {
 			return err
 		}
-	}
 
-	spinnerMsg.ShowMessage("Uploading User Data... ")
-	// Upload files from vars.json to server using SFTP securely.
-	for srcFilePath, destFilePath := range fileUploads {
 		try := 0
 		for {
 			try++
-			err = helpers.SFTPCopyFileToRemote(sftpClient, destFilePath, srcFilePath)
+			if info.IsDir() {
+				err = helpers.SFTPCopyDirToRemote(sftpClient, destFilePath, srcFilePath)
+			} else {
+				err = helpers.SFTPCopyFileToRemote(sftpClient, destFilePath, srcFilePath)
+			}
 			if err != nil {
 				if try > 20 {
 					return err
@@ -939,6 +714,28 @@ func doInitialize(ipAddr string,
 		return err
 	}
 
+	// The build server can't resolve includes by itself.
+	if hf.IsComposed() {
+		resolved, err := hf.ResolvedYAML()
+		if err != nil {
+			return err
+		}
+		err = helpers.SFTPWriteFileToRemote(sftpClient, RESOLVED_RECIPE_PATH, resolved)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Record the recipe this server is building.
+	recipeIdJson, err := json.Marshal(recipeId)
+	if err != nil {
+		return err
+	}
+	err = helpers.SFTPWriteFileToRemote(sftpClient, "/ham-files/recipe.json", recipeIdJson)
+	if err != nil {
+		return err
+	}
+
 	_ = spinnerMsg.StopMessage()
 
 	if volumeLinuxDevice != "" {
@@ -978,7 +775,9 @@ func doInitialize(ipAddr string,
 	return nil
 }
 
-func askQuestions(hf *core.HAMFile, serverName string, answersJsonFilePath string, noconfirm bool) (string, map[string]string, error) {
+// Asks the questions of the recipe, answers are only checked when a
+// answers file was given.
+func askQuestions(hf *core.HAMFile, serverName string, answers core.Answers, noconfirm bool) (string, map[string]string, error) {
 	buildVars := core.NewVariables()
 	optionalSuffix := lipgloss.NewStyle().Foreground(lipgloss.Color("42")).SetString(" (OPTIONAL, Press ENTER to Skip)")
 	varsFilePath := fmt.Sprintf("%s%c%s-vars.json", os.TempDir(), os.PathSeparator, serverName)
@@ -998,9 +797,13 @@ func askQuestions(hf *core.HAMFile, serverName string, answersJsonFilePath strin
 	banner.GetQuestionBanner()
 
 	// Get Answers if Provided
-	answers := map[string]string{}
-	if len(answersJsonFilePath) != 0 {
-		answers, err = helpers.ReadVarsJsonFile(answersJsonFilePath)
+	if answers != nil {
+		answers, err = hf.ResolveAnswers(answers)
+		if err != nil {
+			return varsFilePath, fileUploads, err
+		}
+
+		err = hf.ValidateAnswers(answers, noconfirm)
 		if err != nil {
 			return varsFilePath, fileUploads, err
 		}
@@ -1008,24 +811,35 @@ func askQuestions(hf *core.HAMFile, serverName string, answersJsonFilePath strin
 
 	for _, arg := range hf.Args {
 		placeholder := "Value"
-		required := false
-		valueType := core.VARIABLE_TYPE_VALUE
+		required := arg.IsRequired()
+		valueType := arg.VariableType()
 
-		argType := strings.ToLower(arg.Type)
-		if argType == "file" {
+		switch arg.TypeName() {
+		case "file":
 			placeholder = "File Path"
-			valueType = core.VARIABLE_TYPE_FILE_PATH
-		} else if argType == "secret" {
+		case "dir":
+			placeholder = "Directory Path"
+		case "secret":
 			placeholder = "Secret"
-			valueType = core.VARIABLE_TYPE_SECRET
+		case "int":
+			placeholder = "Number"
 		}
 
-		if arg.Required != nil {
-			required = *arg.Required
+		if len(arg.Default) != 0 && valueType != core.VARIABLE_TYPE_SECRET {
+			placeholder = fmt.Sprintf("%s (Default: %s)", placeholder, arg.Default)
 		}
 
 		answerValue, answerOk := answers[arg.ID]
+		if !answerOk && noconfirm && len(arg.Default) != 0 {
+			answerValue = arg.Default
+			answerOk = true
+		}
+
 		if answerOk {
+			answerValue, err = arg.Normalize(answerValue)
+			if err != nil {
+				return varsFilePath, fileUploads, errors.New(fmt.Sprintf("Answer '%s': %s", arg.ID, err.Error()))
+			}
 			buildVars.PutVar(arg.ID, answerValue, valueType)
 			continue
 		}
@@ -1034,20 +848,60 @@ func askQuestions(hf *core.HAMFile, serverName string, answersJsonFilePath strin
 			continue
 		}
 
-		questionResponse := NewQuestionResponse(required, valueType == core.VARIABLE_TYPE_SECRET)
-
 		suffix := ""
 		if !required {
 			suffix = fmt.Sprintf("%s", optionalSuffix)
 		}
+		question := fmt.Sprintf("%s (%s)%s", arg.Prompt, arg.Describe(), suffix)
 
-		runQuestionTeaProgram(questionResponse, arg.Prompt+suffix, placeholder)
+		// Ask again till we get a answer which is valid
+		// for the type of the argument.
+		for {
+			questionResponse := NewQuestionResponse(required && len(arg.Default) == 0, valueType == core.VARIABLE_TYPE_SECRET)
 
-		if questionResponse.err != nil {
-			return varsFilePath, fileUploads, questionResponse.err
-		}
+			switch arg.TypeName() {
+			case "choice", "bool":
+				options := arg.Options
+				if arg.TypeName() == "bool" {
+					options = []string{"Yes", "No"}
+				}
+
+				selected := 0
+				defaultValue, _ := arg.Normalize(arg.Default)
+				for index, option := range options {
+					normalized, _ := arg.Normalize(option)
+					if len(defaultValue) != 0 && normalized == defaultValue {
+						selected = index
+					}
+				}
+
+				err = runChoiceQuestionTeaProgram(questionResponse, question, arg.Help, options, selected)
+			default:
+				err = runQuestionTeaProgram(questionResponse, question, arg.Help, placeholder)
+			}
+
+			if err != nil {
+				return varsFilePath, fileUploads, err
+			}
+
+			if questionResponse.err != nil {
+				return varsFilePath, fileUploads, questionResponse.err
+			}
+
+			answer := questionResponse.answer
+			if len(answer) == 0 {
+				answer = arg.Default
+			}
 
-		buildVars.PutVar(arg.ID, questionResponse.answer, valueType)
+			answer, err = arg.Normalize(answer)
+			if err != nil {
+				fmt.Printf("   %s%s\n\n", crossMark, err.Error())
+				continue
+			}
+
+			buildVars.PutVar(arg.ID, answer, valueType)
+			break
+		}
 		fmt.Println()
 	}
 
@@ -1059,7 +913,12 @@ func askQuestions(hf *core.HAMFile, serverName string, answersJsonFilePath strin
 			if len(val.Value) != 0 {
 				varsJson[key] = val.Value
 			}
-		} else if val.Type == core.VARIABLE_TYPE_FILE_PATH {
+		} else if val.Type == core.VARIABLE_TYPE_FILE_PATH ||
+			val.Type == core.VARIABLE_TYPE_DIR_PATH {
+			if len(val.Value) == 0 {
+				continue
+			}
+
 			exists, err := helpers.FileExists(val.Value)
 			if err != nil {
 				return varsFilePath, fileUploads, errors.New("Error finding Variables File (" + err.Error() + ").")
@@ -1082,23 +941,16 @@ func askQuestions(hf *core.HAMFile, serverName string, answersJsonFilePath strin
 	return varsFilePath, fileUploads, nil
 }
 
-func trackRemoteServerProgress(host string, sshPrivateKey string, tail chan string) (SSHShellCode, error) {
-	sshClient, err := GetSSHClient(host, sshPrivateKey)
-	if err != nil {
-		return SSH_SHELL_CANNOT_GET_CLIENT, err
-	}
-	defer sshClient.Close()
+// Returns the closed stream, which also has how long the steps that
+// finished took and the manifests of the build.
+func trackRemoteServerProgress(host string, sshPrivateKey string, logPath string) (*BuildStream, error) {
+	stream := NewBuildStream(host, sshPrivateKey, logPath)
+	defer stream.Close()
 
-	shell, err := GetSSHShell(sshClient)
+	err := runProgressTeaProgram(stream)
 	if err != nil {
-		return SSH_SHELL_CANNOT_GET_SESSION, err
+		return stream, err
 	}
 
-	err = runProgressTeaProgram(shell, tail)
-	if err != nil {
-		return shell.code, err
-	}
-
-	return shell.code, nil
-
+	return stream, stream.Err()
 }
@@ -0,0 +1,100 @@
+package get
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/antony-jr/ham/internal/core"
+	"github.com/antony-jr/ham/internal/helpers"
+	"github.com/pkg/sftp"
+)
+
+const (
+	// Only lives on the remote while the recipe is cloned.
+	GIT_CREDENTIALS_FILE = "/root/.ham-git-credentials"
+)
+
+// Clones the git recipe to /ham-recipe at the remote and checks
+// out the exact commit which was cloned at the client, so both
+// always build the same recipe. Private repos are cloned with the
+// token of the user or the forwarded SSH agent.
+func cloneRecipeOnRemote(shell *SSHShellContext, sftpClient *sftp.Client, recipe *core.RecipeSource) error {
+	remote := recipe.Remote()
+	git := "GIT_SSH_COMMAND='ssh -o StrictHostKeyChecking=accept-new' GIT_TERMINAL_PROMPT=0 git"
+
+	token, username := core.GitToken()
+	if !remote.IsSSH() && len(token) != 0 {
+		creds := url.URL{
+			Scheme: "https",
+			User:   url.UserPassword(username, token),
+			Host:   remote.Host(),
+		}
+
+		// Restrict the file before the token is written to it.
+		err := helpers.SFTPWriteFileToRemote(sftpClient, GIT_CREDENTIALS_FILE, nil)
+		if err != nil {
+			return err
+		}
+		defer sftpClient.Remove(GIT_CREDENTIALS_FILE)
+
+		err = sftpClient.Chmod(GIT_CREDENTIALS_FILE, 0600)
+		if err != nil {
+			return err
+		}
+
+		err = helpers.SFTPWriteFileToRemote(sftpClient, GIT_CREDENTIALS_FILE, []byte(creds.String()+"\n"))
+		if err != nil {
+			return err
+		}
+
+		git += " -c " + helpers.ShellQuote("credential.helper=store --file="+GIT_CREDENTIALS_FILE)
+	}
+
+	clone := git + " clone"
+	if remote.Branch != "" {
+		clone += " --branch " + helpers.ShellQuote(remote.Branch)
+	}
+	clone += " -- " + helpers.ShellQuote(remote.URL) + " /ham-recipe"
+
+	commands := []string{
+		"rm -rf /ham-recipe",
+		clone,
+		fmt.Sprintf("%s -C /ham-recipe checkout -q --detach %s", git, helpers.ShellQuote(recipe.GitCommit)),
+		git + " -C /ham-recipe submodule update --init --recursive",
+	}
+
+	exec := shell.Exec
+	if remote.IsSSH() {
+		exec = shell.ExecWithAgent
+	}
+
+	tries := 0
+	for {
+		tries++
+
+		out, err := exec(strings.Join(commands, " && "))
+		if err == nil {
+			break
+		}
+
+		if tries > 3 {
+			return errors.New("Cannot Clone Recipe at Remote Server (" + strings.TrimSpace(out) + ")")
+		}
+		time.Sleep(time.Second * time.Duration(2))
+	}
+
+	head, err := shell.Exec("git -C /ham-recipe rev-parse HEAD")
+	if err != nil {
+		return err
+	}
+
+	head = strings.TrimSpace(head)
+	if head != recipe.GitCommit {
+		return errors.New(fmt.Sprintf("Remote Server Cloned Commit %s but %s was Expected", head, recipe.GitCommit))
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package get
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/mkideal/cli"
+
+	"github.com/antony-jr/ham/internal/core"
+	"github.com/antony-jr/ham/internal/helpers"
+)
+
+// How long the on_cancel and always hooks of the recipe may take on
+// the build server with the default timeout, after the running step
+// stopped.
+const HALT_HOOK_WAIT = time.Minute * time.Duration(25)
+
+type haltT struct {
+	cli.Helper
+	Grace      int    `cli:"g,grace" usage:"Seconds the Running Step has to Stop after SIGINT before it's Killed." dft:"30"`
+	KeepServer bool   `cli:"k,keep-server" usage:"Don't Destroy the Server after the Build is Cancelled."`
+	Registry   string `cli:"r,registry" usage:"URL or Path of the Recipe Registry Index used to find Recipes by Name."`
+}
+
+func NewHaltCommand() *cli.Command {
+	return &cli.Command{
+		Name: "halt",
+		Desc: "Stop a Running Build and Destroy it's Server",
+		Text: `
+Usage: ham halt [Recipe or Build Server Name]
+
+Stop the Build of a Recipe:
+   ham halt ~@gh/enchilada_los18.1
+
+Stop a Build Server and Keep it to Look Around:
+   ham halt --keep-server build-7f3a9c1e5d2b04`,
+		Argv: func() interface{} { return new(haltT) },
+		Fn: func(ctx *cli.Context) error {
+			argv := ctx.Argv().(*haltT)
+			helpers.SetCIMode(helpers.DetectCIMode(false, false))
+
+			if len(ctx.Args()) != 1 {
+				return errors.New("Expected a Recipe or a Build Server Name, See ham halt --help.")
+			}
+
+			tuiSpinnerMsg := NewTUISpinnerMessenger()
+			defer tuiSpinnerMsg.StopMessage()
+
+			config, err := core.GetConfiguration()
+			if err != nil {
+				return err
+			}
+			client := hcloud.NewClient(hcloud.WithToken(config.APIKey))
+
+			tuiSpinnerMsg.ShowMessage("Searching Build Servers...")
+			servers, err := client.Server.All(context.Background())
+			_ = tuiSpinnerMsg.StopMessage()
+			if err != nil {
+				return err
+			}
+
+			targets, err := haltTargets(ctx.Args()[0], argv.Registry, servers, tuiSpinnerMsg)
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			for _, server := range targets {
+				err := haltServer(client, config, server, argv, tuiSpinnerMsg)
+				if err != nil {
+					printFailed("%s", err.Error())
+					failed++
+				}
+			}
+
+			if failed != 0 {
+				return errors.New(fmt.Sprintf("Cannot Halt %d of %d Builds", failed, len(targets)))
+			}
+			return nil
+		},
+	}
+}
+
+// Returns the build servers to halt, the argument is the name of a
+// server or a recipe, which can be built on a server for every
+// variant of it's matrix.
+func haltTargets(arg string, registry string, servers []*hcloud.Server, tuiSpinnerMsg *TUISpinnerMessenger) ([]*hcloud.Server, error) {
+	for _, server := range servers {
+		if server.Name == arg {
+			return []*hcloud.Server{server}, nil
+		}
+	}
+
+	hf, recipe, _, err := loadRecipe(arg, registry, tuiSpinnerMsg)
+	if recipe != nil {
+		defer recipe.Remove()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{
+		helpers.ServerNameFromSHA256(hf.SHA256Sum): true,
+	}
+	variants, err := hf.Variants()
+	if err != nil {
+		return nil, err
+	}
+	for _, variant := range variants {
+		names[helpers.ServerNameFromSHA256(variant.SHA256Sum)] = true
+	}
+
+	targets := []*hcloud.Server{}
+	for _, server := range servers {
+		if names[server.Name] {
+			targets = append(targets, server)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New(fmt.Sprintf("No Build Server Running for %s", arg))
+	}
+	return targets, nil
+}
+
+// Asks the build daemon on the server to stop and waits for it to
+// run the hooks of the recipe. The daemon destroys the server on
+// it's own, it's destroyed here too in case it could not.
+func haltServer(client *hcloud.Client, config core.Configuration, server *hcloud.Server, argv *haltT, tuiSpinnerMsg *TUISpinnerMessenger) error {
+	ipAddr := fmt.Sprintf("%s:22", server.PublicNet.IPv4.IP.String())
+
+	tuiSpinnerMsg.ShowMessage(fmt.Sprintf("Halting Build on %s...", server.Name))
+	sshClient, err := GetSSHClient(ipAddr, config.SSHPrivateKey)
+	if err != nil {
+		_ = tuiSpinnerMsg.StopMessage()
+		return errors.New(fmt.Sprintf("Cannot Connect to %s (%s)", server.Name, err.Error()))
+	}
+
+	shell, err := GetSSHShell(sshClient)
+	if err == nil {
+		keep := ""
+		if argv.KeepServer {
+			keep = " --keep-server"
+		}
+		_, err = shell.Exec(fmt.Sprintf("ham build-halt --grace %d%s", argv.Grace, keep))
+	}
+	sshClient.Close()
+	_ = tuiSpinnerMsg.StopMessage()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Cannot Halt Build on %s (%s)", server.Name, err.Error()))
+	}
+	printDone("Asked %s to Stop", server.Name)
+
+	tuiSpinnerMsg.ShowMessage(fmt.Sprintf("Waiting for %s to Stop...", server.Name))
+	stream := NewBuildStream(ipAddr, config.SSHPrivateKey, "")
+	defer stream.Close()
+
+	timeout := time.After(time.Second*time.Duration(argv.Grace) + HALT_HOOK_WAIT)
+	stopped := false
+	for !stopped {
+		select {
+		case out := <-stream.Status:
+			status, code, _ := parseBuildStatus(out)
+			if code == SSH_SHELL_HAM_STATUS_ERRORED {
+				_ = tuiSpinnerMsg.StopMessage()
+				printFailed("%s", status.Message)
+				stopped = true
+			} else if status.Status == "Cancelled" {
+				_ = tuiSpinnerMsg.StopMessage()
+				printDone("Build on %s Cancelled", server.Name)
+				stopped = true
+			} else if len(status.Progress) != 0 {
+				tuiSpinnerMsg.UpdateMessage(fmt.Sprintf("Waiting for %s to Stop (%s)...", server.Name, status.Progress))
+			}
+		case <-stream.Done:
+			// The daemon is gone, along with the server
+			// most of the time.
+			_ = tuiSpinnerMsg.StopMessage()
+			stopped = true
+		case <-timeout:
+			_ = tuiSpinnerMsg.StopMessage()
+			printFailed("Build on %s did not Stop in Time", server.Name)
+			stopped = true
+		}
+	}
+
+	if argv.KeepServer {
+		printInfo(fmt.Sprintf("Server %s is Kept and Still Running.", server.Name))
+		return nil
+	}
+
+	tuiSpinnerMsg.ShowMessage(fmt.Sprintf("Destroying %s...", server.Name))
+	err = helpers.TryDeleteServer(client, server.Name, 20, 5)
+	_ = tuiSpinnerMsg.StopMessage()
+	if err != nil {
+		return err
+	}
+	printDone("Destroyed %s", server.Name)
+	return nil
+}
@@ -0,0 +1,110 @@
+package get
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mkideal/cli"
+
+	"github.com/antony-jr/ham/internal/helpers"
+)
+
+type logT struct {
+	cli.Helper
+}
+
+// Shows a log downloaded while a build was streamed, in the same
+// viewer as the progress of a live build.
+type logModel struct {
+	viewer *logViewer
+}
+
+func (m logModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m logModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.WindowSizeMsg); ok {
+		m.viewer.SetSize(msg.Width, msg.Height)
+		return m, nil
+	}
+
+	done, cmd := m.viewer.Update(msg)
+	if done {
+		return m, tea.Quit
+	}
+	return m, cmd
+}
+
+func (m logModel) View() string {
+	return m.viewer.View()
+}
+
+// The argument is either the path of a log or the name of a build
+// server, whose log is in ~/.ham.logs.
+func resolveLogPath(arg string) (string, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return arg, nil
+	}
+
+	path, err := helpers.BuildLogPath(arg)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.New(fmt.Sprintf("No Log Found for '%s'", arg))
+	}
+	return path, nil
+}
+
+func NewLogCommand() *cli.Command {
+	return &cli.Command{
+		Name: "log",
+		Desc: "View the Log of a Build Streamed by ham get",
+		Text: "Usage: ham log [Build Server Name or Path to Log]",
+		Argv: func() interface{} { return new(logT) },
+		Fn: func(ctx *cli.Context) error {
+			if len(ctx.Args()) != 1 {
+				return errors.New("Expected a Build Server Name or a Path to a Log")
+			}
+
+			path, err := resolveLogPath(ctx.Args()[0])
+			if err != nil {
+				return err
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			if !helpers.IsTerminal(os.Stdin) || !helpers.IsTerminal(os.Stdout) {
+				_, err = io.Copy(os.Stdout, file)
+				return err
+			}
+
+			viewer := newLogViewer()
+			scanner := bufio.NewScanner(file)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				viewer.Append(strings.TrimRight(scanner.Text(), "\r"))
+			}
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+
+			// Start from the top of a finished log.
+			viewer.follow = false
+
+			_, err = tea.NewProgram(logModel{viewer: viewer}, tea.WithAltScreen()).Run()
+			return err
+		},
+	}
+}
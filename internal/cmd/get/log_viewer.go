@@ -0,0 +1,378 @@
+package get
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/antony-jr/ham/internal/helpers"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Older lines are dropped from the viewer after this many.
+const LOG_VIEWER_MAX_LINES = 50000
+
+const LOG_VIEWER_HELP = "↑/↓ pgup/pgdn scroll • / search • n/N next/prev • e error • [/] step • s step only • p pause • F follow • esc back"
+
+var (
+	// Lines which look like the reason a build failed.
+	errorLineRegex = regexp.MustCompile(`(?i)(\berror\b|\bfatal\b|FAILED:|build stopped|command failed)`)
+
+	ansiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+	logBarStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Background(lipgloss.Color("63")).Padding(0, 1)
+	logStepStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("211")).Bold(true)
+	logHighlightStyle = lipgloss.NewStyle().Reverse(true)
+)
+
+// A step of the build in the log, which starts at the line of it's
+// marker.
+type logStep struct {
+	title string
+	start int
+}
+
+// A full screen viewer for the log of a build which can scroll back,
+// search and follow the log as it grows. Colours of the log are
+// shown as they are.
+type logViewer struct {
+	viewport  viewport.Model
+	search    textinput.Model
+	searching bool
+	query     string
+
+	lines   []string
+	pending []string
+	steps   []logStep
+
+	// Lines from lo to hi are shown, which is only the current
+	// step when stepOnly is set.
+	lo       int
+	hi       int
+	stepOnly bool
+
+	follow    bool
+	paused    bool
+	highlight int
+	message   string
+	dirty     bool
+	width     int
+	height    int
+}
+
+func newLogViewer() *logViewer {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.CharLimit = 120
+
+	return &logViewer{
+		viewport:  viewport.New(80, 20),
+		search:    ti,
+		follow:    true,
+		highlight: -1,
+		dirty:     true,
+	}
+}
+
+func (v *logViewer) Append(lines ...string) {
+	if v.paused {
+		v.pending = append(v.pending, lines...)
+		return
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, helpers.LOG_STEP_MARKER) {
+			v.steps = append(v.steps, logStep{
+				title: strings.TrimPrefix(line, helpers.LOG_STEP_MARKER),
+				start: len(v.lines),
+			})
+		}
+		v.lines = append(v.lines, line)
+	}
+
+	if len(v.lines) > LOG_VIEWER_MAX_LINES {
+		v.drop(len(v.lines) - LOG_VIEWER_MAX_LINES)
+	}
+	v.dirty = true
+}
+
+// Drops the first n lines.
+func (v *logViewer) drop(n int) {
+	v.lines = v.lines[n:]
+
+	steps := []logStep{}
+	for _, step := range v.steps {
+		step.start -= n
+		if step.start >= 0 {
+			steps = append(steps, step)
+		}
+	}
+	v.steps = steps
+	v.highlight -= n
+	v.viewport.YOffset = max(0, v.viewport.YOffset-n)
+}
+
+// The last n lines, for a small view of the log.
+func (v *logViewer) Tail(n int) []string {
+	lines := append(v.lines, v.pending...)
+	if len(lines) <= n {
+		return lines
+	}
+	return lines[len(lines)-n:]
+}
+
+func (v *logViewer) SetSize(width int, height int) {
+	v.width, v.height = width, height
+
+	// A line for the bar at the top and one for the bottom.
+	v.viewport.Width = width
+	v.viewport.Height = max(1, height-2)
+	v.dirty = true
+}
+
+// The step the line belongs to, -1 if it's before all steps.
+func (v *logViewer) stepAt(line int) int {
+	current := -1
+	for i, step := range v.steps {
+		if step.start > line {
+			break
+		}
+		current = i
+	}
+	return current
+}
+
+// Sets what the viewport shows from the lines.
+func (v *logViewer) refresh() {
+	v.lo, v.hi = 0, len(v.lines)
+	if v.stepOnly {
+		current := v.stepAt(v.lo + v.viewport.YOffset)
+		if v.highlight >= 0 {
+			current = v.stepAt(v.highlight)
+		} else if v.follow {
+			current = len(v.steps) - 1
+		}
+		if current >= 0 {
+			v.lo = v.steps[current].start
+			if current+1 < len(v.steps) {
+				v.hi = v.steps[current+1].start
+			}
+		}
+	}
+
+	rendered := make([]string, 0, v.hi-v.lo)
+	for i := v.lo; i < v.hi; i++ {
+		line := v.lines[i]
+		if strings.HasPrefix(line, helpers.LOG_STEP_MARKER) {
+			line = logStepStyle.Render("━━ " + strings.TrimPrefix(line, helpers.LOG_STEP_MARKER))
+		} else if i == v.highlight {
+			line = logHighlightStyle.Render(stripAnsi(line))
+		}
+		rendered = append(rendered, line)
+	}
+
+	v.viewport.SetContent(strings.Join(rendered, "\n"))
+	if v.follow {
+		v.viewport.GotoBottom()
+	}
+	v.dirty = false
+}
+
+// Scrolls to show the line, with a few lines before it.
+func (v *logViewer) show(line int) {
+	v.follow = false
+	v.highlight = line
+	v.refresh()
+	v.viewport.SetYOffset(line - v.lo - 3)
+}
+
+func stripAnsi(s string) string {
+	return ansiRegex.ReplaceAllString(s, "")
+}
+
+// Finds the next line with the query from the highlighted line or
+// the top of the view, backwards if reverse is set.
+func (v *logViewer) find(reverse bool) {
+	if len(v.query) == 0 {
+		return
+	}
+
+	from := v.highlight
+	if from < 0 {
+		from = v.lo + v.viewport.YOffset - 1
+	}
+
+	query := strings.ToLower(v.query)
+	for n := 1; n <= len(v.lines); n++ {
+		i := (from + n) % len(v.lines)
+		if reverse {
+			i = (from - n + 2*len(v.lines)) % len(v.lines)
+		}
+
+		if strings.Contains(strings.ToLower(stripAnsi(v.lines[i])), query) {
+			v.message = ""
+			v.show(i)
+			return
+		}
+	}
+	v.message = "No Match for " + v.query
+}
+
+func (v *logViewer) firstError() {
+	for i, line := range v.lines {
+		if strings.HasPrefix(line, helpers.LOG_STEP_MARKER) {
+			continue
+		}
+		if errorLineRegex.MatchString(stripAnsi(line)) {
+			v.message = ""
+			v.show(i)
+			return
+		}
+	}
+	v.message = "No Errors Found"
+}
+
+// Jumps to the step before or after the one at the top of the view.
+func (v *logViewer) jumpStep(delta int) {
+	if len(v.steps) == 0 {
+		v.message = "No Steps in the Log"
+		return
+	}
+
+	current := v.stepAt(v.lo + v.viewport.YOffset)
+	next := current + delta
+	if next < 0 || next >= len(v.steps) {
+		return
+	}
+
+	v.message = ""
+	v.show(v.steps[next].start)
+}
+
+// Handles a message, done is true when the user wants to leave the
+// viewer.
+func (v *logViewer) Update(msg tea.Msg) (bool, tea.Cmd) {
+	if v.dirty {
+		v.refresh()
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok && v.searching {
+		switch msg.String() {
+		case "enter":
+			v.searching = false
+			v.query = v.search.Value()
+			v.highlight = -1
+			v.find(false)
+			return false, nil
+		case "esc", "ctrl+c":
+			v.searching = false
+			return false, nil
+		}
+
+		var cmd tea.Cmd
+		v.search, cmd = v.search.Update(msg)
+		return false, cmd
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc", "q", "ctrl+c":
+			return true, nil
+		case "/":
+			v.searching = true
+			v.search.SetValue("")
+			v.search.Focus()
+			return false, textinput.Blink
+		case "n":
+			v.find(false)
+			return false, nil
+		case "N":
+			v.find(true)
+			return false, nil
+		case "e":
+			v.firstError()
+			return false, nil
+		case "[":
+			v.jumpStep(-1)
+			return false, nil
+		case "]":
+			v.jumpStep(1)
+			return false, nil
+		case "s":
+			top := v.lo + v.viewport.YOffset
+			v.stepOnly = !v.stepOnly
+			v.refresh()
+			if !v.follow {
+				v.viewport.SetYOffset(top - v.lo)
+			}
+			return false, nil
+		case "p":
+			v.paused = !v.paused
+			if !v.paused {
+				pending := v.pending
+				v.pending = nil
+				v.Append(pending...)
+				v.refresh()
+			}
+			return false, nil
+		case "F":
+			v.follow = !v.follow
+			v.highlight = -1
+			v.refresh()
+			return false, nil
+		case "g", "home":
+			v.follow = false
+			v.viewport.GotoTop()
+			return false, nil
+		case "G", "end":
+			v.follow = true
+			v.highlight = -1
+			v.refresh()
+			return false, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	v.viewport, cmd = v.viewport.Update(msg)
+
+	// Scrolling up stops following the log.
+	if _, ok := msg.(tea.KeyMsg); ok {
+		v.follow = v.viewport.AtBottom()
+	}
+	return false, cmd
+}
+
+func (v *logViewer) View() string {
+	if v.dirty {
+		v.refresh()
+	}
+
+	title := "Log"
+	if current := v.stepAt(v.lo + v.viewport.YOffset); current >= 0 {
+		title = fmt.Sprintf("Step %d/%d: %s", current+1, len(v.steps), v.steps[current].title)
+	}
+
+	state := []string{fmt.Sprintf("%d lines", len(v.lines))}
+	if v.stepOnly {
+		state = append(state, "step only")
+	}
+	if v.paused {
+		state = append(state, fmt.Sprintf("paused, %d new", len(v.pending)))
+	} else if v.follow {
+		state = append(state, "following")
+	}
+	bar := logBarStyle.Width(v.width).MaxWidth(v.width).Render(title + "  (" + strings.Join(state, ", ") + ")")
+
+	bottom := questionHelpStyle.Render(LOG_VIEWER_HELP)
+	if v.searching {
+		bottom = v.search.View()
+	} else if len(v.message) != 0 {
+		bottom = currentStatusStyle.Render(v.message)
+	}
+	bottom = lipgloss.NewStyle().MaxWidth(v.width).Render(bottom)
+
+	return bar + "\n" + v.viewport.View() + "\n" + bottom
+}
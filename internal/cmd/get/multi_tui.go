@@ -0,0 +1,252 @@
+package get
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/antony-jr/ham/internal/helpers"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// A row of the multi build progress, one for every build server
+// that is tracked at the same time.
+type targetRow struct {
+	title      string
+	stream     *BuildStream
+	prog       string
+	percentage int
+	eta        int64
+	progress   progress.Model
+	done       bool
+}
+
+type multiModel struct {
+	rows    []*targetRow
+	width   int
+	spinner spinner.Model
+}
+
+type rowStatusJson struct {
+	row    int
+	status string
+}
+
+type rowDone struct {
+	row int
+}
+
+type rowErrorCode struct {
+	row  int
+	code SSHShellCode
+}
+
+var (
+	rowTitleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("63")).Width(32).MaxWidth(32)
+)
+
+func newMultiModel(titles []string, streams []*BuildStream) multiModel {
+	s := spinner.New()
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("63"))
+	s.Spinner = spinner.Points
+
+	rows := []*targetRow{}
+	for i, title := range titles {
+		rows = append(rows, &targetRow{
+			title:  title,
+			stream: streams[i],
+			prog:   "Building",
+			progress: progress.New(
+				progress.WithDefaultGradient(),
+				progress.WithWidth(30),
+			),
+		})
+	}
+
+	return multiModel{
+		rows:    rows,
+		spinner: s,
+	}
+}
+
+func (m multiModel) Init() tea.Cmd {
+	cmds := []tea.Cmd{
+		tea.Println("  Tracking Remote Builds..."),
+		m.spinner.Tick,
+	}
+	for i, row := range m.rows {
+		cmds = append(cmds, refreshRowProgress(i, row.stream))
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m multiModel) allDone() bool {
+	for _, row := range m.rows {
+		if !row.done {
+			return false
+		}
+	}
+	return true
+}
+
+// Stops tracking the row with the given code.
+func (m multiModel) finishRow(i int, code SSHShellCode) tea.Cmd {
+	m.rows[i].stream.SetCode(code)
+	d := time.Second * time.Duration(2)
+	return tea.Tick(d, func(t time.Time) tea.Msg {
+		return rowDone{row: i}
+	})
+}
+
+func (m multiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			return m, tea.Quit
+		}
+
+	case rowDone:
+		m.rows[msg.row].done = true
+		if m.allDone() {
+			return m, tea.Quit
+		}
+
+	case rowErrorCode:
+		m.rows[msg.row].prog = "Cannot Connect"
+		if msg.code == SSH_SHELL_MALFORMED_JSON {
+			m.rows[msg.row].prog = "Cannot Get Progress"
+		}
+		return m, m.finishRow(msg.row, msg.code)
+
+	case rowStatusJson:
+		row := m.rows[msg.row]
+
+		var result map[string]interface{}
+		err := json.Unmarshal([]byte(msg.status), &result)
+		if err != nil {
+			row.prog = "Cannot Get Progress"
+			return m, m.finishRow(msg.row, SSH_SHELL_MALFORMED_JSON)
+		}
+
+		isErr, _ := result["error"].(bool)
+		if isErr {
+			erMsg, _ := result["message"].(string)
+			row.prog = "Build Failed"
+			return m, tea.Batch(
+				tea.Printf(" %s%s: %s", crossMark, row.title, erMsg),
+				m.finishRow(msg.row, SSH_SHELL_HAM_STATUS_ERRORED),
+			)
+		}
+
+		row.prog, _ = result["progress"].(string)
+		if steps, ok := result["steps"].([]interface{}); ok {
+			for _, st := range steps {
+				step, _ := st.(map[string]interface{})
+				progress, ok := step["progress"].(map[string]interface{})
+				if ok && step["status"] == "running" {
+					stepPercent, _ := progress["percent"].(float64)
+					row.prog += fmt.Sprintf(" (%.0f%%)", stepPercent)
+				}
+			}
+		}
+		percent, _ := result["percentage"].(float64)
+		row.percentage = int(percent)
+		eta, _ := result["eta"].(float64)
+		row.eta = int64(eta)
+
+		if state, _ := result["status"].(string); state == "Cancelled" {
+			row.prog = "Cancelled"
+			return m, tea.Batch(
+				tea.Printf("  %s %s Cancelled\n", crossMark, row.title),
+				m.finishRow(msg.row, SSH_SHELL_NO_ERROR),
+			)
+		}
+
+		if row.percentage == 100 {
+			row.prog = "Completed"
+			return m, tea.Batch(
+				tea.Printf("  %s %s Completed\n", checkMark, row.title),
+				m.finishRow(msg.row, SSH_SHELL_NO_ERROR),
+			)
+		}
+
+		return m, tea.Batch(
+			row.progress.SetPercent(percent/100.0),
+			refreshRowProgress(msg.row, row.stream),
+		)
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case progress.FrameMsg:
+		cmds := []tea.Cmd{}
+		for _, row := range m.rows {
+			newModel, cmd := row.progress.Update(msg)
+			if newModel, ok := newModel.(progress.Model); ok {
+				row.progress = newModel
+			}
+			cmds = append(cmds, cmd)
+		}
+		return m, tea.Batch(cmds...)
+	}
+	return m, nil
+}
+
+func (m multiModel) View() string {
+	if m.allDone() {
+		return ""
+	}
+
+	lines := []string{}
+	for _, row := range m.rows {
+		spin := "  " + m.spinner.View() + " "
+		if row.done {
+			spin = "    "
+		}
+
+		title := rowTitleStyle.Render(row.title)
+		prog := row.progress.View()
+		count := fmt.Sprintf(" %3d/100 ", row.percentage)
+		if row.eta > 0 && !row.done {
+			count += "ETA " + formatSeconds(row.eta) + " "
+		}
+
+		cellsAvail := max(0, m.width-lipgloss.Width(spin+title+prog+count))
+		info := lipgloss.NewStyle().MaxWidth(cellsAvail).Render(currentStatusStyle.Render(row.prog))
+
+		lines = append(lines, spin+title+prog+count+info)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Waits for the daemon of the row to push the next status.
+func refreshRowProgress(row int, stream *BuildStream) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case out := <-stream.Status:
+			return rowStatusJson{row: row, status: out}
+		case <-stream.Done:
+			return rowErrorCode{row: row, code: stream.Code()}
+		}
+	}
+}
+
+func runMultiProgressTeaProgram(titles []string, streams []*BuildStream) error {
+	if helpers.IsCI() {
+		return runMultiProgressCI(titles, streams)
+	}
+
+	if _, err := tea.NewProgram(newMultiModel(titles, streams)).Run(); err != nil {
+		return err
+	}
+
+	return nil
+}
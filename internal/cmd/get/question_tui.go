@@ -4,12 +4,38 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/antony-jr/ham/internal/helpers"
+	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
-func runQuestionTeaProgram(resp *ResponseT, question string, placeholder string) error {
-	p := tea.NewProgram(questionModel(resp, question, placeholder))
+var (
+	questionHelpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+func runQuestionTeaProgram(resp *ResponseT, question string, help string, placeholder string) error {
+	if helpers.IsCI() {
+		resp.err = ciQuestionError(resp, question)
+		return nil
+	}
+
+	p := tea.NewProgram(questionModel(resp, question, help, placeholder))
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func runChoiceQuestionTeaProgram(resp *ResponseT, question string, help string, options []string, selected int) error {
+	if helpers.IsCI() {
+		resp.err = ciQuestionError(resp, question)
+		return nil
+	}
+
+	p := tea.NewProgram(choiceQuestionModel(resp, question, help, options, selected))
 	if _, err := p.Run(); err != nil {
 		return err
 	}
@@ -39,11 +65,12 @@ func NewQuestionResponse(req bool, sec bool) *ResponseT {
 
 type QuestionModel struct {
 	question  string
+	help      string
 	textInput textinput.Model
 	resp      *ResponseT
 }
 
-func questionModel(resp *ResponseT, ques string, holder string) QuestionModel {
+func questionModel(resp *ResponseT, ques string, help string, holder string) QuestionModel {
 	ti := textinput.New()
 	ti.Placeholder = holder
 	ti.Focus()
@@ -55,6 +82,7 @@ func questionModel(resp *ResponseT, ques string, holder string) QuestionModel {
 
 	return QuestionModel{
 		question:  ques,
+		help:      help,
 		textInput: ti,
 		resp:      resp,
 	}
@@ -100,9 +128,95 @@ func (m QuestionModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m QuestionModel) View() string {
 	return fmt.Sprintf(
-		"   %s\n\n%s\n\n%s",
+		"   %s\n%s\n%s\n\n%s",
 		m.question,
+		questionHelpView(m.help),
 		"   "+m.textInput.View(),
 		"    (esc to quit)",
 	) + "\n"
 }
+
+func questionHelpView(help string) string {
+	if len(help) == 0 {
+		return ""
+	}
+	return questionHelpStyle.PaddingLeft(3).Render(help) + "\n"
+}
+
+type ChoiceQuestionModel struct {
+	list     list.Model
+	help     string
+	resp     *ResponseT
+	quitting bool
+}
+
+func choiceQuestionModel(resp *ResponseT, ques string, help string, options []string, selected int) ChoiceQuestionModel {
+	items := []list.Item{}
+	for _, option := range options {
+		items = append(items, item(option))
+	}
+
+	const defaultWidth = 20
+
+	l := list.New(items, itemDelegate{}, defaultWidth, len(options)+4)
+	l.Title = ques
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.Title = titleStyle
+	l.Styles.PaginationStyle = paginationStyle
+	l.Styles.HelpStyle = helpStyle
+	l.Select(selected)
+
+	return ChoiceQuestionModel{
+		list: l,
+		help: help,
+		resp: resp,
+	}
+}
+
+func (m ChoiceQuestionModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m ChoiceQuestionModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetWidth(msg.Width)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			if m.resp.required {
+				m.resp.err = errors.New("User Did Not Answer Question")
+			} else {
+				m.resp.err = nil
+			}
+			m.quitting = true
+			return m, tea.Quit
+
+		case "enter":
+			i, ok := m.list.SelectedItem().(item)
+			if ok {
+				m.resp.answer = string(i)
+			}
+			m.resp.err = nil
+			m.quitting = true
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m ChoiceQuestionModel) View() string {
+	if m.quitting {
+		if len(m.resp.answer) != 0 {
+			return fmt.Sprintf("   %s %s\n", checkMark, m.resp.answer)
+		}
+		return ""
+	}
+	return "\n" + m.list.View() + "\n" + questionHelpView(m.help)
+}
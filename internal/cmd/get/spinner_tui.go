@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/antony-jr/ham/internal/helpers"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -35,6 +36,7 @@ func (m SpinModel) Init() tea.Cmd {
 
 type smoothQuit string
 type waitMore string
+type spinTitle string
 
 func waitForClose(done chan bool) tea.Cmd {
 	d := time.Nanosecond * time.Duration(1)
@@ -54,6 +56,10 @@ func (m SpinModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case waitMore:
 		return m, tea.Batch(waitForClose(m.done))
 
+	case spinTitle:
+		m.title = string(msg)
+		return m, nil
+
 	case smoothQuit:
 		*m.quit = true
 		m.quitting = true
@@ -93,9 +99,7 @@ func (m SpinModel) View() string {
 	return str
 }
 
-func runSpinnerTeaProgram(ok *bool, title string, dn chan bool, end chan bool) {
-	m := initialSpinModel(ok, title, dn)
-	p := tea.NewProgram(m)
+func runSpinnerTeaProgram(p *tea.Program, end chan bool) {
 	_, _ = p.Run()
 
 	end <- true
@@ -106,6 +110,9 @@ type TUISpinnerMessenger struct {
 	fin     chan bool
 	end     chan bool
 	showing bool
+	program *tea.Program
+
+	lastUpdate time.Time
 }
 
 func NewTUISpinnerMessenger() *TUISpinnerMessenger {
@@ -118,6 +125,13 @@ func NewTUISpinnerMessenger() *TUISpinnerMessenger {
 }
 
 func (ctx *TUISpinnerMessenger) ShowMessage(msg string) {
+	// Without a terminal every message is a line of it's own.
+	if helpers.IsCI() {
+		helpers.CIEvent("step", msg, nil)
+		ctx.lastUpdate = time.Now()
+		return
+	}
+
 	if ctx.showing {
 		_ = ctx.StopMessage()
 	}
@@ -126,8 +140,29 @@ func (ctx *TUISpinnerMessenger) ShowMessage(msg string) {
 	ctx.fin = make(chan bool)
 	ctx.end = make(chan bool)
 	ctx.showing = true
+	ctx.program = tea.NewProgram(initialSpinModel(&ctx.quitOk, msg, ctx.fin))
+
+	go runSpinnerTeaProgram(ctx.program, ctx.end)
+}
+
+// Changes the message of the spinner currently shown without
+// starting it over, useful to show progress.
+func (ctx *TUISpinnerMessenger) UpdateMessage(msg string) {
+	// Updates come often, like on every uploaded chunk.
+	if helpers.IsCI() {
+		if time.Since(ctx.lastUpdate) >= time.Second*time.Duration(5) {
+			helpers.CIEvent("step", msg, nil)
+			ctx.lastUpdate = time.Now()
+		}
+		return
+	}
+
+	if !ctx.showing {
+		ctx.ShowMessage(msg)
+		return
+	}
 
-	go runSpinnerTeaProgram(&ctx.quitOk, msg, ctx.fin, ctx.end)
+	ctx.program.Send(spinTitle(msg))
 }
 
 func (ctx *TUISpinnerMessenger) StopMessage() bool {
@@ -135,10 +170,12 @@ func (ctx *TUISpinnerMessenger) StopMessage() bool {
 		return true
 	}
 
-	ctx.fin <- true
-	ret := <-ctx.end
-	for ret {
-		break
+	// The program might have ended already, like when
+	// there is no terminal to show it.
+	select {
+	case ctx.fin <- true:
+		<-ctx.end
+	case <-ctx.end:
 	}
 	time.Sleep(time.Millisecond * time.Duration(500))
 
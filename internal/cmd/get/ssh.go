@@ -1,7 +1,14 @@
 package get
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	//"golang.org/x/crypto/ssh/knownhosts"
 )
 
@@ -17,8 +24,9 @@ const (
 )
 
 type SSHShellContext struct {
-	client *ssh.Client
-	code   SSHShellCode
+	client         *ssh.Client
+	code           SSHShellCode
+	agentForwarded bool
 }
 
 func GetSSHClient(host string, privKey string) (*ssh.Client, error) {
@@ -82,9 +90,73 @@ func (ctx *SSHShellContext) Exec(command string) (string, error) {
 	defer session.Close()
 
 	out, err := session.CombinedOutput(command)
+	if err != nil {
+		return string(out), err
+	}
+
+	return string(out), nil
+}
+
+// Runs the command at the remote with stdin streamed from
+// the given reader, returns once the command exits.
+func (ctx *SSHShellContext) ExecWithStdin(command string, stdin io.Reader) (string, error) {
+	session, err := GetSSHSession(ctx.client)
 	if err != nil {
 		return "", err
 	}
+	defer session.Close()
+
+	var out bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = &out
+	session.Stderr = &out
+
+	err = session.Run(command)
+	if err != nil {
+		return out.String(), err
+	}
+
+	return out.String(), nil
+}
+
+// Runs the command at the remote with the local SSH agent
+// forwarded to it, so the remote can use the keys of the user
+// without them ever leaving this machine.
+func (ctx *SSHShellContext) ExecWithAgent(command string) (string, error) {
+	if !ctx.agentForwarded {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if len(socket) == 0 {
+			return "", errors.New("SSH Agent is not Running (SSH_AUTH_SOCK is not Set)")
+		}
+
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return "", err
+		}
+
+		err = agent.ForwardToAgent(ctx.client, agent.NewClient(conn))
+		if err != nil {
+			conn.Close()
+			return "", err
+		}
+		ctx.agentForwarded = true
+	}
+
+	session, err := GetSSHSession(ctx.client)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	err = agent.RequestAgentForwarding(session)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := session.CombinedOutput(command)
+	if err != nil {
+		return string(out), err
+	}
 
 	return string(out), nil
 }
@@ -0,0 +1,337 @@
+package get
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/antony-jr/ham/internal/core"
+)
+
+const (
+	// Address of the status server of the build daemon, as seen
+	// from the build server.
+	BUILD_STATUS_ADDRESS = "127.0.0.1:1695"
+
+	// Connecting is given up after this many tries in a row
+	// without getting anything.
+	STREAM_MAX_TRIES = 6
+
+	// The daemon sends the status at least this often, a stream
+	// that is silent for longer is dead.
+	STREAM_TIMEOUT = time.Second * time.Duration(30)
+)
+
+// A message pushed by the build daemon.
+type streamMessageT struct {
+	Type     string          `json:"type"`
+	Status   json.RawMessage `json:"status,omitempty"`
+	Log      string          `json:"log,omitempty"`
+	Seq      int64           `json:"seq,omitempty"`
+	Variant  string          `json:"variant,omitempty"`
+	Manifest json.RawMessage `json:"manifest,omitempty"`
+}
+
+// Streams the status and the log of a remote build over a single
+// SSH connection, which forwards to the status server of the build
+// daemon. A lost connection is made again with backoff, Done is
+// closed when it's given up. The log is also written to a local
+// file, so it can be read with ham log later.
+type BuildStream struct {
+	Status chan string
+	Log    chan string
+	Done   chan bool
+
+	host    string
+	privKey string
+	logFile *os.File
+	lastSeq int64
+
+	mutex     sync.Mutex
+	durations core.StepDurations
+	manifests map[string][]byte
+	client    *ssh.Client
+	code      SSHShellCode
+	err       error
+	stop      chan bool
+	closed    bool
+}
+
+// The log is not written anywhere if logPath is empty.
+func NewBuildStream(host string, privKey string, logPath string) *BuildStream {
+	s := &BuildStream{
+		Status:    make(chan string, 1),
+		Log:       make(chan string, 256),
+		Done:      make(chan bool),
+		host:      host,
+		privKey:   privKey,
+		durations: core.StepDurations{},
+		manifests: map[string][]byte{},
+		stop:      make(chan bool),
+	}
+
+	if len(logPath) != 0 {
+		err := os.MkdirAll(filepath.Dir(logPath), 0700)
+		if err == nil {
+			s.logFile, _ = os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		}
+	}
+
+	go s.run()
+	return s
+}
+
+func (s *BuildStream) Code() SSHShellCode {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.code
+}
+
+func (s *BuildStream) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.err
+}
+
+// How long the steps that finished so far took.
+func (s *BuildStream) Durations() core.StepDurations {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	durations := core.StepDurations{}
+	for step, seconds := range s.durations {
+		durations[step] = seconds
+	}
+	return durations
+}
+
+// The manifests of the variants built so far, by the variant.
+func (s *BuildStream) Manifests() map[string][]byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	manifests := map[string][]byte{}
+	for variant, source := range s.manifests {
+		manifests[variant] = source
+	}
+	return manifests
+}
+
+func (s *BuildStream) SetCode(c SSHShellCode) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.code = c
+}
+
+// Stops streaming, the code set so far is kept.
+func (s *BuildStream) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.stop)
+	if s.client != nil {
+		s.client.Close()
+	}
+}
+
+func (s *BuildStream) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *BuildStream) run() {
+	if s.logFile != nil {
+		defer s.logFile.Close()
+	}
+
+	backoff := time.Second
+	tries := 0
+	for {
+		got, code, err := s.stream()
+		if s.stopped() {
+			return
+		}
+
+		if got {
+			tries = 0
+			backoff = time.Second
+		}
+		tries++
+
+		if code == SSH_SHELL_MALFORMED_JSON || tries >= STREAM_MAX_TRIES {
+			s.mutex.Lock()
+			if !s.closed {
+				s.code, s.err = code, err
+			}
+			s.mutex.Unlock()
+			close(s.Done)
+			return
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > STREAM_TIMEOUT {
+			backoff = STREAM_TIMEOUT
+		}
+	}
+}
+
+// Streams until the connection is lost, got tells if anything came
+// through at all.
+func (s *BuildStream) stream() (bool, SSHShellCode, error) {
+	client, err := GetSSHClient(s.host, s.privKey)
+	if err != nil {
+		return false, SSH_SHELL_CANNOT_GET_CLIENT, err
+	}
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		client.Close()
+		return false, SSH_SHELL_NO_ERROR, nil
+	}
+	s.client = client
+	s.mutex.Unlock()
+	defer client.Close()
+
+	// A direct-tcpip channel to the status server.
+	conn, err := client.Dial("tcp", BUILD_STATUS_ADDRESS)
+	if err != nil {
+		return false, SSH_SHELL_CANNOT_GET_SESSION, err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("stream"))
+	if err != nil {
+		return false, SSH_SHELL_CANNOT_GET_SESSION, err
+	}
+
+	// SSH channels have no deadlines, so the connection is closed
+	// when the daemon is silent for too long. The watchdog stops
+	// with the stream.
+	alive := make(chan bool, 1)
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-alive:
+			case <-time.After(STREAM_TIMEOUT):
+				client.Close()
+				return
+			}
+		}
+	}()
+
+	got := false
+	scanner := bufio.NewScanner(conn)
+	// A manifest with the repo manifests in it can be large.
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		select {
+		case alive <- true:
+		default:
+		}
+
+		var msg streamMessageT
+		err = json.Unmarshal(scanner.Bytes(), &msg)
+		if err != nil {
+			return got, SSH_SHELL_MALFORMED_JSON, err
+		}
+		got = true
+
+		switch msg.Type {
+		case "status":
+			s.noteDurations(msg.Status)
+			s.pushStatus(string(msg.Status))
+		case "manifest":
+			s.mutex.Lock()
+			s.manifests[msg.Variant] = append([]byte{}, msg.Manifest...)
+			s.mutex.Unlock()
+		case "log":
+			// Lines sent again after connecting again.
+			if msg.Seq != 0 && msg.Seq <= s.lastSeq {
+				continue
+			}
+			s.lastSeq = msg.Seq
+
+			if s.logFile != nil {
+				s.logFile.WriteString(msg.Log + "\n")
+			}
+
+			select {
+			case s.Log <- msg.Log:
+			default:
+			}
+		}
+	}
+
+	err = scanner.Err()
+	if err == nil {
+		err = errors.New("Build Status Stream Closed")
+	}
+	return got, SSH_SHELL_CANNOT_CONNECT, err
+}
+
+func (s *BuildStream) noteDurations(status []byte) {
+	var steps struct {
+		Steps []struct {
+			Name    string `json:"name"`
+			Status  string `json:"status"`
+			Elapsed int64  `json:"elapsed"`
+		} `json:"steps"`
+	}
+	if json.Unmarshal(status, &steps) != nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, step := range steps.Steps {
+		if step.Status == "done" {
+			s.durations[step.Name] = step.Elapsed
+		}
+	}
+}
+
+// Only the latest status matters, an older one which was not read
+// yet is replaced.
+func (s *BuildStream) pushStatus(status string) {
+	for {
+		select {
+		case s.Status <- status:
+			return
+		default:
+		}
+
+		select {
+		case <-s.Status:
+		default:
+		}
+	}
+}
@@ -0,0 +1,968 @@
+package get
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antony-jr/ham/internal/banner"
+	"github.com/antony-jr/ham/internal/core"
+	"github.com/antony-jr/ham/internal/helpers"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// A build server ham get is responsible for, it builds a recipe
+// with every variant of it's matrix or a single variant.
+type buildTarget struct {
+	// The recipe as given to ham get.
+	source string
+
+	hf       *core.HAMFile
+	recipe   *core.RecipeSource
+	recipeId core.RecipeIdentity
+
+	// Empty when the server builds every variant.
+	variant string
+
+	// The server is named by this sum and the build log is
+	// tailed with it.
+	sum        string
+	serverName string
+
+	server  *hcloud.Server
+	ipAddr  string
+	running bool
+	destroy bool
+
+	// Heads of the remotes watched by the recipe, recorded
+	// when the build is successful.
+	heads map[string]string
+
+	// Built again even if it was built before, since the
+	// watched remotes changed.
+	forced bool
+
+	// How long the steps took, recorded when the build is
+	// successful.
+	durations core.StepDurations
+
+	// The manifests of the variants pushed by the build server,
+	// recorded when the build is successful. The server is gone
+	// soon after, so they are not downloaded at the end.
+	manifests map[string][]byte
+}
+
+func newBuildTarget(source string, hf *core.HAMFile, recipe *core.RecipeSource, recipeId core.RecipeIdentity, variant *core.HAMVariant) *buildTarget {
+	t := &buildTarget{
+		source:   source,
+		hf:       hf,
+		recipe:   recipe,
+		recipeId: recipeId,
+		sum:      hf.SHA256Sum,
+	}
+
+	if variant != nil {
+		t.variant = variant.Name
+		t.sum = variant.SHA256Sum
+	}
+	t.serverName = helpers.ServerNameFromSHA256(t.sum)
+	return t
+}
+
+// Returns the name of the target shown to the user.
+func (t *buildTarget) Title() string {
+	if len(t.variant) != 0 {
+		return fmt.Sprintf("%s [%s]", t.hf.Title, t.variant)
+	}
+	return t.hf.Title
+}
+
+// Keeps the durations of the steps from every time the build was
+// tracked, a step seen again is replaced.
+func (t *buildTarget) addDurations(durations core.StepDurations) {
+	if t.durations == nil {
+		t.durations = core.StepDurations{}
+	}
+	for step, seconds := range durations {
+		t.durations[step] = seconds
+	}
+}
+
+func (t *buildTarget) addManifests(manifests map[string][]byte) {
+	if t.manifests == nil {
+		t.manifests = map[string][]byte{}
+	}
+	for variant, source := range manifests {
+		t.manifests[variant] = source
+	}
+}
+
+// Adds the target unless a target with the same server is there
+// already, the same recipe given twice is built once.
+func addBuildTarget(targets []*buildTarget, t *buildTarget) []*buildTarget {
+	for _, target := range targets {
+		if target.serverName == t.serverName {
+			return targets
+		}
+	}
+	return append(targets, t)
+}
+
+// Answers to the questions of a recipe, asked only once no matter
+// how many servers build it.
+type answeredVars struct {
+	varsFilePath string
+	fileUploads  map[string]string
+}
+
+// State shared by every build target of a single ham get run.
+type getSession struct {
+	argv       *getT
+	client     *hcloud.Client
+	config     core.Configuration
+	spinner    *TUISpinnerMessenger
+	testingRun bool
+
+	// Labels of the ham-ssh-key, which has the state of
+	// every build.
+	labels  map[string]string
+	servers []*hcloud.Server
+
+	answers   map[*core.HAMFile]answeredVars
+	confirmed bool
+
+	// Read once for every recipe, args has the id of every
+	// argument of every recipe.
+	answersFile *core.AnswersFile
+	args        map[string]bool
+
+	serverType *hcloud.ServerType
+}
+
+func (s *getSession) cleanup() {
+	for _, vars := range s.answers {
+		os.Remove(vars.varsFilePath)
+	}
+}
+
+// Asks the questions of the recipe the target builds, the answers
+// are reused for every other target of the same recipe.
+func (s *getSession) askOnce(t *buildTarget) (string, map[string]string, error) {
+	if vars, ok := s.answers[t.hf]; ok {
+		return vars.varsFilePath, vars.fileUploads, nil
+	}
+
+	var answers core.Answers
+	if len(s.argv.Answers) != 0 {
+		if s.answersFile == nil {
+			file, err := core.ReadAnswersFile(s.argv.Answers)
+			if err != nil {
+				return "", nil, err
+			}
+			s.answersFile = &file
+		}
+		answers = s.answersFile.ForRecipe(t.hf, []string{t.source, t.hf.Title}, s.args)
+	}
+
+	varsFilePath, fileUploads, err := askQuestions(t.hf, t.serverName, answers, s.argv.NoConfirm)
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.answers[t.hf] = answeredVars{
+		varsFilePath: varsFilePath,
+		fileUploads:  fileUploads,
+	}
+	return varsFilePath, fileUploads, nil
+}
+
+// Searches for a running build server of the target, a server
+// started from a different version of the recipe is only used if
+// the user wants to.
+func (s *getSession) findServer(t *buildTarget) error {
+	for _, server := range s.servers {
+		if server.Name == t.serverName {
+			_ = s.spinner.StopMessage()
+			// Track status instead of creating a new one.
+			t.server = server
+			printDone("Active Build Found (%s)", t.Title())
+			t.running = true
+			break
+		}
+	}
+
+	// Make sure the running build server was started from
+	// the exact same recipe we have, a changed script in the
+	// recipe does not change the name of the server.
+	if t.running && !s.testingRun {
+		mismatch := t.recipeId.CheckLabels(t.server.Labels)
+		if mismatch != nil {
+			banner.GetRecipeMismatchBanner(t.serverName, mismatch.Error())
+
+			if s.argv.NoConfirm {
+				return errors.New("Running Build Server was Started from a Different Recipe.")
+			}
+
+			resp := NewQuestionResponse(true, false)
+			err := runChoiceQuestionTeaProgram(resp,
+				"What do you want to do?",
+				"",
+				[]string{
+					RECIPE_MISMATCH_ABORT,
+					RECIPE_MISMATCH_RESTART,
+					RECIPE_MISMATCH_ATTACH,
+				},
+				0)
+			if err != nil {
+				return err
+			}
+
+			if resp.err != nil || resp.answer == RECIPE_MISMATCH_ABORT {
+				return errors.New("Running Build Server was Started from a Different Recipe.")
+			}
+
+			if resp.answer == RECIPE_MISMATCH_RESTART {
+				s.spinner.ShowMessage("Destroying Old Build Server... ")
+				err = helpers.TryDeleteServer(s.client, t.serverName, 20, 5)
+				if err != nil {
+					return err
+				}
+				_ = s.spinner.StopMessage()
+				printDone("Destroyed Old Build Server")
+
+				t.server = nil
+				t.running = false
+			}
+		}
+	}
+
+	if s.testingRun {
+		t.ipAddr = s.argv.TestingSSHIP
+	} else if t.server != nil {
+		t.ipAddr = fmt.Sprintf("%s:22", t.server.PublicNet.IPv4.IP.String())
+	} else {
+		t.ipAddr = "127.1:22"
+	}
+
+	return nil
+}
+
+// Checks the heads of the remotes watched by the recipe of every
+// target. With --if-changed the targets which were built
+// successfully from the same heads are left out.
+func (s *getSession) checkWatched(targets []*buildTarget) ([]*buildTarget, error) {
+	heads := map[*core.HAMFile]map[string]string{}
+	changed := []*buildTarget{}
+
+	for _, t := range targets {
+		if len(t.hf.Watch) != 0 {
+			if _, ok := heads[t.hf]; !ok {
+				s.spinner.ShowMessage("Checking Watched Sources... ")
+				current, err := t.hf.WatchedHeads()
+				_ = s.spinner.StopMessage()
+				if err != nil {
+					return nil, err
+				}
+				heads[t.hf] = current
+
+				for _, w := range t.hf.Watch {
+					printDone("Watched %s: %s", w.Key(), current[w.Key()])
+				}
+			}
+			t.heads = heads[t.hf]
+		}
+
+		if !s.argv.IfChanged || s.labels[t.serverName] != "successful" {
+			changed = append(changed, t)
+			continue
+		}
+
+		if len(t.hf.Watch) != 0 {
+			record, err := core.GetWatchRecord(t.serverName)
+			if err != nil {
+				return nil, err
+			}
+
+			// The build server keeps the heads at Hetzner, our own
+			// record is missing if we were not there at the end.
+			same := record != nil && record.Same(t.heads)
+			if sum, ok := s.labels[core.WatchHeadsLabel(t.serverName)]; ok {
+				same = sum == core.WatchHeadsSum(t.heads)
+				if same && record == nil {
+					err = core.RecordWatchedHeads(t.serverName, t.heads)
+					if err != nil {
+						printFailed("Cannot Record Watched Sources (%s)", err.Error())
+					}
+				}
+			}
+
+			if !same {
+				printDone("Watched Sources Changed (%s)", t.Title())
+				t.forced = true
+				changed = append(changed, t)
+				continue
+			}
+		}
+
+		printDone("No Changes (%s)", t.Title())
+	}
+
+	return changed, nil
+}
+
+// Errors out if the target was already built, unless forced.
+func (s *getSession) checkPreviousBuild(t *buildTarget) error {
+	if t.forced {
+		return nil
+	}
+
+	previousBuildStatus := ""
+	for key, status := range s.labels {
+		if key == t.serverName && !s.argv.Force {
+			previousBuildStatus = status
+			break
+		}
+	}
+
+	if previousBuildStatus != "" && !s.argv.Force {
+		if previousBuildStatus == "failed" ||
+			previousBuildStatus == "successful" {
+			estr := fmt.Sprintf("A %s build had run before with this recipe, Run with -f flag to force build.",
+				previousBuildStatus)
+			if len(t.variant) != 0 {
+				estr = fmt.Sprintf("A %s build of %s had run before with this recipe, Run with -f flag to force build.",
+					previousBuildStatus, t.variant)
+			}
+			return errors.New(estr)
+		}
+	}
+
+	return nil
+}
+
+// Shows the price and asks the user to confirm creating count
+// servers, the user is only asked once.
+func (s *getSession) confirmServers(count int) error {
+	if s.confirmed {
+		return nil
+	}
+
+	s.spinner.ShowMessage("Getting Server Information... ")
+
+	// Get Suitable Server and Price
+	price, serverType, err := GrossServerPriceForServerWithHighestPerformance(s.client)
+	if err != nil {
+		return err
+	}
+	_ = s.spinner.StopMessage()
+	banner.GetServerPriceInformationBanner(strings.ToUpper(serverType.Name), price)
+	if count > 1 {
+		banner.GetServerCountBanner(count, price)
+	}
+
+	confirmCreate := s.argv.NoConfirm
+
+	if !s.argv.NoConfirm {
+		err = runConfirmCreateTeaProgram(&confirmCreate)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keep this condition simple since this is an important
+	// decision by the user.
+	if confirmCreate == false {
+		return errors.New("User Declined to Create a New Server.")
+	}
+
+	s.confirmed = true
+	s.serverType = serverType
+	return nil
+}
+
+// Errors out if creating count servers would go over the server
+// limit of the Hetzner project.
+func (s *getSession) checkServerLimit(count int) error {
+	if s.argv.MaxServers <= 0 {
+		return nil
+	}
+
+	if len(s.servers)+count > s.argv.MaxServers {
+		return errors.New(fmt.Sprintf("Cannot Create %d Servers, %d Servers are Running and the Limit is %d. Use --max-servers if your Hetzner Project allows more.",
+			count, len(s.servers), s.argv.MaxServers))
+	}
+	return nil
+}
+
+// Creates a new build server for every target at the same time and
+// initializes them one after the other.
+func (s *getSession) createServers(targets []*buildTarget) error {
+	if len(targets) == 1 {
+		s.spinner.ShowMessage(fmt.Sprintf("Creating Server for %s... ", targets[0].Title()))
+	} else {
+		s.spinner.ShowMessage(fmt.Sprintf("Creating %d Servers... ", len(targets)))
+	}
+
+	errs := make([]error, len(targets))
+	wg := sync.WaitGroup{}
+	for i, t := range targets {
+		// The server might be created even on error.
+		t.destroy = !s.argv.KeepServer
+
+		wg.Add(1)
+		go func(i int, t *buildTarget) {
+			defer wg.Done()
+
+			/* NOTE: Important Section. */
+			server, err := core.CreateServer(s.client, s.serverType, t.serverName, t.recipeId.Labels())
+			if err != nil {
+				if hcloud.IsError(err, hcloud.ErrorCodeResourceLimitExceeded) {
+					err = errors.New("Hetzner Project Limit Reached, Ask Hetzner to Raise the Limit or Build Less at Once. (" + err.Error() + ")")
+				}
+				errs[i] = err
+				return
+			}
+			t.server = server
+			t.ipAddr = fmt.Sprintf("%s:22", t.server.PublicNet.IPv4.IP.String())
+		}(i, t)
+	}
+	wg.Wait()
+	_ = s.spinner.StopMessage()
+
+	failed := []string{}
+	for i, t := range targets {
+		if errs[i] != nil {
+			printFailed("Cannot Create Server for %s (%s)", t.Title(), errs[i].Error())
+			failed = append(failed, t.Title())
+			continue
+		}
+		printDone("Created Server (%s)", t.Title())
+	}
+
+	if len(failed) != 0 {
+		if len(targets) == 1 {
+			return errs[0]
+		}
+		return errors.New(fmt.Sprintf("Cannot Create %d of %d Servers (%s)", len(failed), len(targets), strings.Join(failed, ", ")))
+	}
+
+	for _, t := range targets {
+		// Before that we need to get variables from the user
+		// such as special files, env vars required for the
+		// build from the user. This might be crucial secrets
+		// so transport it with SSH to stay secure.
+		varsFilePath, fileUploads, err := s.askOnce(t)
+		if err != nil {
+			return err
+		}
+
+		err = s.initialize(t, varsFilePath, fileUploads)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *getSession) initialize(t *buildTarget, varsFilePath string, fileUploads map[string]string) error {
+	volDevice, err := helpers.GetVolumeLinuxDeviceForServer(s.client, t.serverName)
+	if err != nil {
+		return err
+	}
+	printDone("Volume Device: %s", volDevice)
+
+	return doInitialize(t.ipAddr, s.config.SSHPrivateKey, volDevice, varsFilePath, fileUploads,
+		t.hf, t.recipeId, t.recipe, s.argv.CloneOnServer, s.argv.TestingBinary)
+}
+
+// Starts the build at the server of the target if it's not
+// running already.
+func (s *getSession) startBuild(t *buildTarget) error {
+	s.spinner.ShowMessage("Checking Build Process... ")
+	defer s.spinner.StopMessage()
+
+	sshClient, err := GetSSHClient(t.ipAddr, s.config.SSHPrivateKey)
+	tries := 0
+	for {
+		tries++
+		if err != nil {
+			if tries > 20 {
+				return err
+			}
+			time.Sleep(time.Second * time.Duration(2))
+			sshClient, err = GetSSHClient(t.ipAddr, s.config.SSHPrivateKey)
+			continue
+		}
+		break
+	}
+	tries = 0
+	defer sshClient.Close()
+
+	shell, err := GetSSHShell(sshClient)
+	for {
+		tries++
+		if err != nil {
+			if tries > 20 {
+				return err
+			}
+			time.Sleep(time.Second * time.Duration(2))
+			shell, err = GetSSHShell(sshClient)
+			continue
+		}
+		break
+	}
+	tries = 0
+
+	tryExec := func(cmd string) (string, error) {
+		out, err := shell.Exec(cmd)
+		try := 0
+		for {
+			try++
+			if err != nil {
+				if try > 20 {
+					return "", err
+				}
+				time.Sleep(time.Second * time.Duration(2))
+				out, err = shell.Exec(cmd)
+				continue
+			}
+			break
+		}
+		return out, err
+	}
+
+	processExists, _ := shell.Exec("ps -ef | grep \"[h]am build\"")
+	if strings.Contains(processExists, "ham build") {
+		_ = s.spinner.StopMessage()
+		printDone("Build Process Running (%s)", t.Title())
+		return nil
+	}
+
+	keep := ""
+	if s.argv.KeepServer || s.argv.KeepServerOnBuildFail {
+		keep = "--keep-server"
+	}
+	buildCommand := fmt.Sprintf("ham build %s --sum %s --recipe /ham-recipe --vars /ham-files/vars.json",
+		keep,
+		t.hf.SHA256Sum)
+	if t.hf.IsComposed() {
+		buildCommand += " --resolved " + RESOLVED_RECIPE_PATH
+	}
+	if len(t.variant) != 0 {
+		buildCommand += " --variant " + helpers.ShellQuote(t.variant)
+	}
+
+	// check if initialized first
+	out, _ := shell.Exec("ls /tmp/ | grep ham.init.finished")
+	if !strings.Contains(out, "ham.init.finished") {
+		_ = s.spinner.StopMessage()
+		printInfo(" Server is not Initialized Properly")
+		printInfo(" Please Answer All Questions to Initialize Properly")
+		varsFilePath, fileUploads, err := s.askOnce(t)
+		for {
+			tries++
+			if err != nil {
+				if tries > 4 {
+					return err
+				}
+				time.Sleep(time.Second * time.Duration(1))
+				varsFilePath, fileUploads, err = s.askOnce(t)
+				continue
+			}
+			break
+		}
+		tries = 0
+
+		err = s.initialize(t, varsFilePath, fileUploads)
+		if err != nil {
+			return err
+		}
+
+		time.Sleep(time.Second * time.Duration(2))
+	}
+
+	// Cleanup any previous builds
+	_, err = tryExec("rm -rf /tmp/*.ham.command.status")
+	_, err = tryExec("rm -rf /tmp/*.ham.stdout")
+	if err != nil {
+		return err
+	}
+
+	// The steps are weighed by how long they took before.
+	durations, _ := core.GetStepDurations(t.hf.Title)
+	if len(durations) != 0 {
+		source, err := json.Marshal(durations)
+		if err == nil {
+			_, err = shell.ExecWithStdin("cat > "+DURATIONS_PATH, bytes.NewReader(source))
+		}
+		if err == nil {
+			buildCommand += " --durations " + DURATIONS_PATH
+		}
+	}
+
+	// The build server records the heads when it's successful.
+	if len(t.heads) != 0 {
+		source, err := json.Marshal(t.heads)
+		if err == nil {
+			_, err = shell.ExecWithStdin("cat > "+HEADS_PATH, bytes.NewReader(source))
+		}
+		if err == nil {
+			buildCommand += " --heads " + HEADS_PATH
+		}
+	}
+
+	_, err = tryExec(buildCommand)
+	if err != nil {
+		return err
+	}
+	_ = s.spinner.StopMessage()
+	time.Sleep(time.Second * time.Duration(2))
+	return nil
+}
+
+// Gives up on the target after tracking failed with the given
+// code, the server is destroyed unless the user wants to keep it.
+func (s *getSession) giveUp(t *buildTarget, code SSHShellCode, err error) error {
+	argv := s.argv
+	serverName := t.serverName
+
+	switch code {
+	case SSH_SHELL_CANNOT_GET_CLIENT, SSH_SHELL_CANNOT_GET_SESSION, SSH_SHELL_CANNOT_CONNECT:
+		reason := "Unknown Error"
+		if err != nil {
+			reason = err.Error()
+		}
+
+		if argv.KeepServer || argv.KeepServerOnConnectFail {
+			t.destroy = false
+			banner.GetConnectFailBanner(serverName)
+			return helpers.NewFatalExitError(code.ExitCode(), errors.New(
+				"Cannot Get SSH Client ("+reason+"), But Server is Kept and Still Running."))
+		}
+
+		delErr := helpers.TryDeleteServer(s.client, serverName, 20, 5)
+		if delErr != nil {
+			banner.GetConnectFailBanner(serverName)
+			return delErr
+		}
+
+		t.destroy = false
+		return helpers.NewFatalExitError(code.ExitCode(), errors.New("Cannnot Get SSH Client ("+reason+"). Destroyed Server."))
+	case SSH_SHELL_MALFORMED_JSON:
+		if argv.KeepServer || argv.KeepServerOnTrackFail {
+			t.destroy = false
+			banner.GetMalformedJSONBanner(serverName)
+			return helpers.NewFatalExitError(code.ExitCode(), errors.New("Malformed JSON from Build Server, But Server is Kept and Still Running."))
+		}
+
+		delErr := helpers.TryDeleteServer(s.client, serverName, 20, 5)
+		if delErr != nil {
+			banner.GetMalformedJSONBanner(serverName)
+			return delErr
+		}
+
+		t.destroy = false
+		return helpers.NewFatalExitError(code.ExitCode(), errors.New("Malformed JSON from Build Server. Destroyed Server"))
+	case SSH_SHELL_HAM_STATUS_ERRORED:
+		if argv.KeepServer || argv.KeepServerOnBuildFail {
+			t.destroy = false
+			banner.GetBuildFailedBanner(serverName)
+			return helpers.NewFatalExitError(code.ExitCode(), errors.New("Remote Build Failed, But Server is Kept and Still Running."))
+		}
+
+		delErr := helpers.TryDeleteServer(s.client, serverName, 20, 5)
+		if delErr != nil {
+			banner.GetBuildFailedBanner(serverName)
+			return delErr
+		}
+
+		t.destroy = false
+		return helpers.NewFatalExitError(code.ExitCode(), errors.New("Remote Build Failed. Destroyed Server."))
+	}
+
+	delErr := helpers.TryDeleteServer(s.client, serverName, 20, 5)
+	if delErr != nil {
+		return delErr
+	}
+
+	t.destroy = false
+	return helpers.NewFatalExitError(code.ExitCode(), errors.New("Unknown Build Error. Destroyed Server."))
+}
+
+// Tracks the build of a single target with it's log until it's
+// done.
+func (s *getSession) trackTarget(t *buildTarget) error {
+	tries := 0
+	for {
+		logPath, _ := helpers.BuildLogPath(t.serverName)
+		stream, err := trackRemoteServerProgress(t.ipAddr, s.config.SSHPrivateKey, logPath)
+		sshCode := stream.Code()
+		t.addDurations(stream.Durations())
+		t.addManifests(stream.Manifests())
+
+		// Check for SSH Shell Code for More
+		// accurate errors.
+		if sshCode != SSH_SHELL_NO_ERROR {
+			if sshCode == SSH_SHELL_CANNOT_GET_CLIENT ||
+				sshCode == SSH_SHELL_CANNOT_GET_SESSION ||
+				sshCode == SSH_SHELL_CANNOT_CONNECT {
+				tries++
+				if tries >= 3 {
+					return s.giveUp(t, sshCode, err)
+				}
+
+				time.Sleep(time.Second * time.Duration(5))
+				continue
+			} else if sshCode == SSH_SHELL_MALFORMED_JSON {
+				tries++
+				if tries < 20 {
+					time.Sleep(time.Second * time.Duration(10))
+					continue
+				}
+
+				return s.giveUp(t, sshCode, err)
+			} else if sshCode == SSH_SHELL_HAM_STATUS_ERRORED {
+				return s.giveUp(t, sshCode, err)
+			} else {
+				tries++
+				if tries >= 3 {
+					return s.giveUp(t, sshCode, err)
+				}
+			}
+		} else {
+			if err != nil {
+				return err
+			}
+
+			t.destroy = false
+			break
+		}
+	}
+
+	return s.finalStatus(t)
+}
+
+// Reads the final state of the build of the target from the
+// labels at Hetzner.
+func (s *getSession) finalStatus(t *buildTarget) error {
+	argv := s.argv
+
+	s.spinner.ShowMessage("Fetching Build Status... ")
+	statusTries := 0
+	for statusTries < 20 {
+		statusTries++
+
+		targetSSHKey, _, err := s.client.SSHKey.Get(
+			context.Background(),
+			"ham-ssh-key",
+		)
+		if err != nil {
+			time.Sleep(time.Second * time.Duration(10))
+			continue
+		}
+
+		if targetSSHKey == nil {
+			t.destroy = !argv.KeepServer
+			return errors.New("HAM SSH Key not found at Hetzner Project.")
+		}
+
+		labels := targetSSHKey.Labels
+		for serv, buildStatus := range labels {
+			if serv == t.serverName {
+				_ = s.spinner.StopMessage()
+				if buildStatus == "successful" {
+					t.destroy = !argv.KeepServer
+					printInfo("Build Successful")
+
+					if t.heads != nil {
+						err = core.RecordWatchedHeads(t.serverName, t.heads)
+						if err != nil {
+							printFailed("Cannot Record Watched Sources (%s)", err.Error())
+						}
+					}
+
+					if len(t.durations) != 0 {
+						err = core.RecordStepDurations(t.hf.Title, t.durations)
+						if err != nil {
+							printFailed("Cannot Record Step Durations (%s)", err.Error())
+						}
+					}
+
+					s.recordManifests(t)
+				} else if buildStatus == "inprogress" {
+					printInfo("Build in Progress")
+				} else if buildStatus == "cancelled" {
+					// ham halt decides if the server is kept.
+					t.destroy = false
+					printInfo("Build Cancelled")
+				} else {
+					t.destroy = !argv.KeepServer || !argv.KeepServerOnBuildFail
+				}
+
+				s.printVariantStatus(t, labels)
+				if buildStatus == "failed" {
+					return helpers.NewFatalExitError(helpers.EXIT_BUILD_FAILED, errors.New("Remote Build Failed."))
+				}
+				if buildStatus == "cancelled" {
+					return helpers.NewFatalExitError(helpers.EXIT_BUILD_CANCELLED, errors.New("Remote Build Cancelled."))
+				}
+				return nil
+			}
+		}
+
+		t.destroy = !argv.KeepServer
+		_ = s.spinner.StopMessage()
+		break
+	}
+
+	banner.GetMalformedJSONBanner(t.serverName)
+	return errors.New("Cannot Get Status of Build.")
+}
+
+// Keeps the manifest of every variant built by the target with the
+// build log, a build is not failed for it.
+func (s *getSession) recordManifests(t *buildTarget) {
+	if len(t.manifests) == 0 {
+		printFailed("Build Manifest was not Received (%s)", t.Title())
+		return
+	}
+
+	variants := make([]string, 0, len(t.manifests))
+	for variant := range t.manifests {
+		variants = append(variants, variant)
+	}
+	sort.Strings(variants)
+
+	for _, variant := range variants {
+		path, err := core.RecordBuildManifest(t.serverName, variant, t.manifests[variant])
+		if err != nil {
+			printFailed("Cannot Record Build Manifest (%s)", err.Error())
+			continue
+		}
+		printDone("Saved Build Manifest to %s", path)
+	}
+}
+
+// Prints the state of every variant built by the target.
+func (s *getSession) printVariantStatus(t *buildTarget, labels map[string]string) {
+	if len(t.variant) != 0 || !t.hf.HasMatrix() {
+		return
+	}
+
+	variants, err := t.hf.Variants()
+	if err != nil {
+		return
+	}
+
+	for _, variant := range variants {
+		state := labels[helpers.ServerNameFromSHA256(variant.SHA256Sum)]
+		if helpers.IsCI() {
+			helpers.CIEvent("variant", "", map[string]interface{}{
+				"variant": variant.Name,
+				"status":  state,
+			})
+			continue
+		}
+
+		mark := checkMark
+		if state != "successful" {
+			mark = crossMark
+		}
+		fmt.Printf(" %s %s: %s\n", mark, variant.Name, state)
+	}
+}
+
+// Tracks the builds of several targets at once, each with a row of
+// it's own. Targets that lost the connection are tracked again, the
+// rest are given up or checked for their final state.
+func (s *getSession) trackTargets(targets []*buildTarget) error {
+	tries := map[*buildTarget]int{}
+	codes := map[*buildTarget]SSHShellCode{}
+	errs := map[*buildTarget]error{}
+
+	pending := targets
+	for len(pending) != 0 {
+		titles := []string{}
+		streams := []*BuildStream{}
+		for _, t := range pending {
+			titles = append(titles, t.Title())
+			logPath, _ := helpers.BuildLogPath(t.serverName)
+			streams = append(streams, NewBuildStream(t.ipAddr, s.config.SSHPrivateKey, logPath))
+		}
+
+		err := runMultiProgressTeaProgram(titles, streams)
+		for _, stream := range streams {
+			stream.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		retry := []*buildTarget{}
+		for i, t := range pending {
+			codes[t], errs[t] = streams[i].Code(), streams[i].Err()
+			t.addDurations(streams[i].Durations())
+			t.addManifests(streams[i].Manifests())
+
+			switch codes[t] {
+			case SSH_SHELL_CANNOT_GET_CLIENT, SSH_SHELL_CANNOT_GET_SESSION, SSH_SHELL_CANNOT_CONNECT:
+				tries[t]++
+				if tries[t] < 3 {
+					retry = append(retry, t)
+				}
+			case SSH_SHELL_MALFORMED_JSON:
+				tries[t]++
+				if tries[t] < 20 {
+					retry = append(retry, t)
+				}
+			}
+		}
+
+		pending = retry
+		if len(pending) != 0 {
+			time.Sleep(time.Second * time.Duration(5))
+		}
+	}
+
+	failed := []string{}
+	exitCode := 0
+	for _, t := range targets {
+		var err error
+		if codes[t] == SSH_SHELL_NO_ERROR {
+			t.destroy = false
+			err = s.finalStatus(t)
+		} else {
+			err = s.giveUp(t, codes[t], errs[t])
+		}
+
+		if err != nil {
+			printFailed("%s: %s", t.Title(), err.Error())
+			failed = append(failed, t.Title())
+
+			// The exit code tells what went wrong only when it's
+			// the same for all failed builds.
+			code := 1
+			var exitErr *helpers.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.Code
+			}
+			if exitCode != 0 && exitCode != code {
+				code = 1
+			}
+			exitCode = code
+		}
+	}
+
+	if len(failed) != 0 {
+		return helpers.NewFatalExitError(exitCode, errors.New(fmt.Sprintf("%d of %d Builds Failed (%s)", len(failed), len(targets), strings.Join(failed, ", "))))
+	}
+	return nil
+}
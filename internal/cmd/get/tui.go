@@ -7,6 +7,7 @@ import (
 
 	"encoding/json"
 
+	"github.com/antony-jr/ham/internal/helpers"
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
@@ -14,16 +15,27 @@ import (
 	"github.com/kyokomi/emoji/v2"
 )
 
+// Number of log lines shown under the progress.
+const LOG_LINES_SHOWN = 6
+
+// Number of steps shown in the checklist, around the running one.
+const STEPS_SHOWN = 10
+
 type model struct {
-	shell      *SSHShellContext
-	tail       chan string
-	output     string
+	stream     *BuildStream
+	viewer     *logViewer
+	viewing    bool
 	percentage int
 	prog       string
+	variants   []variantRow
+	steps      []stepRow
+	elapsed    int64
+	eta        int64
 	width      int
 	height     int
 	spinner    spinner.Model
 	progress   progress.Model
+	stepBar    progress.Model
 	done       bool
 }
 
@@ -34,7 +46,7 @@ var (
 	crossMark          = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).SetString(emoji.Sprintf(":prohibited:"))
 )
 
-func newModel(shell *SSHShellContext, t chan string) model {
+func newModel(stream *BuildStream) model {
 	p := progress.New(
 		progress.WithDefaultGradient(),
 		progress.WithWidth(40),
@@ -43,38 +55,53 @@ func newModel(shell *SSHShellContext, t chan string) model {
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("63"))
 	s.Spinner = spinner.Points
 	return model{
-		shell:      shell,
-		tail:       t,
+		stream:     stream,
+		viewer:     newLogViewer(),
 		percentage: 0,
-		output:     "",
 		prog:       "Building",
 		spinner:    s,
 		progress:   p,
+		stepBar: progress.New(
+			progress.WithDefaultGradient(),
+			progress.WithWidth(20),
+		),
 	}
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(tea.Println("  Tracking Remote Build..."), m.spinner.Tick, refreshProgress(m.shell), refreshOutput(m.tail))
+	return tea.Batch(tea.Println("  Tracking Remote Build..."), m.spinner.Tick, refreshProgress(m.stream), refreshOutput(m.stream))
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(tea.KeyMsg); ok && m.viewing {
+		done, cmd := m.viewer.Update(msg)
+		if done {
+			m.viewing = false
+			return m, tea.ExitAltScreen
+		}
+		return m, cmd
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width, m.height = msg.Width, msg.Height
+		m.viewer.SetSize(msg.Width, msg.Height)
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "esc", "q":
 			return m, tea.Quit
+		case "l":
+			m.viewing = true
+			return m, tea.EnterAltScreen
 		}
 
 	case errorCode:
 		return m, tea.Quit
 
 	case outputContent:
-		outputStr := string(msg)
-		m.output = outputStr
+		m.viewer.Append(string(msg))
 		return m, tea.Batch(
-			refreshOutput(m.tail),
+			refreshOutput(m.stream),
 		)
 
 	case statusJson:
@@ -85,7 +112,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if err != nil {
 			return m, tea.Batch(
 				tea.Printf(" %sCannot Get Progress from Remote. (%s)\n", crossMark, err.Error()),
-				withErrorQuit(m.shell, SSH_SHELL_MALFORMED_JSON),
+				withErrorQuit(m.stream, SSH_SHELL_MALFORMED_JSON),
 			)
 		}
 
@@ -97,7 +124,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Batch(
 				tea.Printf(" %s%s", crossMark, erMsg),
 				tea.Printf(" %sBuild Failed.\n", crossMark),
-				withErrorQuit(m.shell, SSH_SHELL_HAM_STATUS_ERRORED),
+				withErrorQuit(m.stream, SSH_SHELL_HAM_STATUS_ERRORED),
 			)
 		}
 
@@ -105,11 +132,97 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		percent := result["percentage"].(interface{}).(float64)
 		m.percentage = int(percent)
 
+		elapsed, _ := result["elapsed"].(float64)
+		eta, _ := result["eta"].(float64)
+		m.elapsed, m.eta = int64(elapsed), int64(eta)
+
+		m.steps = []stepRow{}
+		if steps, ok := result["steps"].([]interface{}); ok {
+			for _, st := range steps {
+				step, ok := st.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := step["name"].(string)
+				status, _ := step["status"].(string)
+				stepElapsed, _ := step["elapsed"].(float64)
+				stepEta, _ := step["eta"].(float64)
+				row := stepRow{
+					name:    name,
+					status:  status,
+					elapsed: int64(stepElapsed),
+					eta:     int64(stepEta),
+					percent: -1,
+				}
+
+				// What the command of the step says, like the
+				// progress lines of Ninja.
+				if progress, ok := step["progress"].(map[string]interface{}); ok {
+					row.percent, _ = progress["percent"].(float64)
+					done, _ := progress["done"].(float64)
+					total, _ := progress["total"].(float64)
+					if total > 0 {
+						row.count = fmt.Sprintf("%.0f/%.0f", done, total)
+					}
+				}
+				m.steps = append(m.steps, row)
+			}
+		}
+
+		// Hooks of the recipe run after the steps as the build
+		// ends, a hook that timed out failed.
+		if hooks, ok := result["hooks"].([]interface{}); ok {
+			for _, h := range hooks {
+				hook, ok := h.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := hook["name"].(string)
+				status, _ := hook["status"].(string)
+				hookElapsed, _ := hook["elapsed"].(float64)
+				if status == "timed_out" {
+					status = "failed"
+				}
+				m.steps = append(m.steps, stepRow{
+					name:    name + " hook",
+					status:  status,
+					elapsed: int64(hookElapsed),
+					percent: -1,
+				})
+			}
+		}
+
+		m.variants = []variantRow{}
+		if variants, ok := result["variants"].([]interface{}); ok {
+			for _, v := range variants {
+				variant, ok := v.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := variant["name"].(string)
+				status, _ := variant["status"].(string)
+				vpercent, _ := variant["percentage"].(float64)
+				m.variants = append(m.variants, variantRow{
+					name:       name,
+					status:     status,
+					percentage: int(vpercent),
+				})
+			}
+		}
+
+		if state, _ := result["status"].(string); state == "Cancelled" {
+			m.done = true
+			return m, tea.Batch(
+				tea.Printf("  %s Remote Build Cancelled\n", crossMark),
+				withErrorQuit(m.stream, SSH_SHELL_NO_ERROR),
+			)
+		}
+
 		if m.percentage == 100 {
 			m.done = true
 			return m, tea.Batch(
 				tea.Printf("  %s Remote Build Completed\n", checkMark),
-				withErrorQuit(m.shell, SSH_SHELL_NO_ERROR),
+				withErrorQuit(m.stream, SSH_SHELL_NO_ERROR),
 			)
 		}
 
@@ -117,7 +230,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		return m, tea.Batch(
 			progressCmd,
-			refreshProgress(m.shell),
+			refreshProgress(m.stream),
 		)
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -141,6 +254,10 @@ func (m model) View() string {
 		return ""
 	}
 
+	if m.viewing {
+		return m.viewer.View()
+	}
+
 	pkgCount := fmt.Sprintf(" %*d/%*d", w, m.percentage, w, n)
 
 	spin := "  " + m.spinner.View() + " "
@@ -154,54 +271,171 @@ func (m model) View() string {
 	gap := strings.Repeat(" ", cellsRemaining)
 
 	tailOut := ""
-	if m.output != "" {
+	if output := m.viewer.Tail(LOG_LINES_SHOWN); len(output) != 0 {
 		dialogBoxStyle := lipgloss.NewStyle().
 			Padding(1, 1).
 			PaddingLeft(2).
 			Width(100).
 			Foreground(lipgloss.Color("201"))
 
-		tailOut = dialogBoxStyle.Render(strings.ReplaceAll(strings.ReplaceAll(m.output, "\r", "\n"), "\n\n", "\n")) + "\n\n"
+		tailOut = dialogBoxStyle.Render(strings.Join(output, "\n")) + "\n\n"
+	}
+
+	variantsOut := ""
+	for _, v := range m.variants {
+		mark := "  "
+		if v.status == "successful" {
+			mark = checkMark.String() + " "
+		} else if v.status == "failed" {
+			mark = crossMark.String() + " "
+		}
+		variantsOut += fmt.Sprintf("  %s%s %3d%% %s\n", mark, rowTitleStyle.Render(v.name), v.percentage, currentStatusStyle.Render(v.status))
 	}
 
-	return tailOut + spin + info + gap + prog + pkgCount
+	timing := ""
+	if m.elapsed > 0 {
+		timing = "Elapsed " + formatSeconds(m.elapsed)
+		if m.eta > 0 {
+			timing += " • ETA " + formatSeconds(m.eta)
+		}
+		timing = "\n  " + currentStatusStyle.Render(timing)
+	}
+
+	help := "\n\n" + questionHelpStyle.Render("  l view log • q quit")
+	return tailOut + variantsOut + m.checklist() + spin + info + gap + prog + pkgCount + timing + help
+}
+
+// Shows the steps around the running one, with how long they took
+// or how long they will take.
+func (m model) checklist() string {
+	if len(m.steps) == 0 {
+		return ""
+	}
+
+	current := len(m.steps) - 1
+	for i, step := range m.steps {
+		if step.status != "done" && step.status != "skipped" {
+			current = i
+			break
+		}
+	}
+
+	lo := max(0, current-STEPS_SHOWN/2)
+	hi := lo + STEPS_SHOWN
+	if hi > len(m.steps) {
+		hi = len(m.steps)
+		lo = max(0, hi-STEPS_SHOWN)
+	}
+
+	out := ""
+	if lo > 0 {
+		out += questionHelpStyle.Render(fmt.Sprintf("    ... %d more done", lo)) + "\n"
+	}
+	for _, step := range m.steps[lo:hi] {
+		mark := questionHelpStyle.Render("·")
+		timing, bar := "", ""
+		switch step.status {
+		case "done":
+			mark = checkMark.String()
+			timing = formatSeconds(step.elapsed)
+		case "failed":
+			mark = crossMark.String()
+			timing = formatSeconds(step.elapsed)
+		case "skipped":
+			mark = questionHelpStyle.Render("-")
+			timing = "skipped"
+		case "running":
+			mark = currentStatusStyle.Render("▸")
+			timing = formatSeconds(step.elapsed)
+			if step.eta > 0 {
+				timing += ", " + formatSeconds(step.eta) + " left"
+			}
+			if step.percent >= 0 {
+				bar = m.stepBar.ViewAs(step.percent/100) + " "
+				timing = strings.TrimSpace(step.count + " " + timing)
+			}
+		default:
+			if step.eta > 0 {
+				timing = "~" + formatSeconds(step.eta)
+			}
+		}
+		out += fmt.Sprintf("  %s %s %s%s\n", mark, rowTitleStyle.Render(step.name), bar, questionHelpStyle.Render(timing))
+	}
+	if hi < len(m.steps) {
+		out += questionHelpStyle.Render(fmt.Sprintf("    ... %d more to go", len(m.steps)-hi)) + "\n"
+	}
+	return out + "\n"
+}
+
+// The progress of a variant when the server builds a matrix.
+type variantRow struct {
+	name       string
+	status     string
+	percentage int
+}
+
+// A step of the build in the checklist, times are in seconds.
+type stepRow struct {
+	name    string
+	status  string
+	elapsed int64
+	eta     int64
+
+	// Progress from the output of the step, -1 if there is none.
+	percent float64
+	count   string
 }
 
 type statusJson string
 type errorCode SSHShellCode
 
-func withErrorQuit(shell *SSHShellContext, code SSHShellCode) tea.Cmd {
+func withErrorQuit(stream *BuildStream, code SSHShellCode) tea.Cmd {
 	d := time.Second * time.Duration(5)
 	return tea.Tick(d, func(t time.Time) tea.Msg {
-		shell.SetCode(code)
+		stream.SetCode(code)
 		return errorCode(code)
 	})
 }
 
-func refreshProgress(shell *SSHShellContext) tea.Cmd {
-	d := time.Second * time.Duration(2)
-	return tea.Tick(d, func(t time.Time) tea.Msg {
-		out, err := shell.Exec("ham build-status | cat |  grep -a Status | cut -c 10-")
-		if err != nil {
-			shell.SetCode(SSH_SHELL_CANNOT_CONNECT)
-			return errorCode(SSH_SHELL_CANNOT_CONNECT)
+// Waits for the daemon to push the next status.
+func refreshProgress(stream *BuildStream) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case out := <-stream.Status:
+			return statusJson(out)
+		case <-stream.Done:
+			return errorCode(stream.Code())
 		}
+	}
+}
+
+type outputContent string
 
-		if len(out) == 0 {
-			return statusJson("{\"error\": true, \"message\": \"Remote Server not Responding Build Status\"}")
+func refreshOutput(stream *BuildStream) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case out := <-stream.Log:
+			return outputContent(out)
+		case <-stream.Done:
+			return nil
 		}
-		return statusJson(out)
-	})
+	}
 }
 
-type outputContent string
+// Formats seconds like 1h05m or 3m20s.
+func formatSeconds(seconds int64) string {
+	if seconds < 0 {
+		return "?"
+	}
 
-func refreshOutput(tail chan string) tea.Cmd {
-	d := time.Millisecond * time.Duration(200)
-	return tea.Tick(d, func(t time.Time) tea.Msg {
-		out := <-tail
-		return outputContent(out)
-	})
+	h, m, s := seconds/3600, (seconds%3600)/60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%dh%02dm", h, m)
+	}
+	if m > 0 {
+		return fmt.Sprintf("%dm%02ds", m, s)
+	}
+	return fmt.Sprintf("%ds", s)
 }
 
 func max(a, b int) int {
@@ -211,8 +445,12 @@ func max(a, b int) int {
 	return b
 }
 
-func runProgressTeaProgram(shell *SSHShellContext, tail chan string) error {
-	if _, err := tea.NewProgram(newModel(shell, tail)).Run(); err != nil {
+func runProgressTeaProgram(stream *BuildStream) error {
+	if helpers.IsCI() {
+		return runProgressCI(stream)
+	}
+
+	if _, err := tea.NewProgram(newModel(stream)).Run(); err != nil {
 		return err
 	}
 
@@ -0,0 +1,92 @@
+package get
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/antony-jr/ham/internal/core"
+	"github.com/antony-jr/ham/internal/helpers"
+)
+
+const (
+	RECIPE_UPLOAD_COMMAND = "rm -rf /ham-recipe && mkdir -p /ham-recipe && tar -x -p --no-same-owner -f - -C /ham-recipe"
+)
+
+// Counts the bytes written through it and reports them
+// from time to time.
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	reported time.Time
+	report   func(written int64)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+
+	if time.Since(pw.reported) > time.Millisecond*time.Duration(200) {
+		pw.reported = time.Now()
+		pw.report(pw.written)
+	}
+	return n, err
+}
+
+// Uploads the recipe to /ham-recipe at the remote as a single tar
+// stream, which is extracted as it arrives. Git recipes are sent
+// with their .git directory so the remote gets the exact checkout
+// that was cloned and hashed here.
+func uploadRecipeTar(shell *SSHShellContext, recipe *core.RecipeSource, spinnerMsg *TUISpinnerMessenger) error {
+	var files []core.RecipeFile
+	var err error
+	if recipe.UsedGit {
+		files, err = core.ListCheckoutFiles(recipe.Dir)
+	} else {
+		files, err = core.ListRecipeFiles(recipe.Dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	total := core.RecipeFilesSize(files)
+
+	report := func(written int64) {
+		if written > total {
+			written = total
+		}
+		spinnerMsg.UpdateMessage(fmt.Sprintf("Uploading Recipe to Remote Server... %s / %s ",
+			helpers.HumanBytes(written),
+			helpers.HumanBytes(total)))
+	}
+
+	tries := 0
+	for {
+		tries++
+
+		reader, writer := io.Pipe()
+		go func() {
+			pw := &progressWriter{
+				w:      writer,
+				report: report,
+			}
+			writer.CloseWithError(core.WriteRecipeTar(files, pw))
+		}()
+
+		out, err := shell.ExecWithStdin(RECIPE_UPLOAD_COMMAND, reader)
+		reader.Close()
+		if err == nil {
+			break
+		}
+
+		if tries > 5 {
+			return errors.New("Recipe Upload Failed (" + strings.TrimSpace(out) + ")")
+		}
+		time.Sleep(time.Second * time.Duration(2))
+	}
+
+	report(total)
+	return nil
+}
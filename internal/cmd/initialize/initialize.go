@@ -124,9 +124,9 @@ func NewCommand() *cli.Command {
 			_, _, err = client.SSHKey.Create(
 				context.Background(),
 				hcloud.SSHKeyCreateOpts{
-					"ham-ssh-key",
-					config.SSHPublicKey,
-					labels,
+					Name:      "ham-ssh-key",
+					PublicKey: config.SSHPublicKey,
+					Labels:    labels,
 				},
 			)
 
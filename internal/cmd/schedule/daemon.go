@@ -0,0 +1,200 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antony-jr/ham/internal/core"
+	"github.com/antony-jr/ham/internal/helpers"
+	"github.com/mkideal/cli"
+)
+
+type daemonT struct {
+	cli.Helper
+}
+
+// Runs the schedules which are due, every schedule runs at most
+// once at a time.
+type scheduler struct {
+	mutex   sync.Mutex
+	running map[int]bool
+}
+
+func NewDaemonCommand() *cli.Command {
+	return &cli.Command{
+		Name: "daemon",
+		Desc: "Run Scheduled Builds, Keep it Running in the Background",
+		Text: `
+Syntax: ham daemon
+
+Every Minute the Schedules (See ham schedule) are checked and the
+due ones are built with ham get --no-confirm and their Answers File.
+A run is skipped if the recipe did not change since the last
+successful build. Runs are logged, see ham schedule log.`,
+		Argv: func() interface{} { return new(daemonT) },
+		Fn: func(ctx *cli.Context) error {
+			executable, err := os.Executable()
+			if err != nil {
+				return err
+			}
+
+			runsDir, err := helpers.ScheduleRunsDir()
+			if err != nil {
+				return err
+			}
+			err = os.MkdirAll(runsDir, 0700)
+			if err != nil {
+				return err
+			}
+
+			s := &scheduler{
+				running: map[int]bool{},
+			}
+
+			fmt.Printf("%s HAM Daemon Started\n", time.Now().Format(time.RFC3339))
+			for {
+				// Wake up at the start of every minute.
+				now := time.Now()
+				time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+				now = time.Now().Truncate(time.Minute)
+
+				schedules, err := core.GetSchedules()
+				if err != nil {
+					fmt.Printf("%s %s\n", now.Format(time.RFC3339), err.Error())
+					continue
+				}
+
+				for _, schedule := range schedules {
+					cron, err := core.ParseCron(schedule.Cron)
+					if err != nil || !cron.Matches(now) {
+						continue
+					}
+
+					if !s.start(schedule.ID) {
+						fmt.Printf("%s Schedule %d is Still Running, Skipped.\n", now.Format(time.RFC3339), schedule.ID)
+						continue
+					}
+
+					go func(schedule core.Schedule) {
+						defer s.done(schedule.ID)
+						s.run(executable, runsDir, schedule)
+					}(schedule)
+				}
+			}
+		},
+	}
+}
+
+func (s *scheduler) start(id int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running[id] {
+		return false
+	}
+	s.running[id] = true
+	return true
+}
+
+func (s *scheduler) done(id int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.running, id)
+}
+
+func (s *scheduler) run(executable string, runsDir string, schedule core.Schedule) {
+	run := core.ScheduleRun{
+		Schedule: schedule.ID,
+		Recipe:   schedule.Recipe,
+		Started:  time.Now(),
+	}
+
+	revision, err := schedule.Revision()
+	run.Revision = revision
+	if err != nil {
+		run.Status = "failed"
+		run.Message = "Cannot Check Recipe (" + err.Error() + ")"
+	} else if revision == schedule.LastRevision && !schedule.IfChanged {
+		run.Status = "skipped"
+		run.Message = "Recipe did not Change"
+	} else {
+		run.Output = filepath.Join(runsDir, fmt.Sprintf("%d-%s.log", schedule.ID, run.Started.Format("20060102-1504")))
+		err = runGet(executable, run.Output, schedule)
+		var exitErr *helpers.ExitError
+		if errors.As(err, &exitErr) && exitErr.Code == helpers.EXIT_NO_CHANGES {
+			run.Status = "skipped"
+			run.Message = "Watched Sources did not Change"
+		} else if err != nil {
+			run.Status = "failed"
+			run.Message = err.Error()
+		} else {
+			run.Status = "successful"
+		}
+	}
+	run.Finished = time.Now()
+
+	fmt.Printf("%s Schedule %d: %s %s\n", run.Finished.Format(time.RFC3339), run.Schedule, run.Status, run.Message)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = core.AppendScheduleLog(run)
+	if err != nil {
+		fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), err.Error())
+	}
+
+	// Skipped runs don't change anything.
+	if run.Status != "skipped" {
+		err = core.UpdateScheduleRun(run)
+		if err != nil {
+			fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), err.Error())
+		}
+	}
+}
+
+// Runs ham get for the schedule without asking anything, the
+// output goes to outputPath.
+func runGet(executable string, outputPath string, schedule core.Schedule) error {
+	args := []string{"get", schedule.Recipe, "--no-confirm", "--ci"}
+	if len(schedule.Answers) != 0 {
+		args = append(args, "--answers", schedule.Answers)
+	}
+	if len(schedule.Registry) != 0 {
+		args = append(args, "--registry", schedule.Registry)
+	}
+	if schedule.Parallel {
+		args = append(args, "--parallel")
+	}
+	if schedule.IfChanged {
+		args = append(args, "--if-changed")
+	}
+
+	output, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	fmt.Fprintf(output, "$ ham %s\n", strings.Join(args, " "))
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err = cmd.Run()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return helpers.NewExitError(exitErr.ExitCode(), fmt.Sprintf("ham get Exited with %d", exitErr.ExitCode()))
+		}
+		return err
+	}
+	return nil
+}
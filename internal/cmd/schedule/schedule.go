@@ -0,0 +1,260 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/antony-jr/ham/internal/core"
+	"github.com/mkideal/cli"
+)
+
+type scheduleT struct {
+	cli.Helper
+}
+
+type scheduleAddT struct {
+	cli.Helper
+	Cron      string `cli:"*c,cron" usage:"Cron Expression of when to Build, like \"0 3 1 * *\" for 3 AM on the First of every Month."`
+	Answers   string `cli:"a,answers" usage:"Path to a Answers File (JSON or YAML) to Answer required Questions."`
+	Registry  string `cli:"r,registry" usage:"URL or Path of the Recipe Registry Index used to find Recipes by Name."`
+	Parallel  bool   `cli:"p,parallel" usage:"Build each Variant of the Recipe Matrix on it's own Server."`
+	IfChanged bool   `cli:"if-changed" usage:"Also Build when the Remotes the Recipe Watches Changed, See ham get --if-changed."`
+}
+
+type scheduleListT struct {
+	cli.Helper
+}
+
+type scheduleRemoveT struct {
+	cli.Helper
+}
+
+type scheduleLogT struct {
+	cli.Helper
+	Count int `cli:"n,count" usage:"Number of Runs to Show, Latest First." dft:"20"`
+}
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name: "schedule",
+		Desc: "Build Recipes Again and Again on a Schedule with ham daemon",
+		Text: `
+Syntax: ham schedule [add|list|remove|log]
+
+Build on the First of every Month at 3 AM:
+   ham schedule add ~@gh/enchilada_los18.1 --cron "0 3 1 * *" -a answers.yml
+
+List and Remove Schedules:
+   ham schedule list
+   ham schedule remove 1
+
+Show the Last Scheduled Runs:
+   ham schedule log
+
+Schedules are only run while ham daemon is running. A run is
+skipped if the recipe did not change since the last successful
+build.`,
+		Argv: func() interface{} { return new(scheduleT) },
+		Fn: func(ctx *cli.Context) error {
+			ctx.WriteUsage()
+			return nil
+		},
+	}
+}
+
+func NewAddCommand() *cli.Command {
+	return &cli.Command{
+		Name: "add",
+		Desc: "Schedule a Recipe to be Built",
+		Argv: func() interface{} { return new(scheduleAddT) },
+		NumArg: func(n int) bool {
+			return n == 1
+		},
+		Fn: func(ctx *cli.Context) error {
+			argv := ctx.Argv().(*scheduleAddT)
+			args := ctx.Args()
+			if len(args) != 1 {
+				return nil
+			}
+
+			recipe := args[0]
+			if !core.IsRemoteRecipe(recipe) {
+				// The daemon can run from any directory.
+				abs, err := filepath.Abs(recipe)
+				if err != nil {
+					return err
+				}
+				recipe = abs
+			}
+
+			answers := argv.Answers
+			if len(answers) != 0 {
+				abs, err := filepath.Abs(answers)
+				if err != nil {
+					return err
+				}
+				if _, err := os.Stat(abs); err != nil {
+					return errors.New("Cannot Read Answers File " + answers)
+				}
+				answers = abs
+			}
+
+			cron, err := core.ParseCron(argv.Cron)
+			if err != nil {
+				return err
+			}
+
+			id, err := core.AddSchedule(core.Schedule{
+				Recipe:    recipe,
+				Cron:      argv.Cron,
+				Answers:   answers,
+				Registry:  argv.Registry,
+				Parallel:  argv.Parallel,
+				IfChanged: argv.IfChanged,
+			})
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Added Schedule %d, Next Run at %s.\n", id, formatTime(cron.Next(time.Now())))
+			if len(answers) == 0 {
+				fmt.Println("No Answers File Given, Scheduled Runs will Fail if the Recipe has Questions.")
+			}
+			fmt.Println("Schedules are only run while ham daemon is running.")
+			return nil
+		},
+	}
+}
+
+func NewListCommand() *cli.Command {
+	return &cli.Command{
+		Name: "list",
+		Desc: "List all Schedules",
+		Argv: func() interface{} { return new(scheduleListT) },
+		Fn: func(ctx *cli.Context) error {
+			schedules, err := core.GetSchedules()
+			if err != nil {
+				return err
+			}
+
+			if len(schedules) == 0 {
+				fmt.Println("No Schedules, Add one with ham schedule add.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "ID\tCRON\tNEXT RUN\tLAST RUN\tLAST STATUS\tRECIPE")
+			for _, s := range schedules {
+				next := "Never"
+				cron, err := core.ParseCron(s.Cron)
+				if err == nil {
+					next = formatTime(cron.Next(time.Now()))
+				}
+
+				status := s.LastStatus
+				if len(status) == 0 {
+					status = "-"
+				}
+
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+					s.ID,
+					s.Cron,
+					next,
+					formatTime(s.LastRun),
+					status,
+					s.Recipe)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func NewRemoveCommand() *cli.Command {
+	return &cli.Command{
+		Name: "remove",
+		Desc: "Remove a Schedule by it's ID",
+		Argv: func() interface{} { return new(scheduleRemoveT) },
+		NumArg: func(n int) bool {
+			return n == 1
+		},
+		Fn: func(ctx *cli.Context) error {
+			args := ctx.Args()
+			if len(args) != 1 {
+				return nil
+			}
+
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				return errors.New("Invalid Schedule ID " + args[0])
+			}
+
+			err = core.RemoveSchedule(id)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Removed Schedule %d.\n", id)
+			return nil
+		},
+	}
+}
+
+func NewLogCommand() *cli.Command {
+	return &cli.Command{
+		Name: "log",
+		Desc: "Show the Latest Scheduled Runs",
+		Argv: func() interface{} { return new(scheduleLogT) },
+		Fn: func(ctx *cli.Context) error {
+			argv := ctx.Argv().(*scheduleLogT)
+
+			runs, err := core.ReadScheduleLog()
+			if err != nil {
+				return err
+			}
+
+			if len(runs) == 0 {
+				fmt.Println("No Scheduled Runs Yet.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "SCHEDULE\tSTARTED\tTOOK\tSTATUS\tREVISION\tMESSAGE")
+			for i := len(runs) - 1; i >= 0 && i >= len(runs)-argv.Count; i-- {
+				run := runs[i]
+
+				revision := run.Revision
+				if len(revision) > 12 {
+					revision = revision[:12]
+				}
+
+				message := run.Message
+				if len(run.Output) != 0 {
+					message += " (" + run.Output + ")"
+				}
+
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+					run.Schedule,
+					formatTime(run.Started),
+					run.Finished.Sub(run.Started).Round(time.Second),
+					run.Status,
+					revision,
+					message)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,124 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/antony-jr/ham/internal/core"
+	"github.com/mkideal/cli"
+)
+
+type searchT struct {
+	cli.Helper
+	Registry string `cli:"r,registry" usage:"URL or Path of the Recipe Registry Index to Search."`
+	Json     bool   `cli:"j,json" usage:"Print the matching Recipes as JSON."`
+}
+
+type recipesT struct {
+	cli.Helper
+	Registry string `cli:"r,registry" usage:"URL or Path of the Recipe Registry Index to List."`
+	Json     bool   `cli:"j,json" usage:"Print the Recipes as JSON."`
+}
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name: "search",
+		Desc: "Search the Recipe Registry by Device Codename, Name or OS",
+		Text: `
+Syntax: ham search [QUERY]
+
+Search by Device Codename:
+   ham search enchilada
+
+Search with more than one Word:
+   ham search enchilada lineage 19.1
+
+Search a different Registry:
+   ham search -r ./index.json enchilada`,
+		Argv: func() interface{} { return new(searchT) },
+		NumArg: func(n int) bool {
+			return n > 0
+		},
+		Fn: func(ctx *cli.Context) error {
+			argv := ctx.Argv().(*searchT)
+			query := strings.Join(ctx.Args(), " ")
+
+			registry, err := core.FetchRegistry(argv.Registry)
+			if err != nil {
+				return err
+			}
+
+			results := registry.Search(query)
+			if argv.Json {
+				return printJson(results)
+			}
+
+			if len(results) == 0 {
+				fmt.Printf("No Recipes Found for '%s'.\n", query)
+				return nil
+			}
+
+			printTable(results)
+			return nil
+		},
+	}
+}
+
+func NewRecipesCommand() *cli.Command {
+	return &cli.Command{
+		Name: "recipes",
+		Desc: "List all Recipes in the Recipe Registry",
+		Argv: func() interface{} { return new(recipesT) },
+		Fn: func(ctx *cli.Context) error {
+			argv := ctx.Argv().(*recipesT)
+
+			registry, err := core.FetchRegistry(argv.Registry)
+			if err != nil {
+				return err
+			}
+
+			results := registry.Search("")
+			if argv.Json {
+				return printJson(results)
+			}
+
+			if len(results) == 0 {
+				fmt.Println("Registry has no Recipes.")
+				return nil
+			}
+
+			printTable(results)
+			return nil
+		},
+	}
+}
+
+func printJson(results []core.RegistryEntry) error {
+	out, err := json.MarshalIndent(results, "", "   ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+func printTable(results []core.RegistryEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDEVICE\tOS\tMAINTAINER\tTITLE")
+	for _, entry := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s %s\t%s\t%s\n",
+			entry.Name,
+			entry.Device,
+			entry.OS,
+			entry.OSVersion,
+			entry.Maintainer,
+			entry.Title)
+	}
+	w.Flush()
+
+	fmt.Printf("\nGet a Recipe with: ham get <NAME>\n")
+}
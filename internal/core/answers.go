@@ -0,0 +1,257 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/antony-jr/ham/internal/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Prefix for answers that should be read from a file
+	// instead of being given inline, useful for secrets.
+	AnswerFileRefPrefix = "file:"
+)
+
+// Answers for the questions asked by a recipe, keyed by the
+// id of the argument.
+type Answers map[string]string
+
+var envRefRegex = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// A answers file, the answers at the top are for every recipe and a
+// map under the name of a recipe is only for that recipe,
+//
+//	github_token: "${GITHUB_TOKEN}"
+//	~@gh/enchilada-los19.1:
+//	  device_name: enchilada
+type AnswersFile struct {
+	Shared  Answers
+	Recipes map[string]Answers
+}
+
+// Reads a answers file in JSON or YAML format. The format is
+// guessed from the file extension, anything other than .yml or
+// .yaml is treated as JSON. Values can be strings, numbers or
+// bools and are always converted to strings.
+func ReadAnswersFile(path string) (AnswersFile, error) {
+	file := AnswersFile{
+		Shared:  Answers{},
+		Recipes: map[string]Answers{},
+	}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return file, err
+	}
+
+	var result map[string]interface{}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yml" || ext == ".yaml" {
+		err = yaml.Unmarshal(source, &result)
+	} else {
+		err = json.Unmarshal(source, &result)
+	}
+	if err != nil {
+		return file, errors.New("Cannot Parse Answers File (" + err.Error() + ")")
+	}
+
+	for key, value := range result {
+		section, ok := value.(map[string]interface{})
+		if !ok {
+			str, err := helpers.StringifyValue(key, value)
+			if err != nil {
+				return file, err
+			}
+			file.Shared[key] = str
+			continue
+		}
+
+		answers := Answers{}
+		for arg, value := range section {
+			str, err := helpers.StringifyValue(key+"."+arg, value)
+			if err != nil {
+				return file, err
+			}
+			answers[arg] = str
+		}
+		file.Recipes[key] = answers
+	}
+
+	return file, nil
+}
+
+// Returns the answers for the recipe known by any of the names, it's
+// own answers win over the shared ones. A shared answer the recipe
+// does not ask for is left out when another recipe built with it
+// asks for it, so one file can answer several recipes.
+func (f AnswersFile) ForRecipe(hf *HAMFile, names []string, otherArgs map[string]bool) Answers {
+	answers := Answers{}
+	for key, value := range f.Shared {
+		if hf.GetArg(key) == nil && otherArgs[key] {
+			continue
+		}
+		answers[key] = value
+	}
+
+	for _, name := range names {
+		for key, value := range f.Recipes[name] {
+			answers[key] = value
+		}
+	}
+	return answers
+}
+
+// Replaces ${NAME} with the value of the environmental variable
+// NAME, $$ can be used to write a literal $. Referring to a
+// variable which is not set is an error.
+func ExpandAnswerEnv(value string) (string, error) {
+	var err error
+	expanded := envRefRegex.ReplaceAllStringFunc(value, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+
+		name := ref[2 : len(ref)-1]
+		env, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = errors.New(fmt.Sprintf("Environmental Variable '%s' is not Set.", name))
+		}
+		return env
+	})
+
+	return expanded, err
+}
+
+// Expands environmental variables and file references in all
+// answers, the type of the argument decides what a file reference
+// means. For file arguments it's simply the path to the file, for
+// every other argument the contents of the file is the value.
+func (hf *HAMFile) ResolveAnswers(answers Answers) (Answers, error) {
+	resolved := Answers{}
+
+	for key, value := range answers {
+		value, err := ExpandAnswerEnv(value)
+		if err != nil {
+			return resolved, errors.New(fmt.Sprintf("Answer '%s': %s", key, err.Error()))
+		}
+
+		if strings.HasPrefix(value, AnswerFileRefPrefix) {
+			path := strings.TrimPrefix(value, AnswerFileRefPrefix)
+			arg := hf.GetArg(key)
+			if arg != nil && arg.IsPath() {
+				value = path
+			} else {
+				source, err := ioutil.ReadFile(path)
+				if err != nil {
+					return resolved, errors.New(fmt.Sprintf("Answer '%s': Cannot Read %s (%s)", key, path, err.Error()))
+				}
+				value = strings.TrimRight(string(source), "\r\n")
+			}
+		}
+
+		resolved[key] = value
+	}
+
+	return resolved, nil
+}
+
+// Checks the answers against the arguments of the recipe. Answers
+// for arguments the recipe does not have and answers which does
+// not fit the type of the argument are always errors, required
+// arguments with no answer or default are only errors when
+// requireAll is set since otherwise the user is asked for them.
+func (hf *HAMFile) ValidateAnswers(answers Answers, requireAll bool) error {
+	problems := []string{}
+
+	keys := make([]string, 0, len(answers))
+	for key := range answers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		arg := hf.GetArg(key)
+		if arg == nil {
+			problems = append(problems, fmt.Sprintf("Unknown answer '%s', the recipe does not ask for it.", key))
+			continue
+		}
+
+		_, err := arg.Normalize(answers[key])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Answer '%s': %s.", key, err.Error()))
+		}
+	}
+
+	if requireAll {
+		for _, arg := range hf.Args {
+			if !arg.IsRequired() {
+				continue
+			}
+
+			if len(answers[arg.ID]) == 0 && len(arg.Default) == 0 {
+				problems = append(problems, fmt.Sprintf("Required answer '%s' is missing.", arg.ID))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return errors.New("Invalid Answers File\n - " + strings.Join(problems, "\n - "))
+}
+
+// Returns a answers file for this recipe with every argument
+// set to it's default, to be filled by the user.
+func (hf *HAMFile) AnswersTemplate(asJson bool) (string, error) {
+	if asJson {
+		template := map[string]string{}
+		for _, arg := range hf.Args {
+			template[arg.ID] = arg.Default
+		}
+
+		out, err := json.MarshalIndent(template, "", "   ")
+		if err != nil {
+			return "", err
+		}
+		return string(out) + "\n", nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Answers for %s [%s]\n", hf.Title, hf.Version)
+	fmt.Fprintf(&b, "# Use with: ham get --answers <this file> <recipe>\n")
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "# ${NAME} is replaced with the environmental variable NAME and a value\n")
+	fmt.Fprintf(&b, "# of %s<path> is replaced with the contents of the file at <path>.\n", AnswerFileRefPrefix)
+
+	for _, arg := range hf.Args {
+		required := "optional"
+		if arg.IsRequired() {
+			required = "required"
+		}
+
+		fmt.Fprintf(&b, "\n# %s (%s, %s)\n", arg.Prompt, arg.Describe(), required)
+		if len(arg.Help) != 0 {
+			for _, line := range strings.Split(strings.TrimSpace(arg.Help), "\n") {
+				fmt.Fprintf(&b, "# %s\n", line)
+			}
+		}
+
+		value, err := yaml.Marshal(map[string]string{arg.ID: arg.Default})
+		if err != nil {
+			return "", err
+		}
+		b.Write(value)
+	}
+
+	return b.String(), nil
+}
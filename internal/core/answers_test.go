@@ -0,0 +1,208 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadAnswersFile(t *testing.T) {
+	want := AnswersFile{
+		Shared: Answers{
+			"github_token": "${GITHUB_TOKEN}",
+			"jobs":         "16",
+			"sign":         "true",
+			"empty":        "",
+		},
+		Recipes: map[string]Answers{
+			"~@gh/enchilada-los19.1": {
+				"device_name": "enchilada",
+				"ratio":       "1.5",
+			},
+		},
+	}
+
+	tests := map[string]string{
+		"answers.yml": `
+github_token: "${GITHUB_TOKEN}"
+jobs: 16
+sign: true
+empty:
+~@gh/enchilada-los19.1:
+  device_name: enchilada
+  ratio: 1.5
+`,
+		"answers.YAML": `
+github_token: "${GITHUB_TOKEN}"
+jobs: 16
+sign: true
+empty: null
+"~@gh/enchilada-los19.1": {device_name: enchilada, ratio: 1.5}
+`,
+		"answers.json": `{
+   "github_token": "${GITHUB_TOKEN}",
+   "jobs": 16,
+   "sign": true,
+   "empty": null,
+   "~@gh/enchilada-los19.1": {"device_name": "enchilada", "ratio": 1.5}
+}`,
+	}
+
+	for name, content := range tests {
+		got, err := ReadAnswersFile(writeTestFile(t, name, content))
+		if err != nil {
+			t.Errorf("ReadAnswersFile(%s) failed (%s)", name, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadAnswersFile(%s) = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestReadAnswersFileInvalid(t *testing.T) {
+	tests := map[string]string{
+		"list.yml":    "jobs: [1, 2]\n",
+		"nested.yml":  "recipe:\n  jobs: [1, 2]\n",
+		"broken.json": "{\"jobs\": ",
+		"yaml.json":   "jobs: 16\n",
+	}
+
+	for name, content := range tests {
+		_, err := ReadAnswersFile(writeTestFile(t, name, content))
+		if err == nil {
+			t.Errorf("ReadAnswersFile(%s) did not fail", name)
+		}
+	}
+
+	_, err := ReadAnswersFile(filepath.Join(t.TempDir(), "missing.yml"))
+	if err == nil {
+		t.Errorf("ReadAnswersFile of a missing file did not fail")
+	}
+}
+
+func TestAnswersForRecipe(t *testing.T) {
+	file := AnswersFile{
+		Shared: Answers{"device_name": "fajita", "jobs": "8", "other": "x"},
+		Recipes: map[string]Answers{
+			"~@gh/enchilada": {"device_name": "enchilada"},
+			"enchilada":      {"jobs": "16"},
+		},
+	}
+	hf := &HAMFile{Args: []HAMArg{{ID: "device_name"}, {ID: "jobs"}}}
+
+	got := file.ForRecipe(hf, []string{"~@gh/enchilada", "enchilada"}, map[string]bool{"other": true})
+	want := Answers{"device_name": "enchilada", "jobs": "16"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ForRecipe() = %v, want %v", got, want)
+	}
+
+	// Without other recipes a unknown answer is kept, so it's
+	// reported.
+	got = file.ForRecipe(hf, nil, nil)
+	want = Answers{"device_name": "fajita", "jobs": "8", "other": "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ForRecipe() = %v, want %v", got, want)
+	}
+}
+
+func TestExpandAnswerEnv(t *testing.T) {
+	t.Setenv("HAM_TEST_TOKEN", "secret")
+	t.Setenv("HAM_TEST_EMPTY", "")
+
+	tests := []struct {
+		value string
+		want  string
+		err   bool
+	}{
+		{"plain", "plain", false},
+		{"${HAM_TEST_TOKEN}", "secret", false},
+		{"token-${HAM_TEST_TOKEN}-${HAM_TEST_TOKEN}", "token-secret-secret", false},
+		{"${HAM_TEST_EMPTY}", "", false},
+		{"$$", "$", false},
+		{"$${HAM_TEST_TOKEN}", "${HAM_TEST_TOKEN}", false},
+		{"$$$${HAM_TEST_TOKEN}", "$${HAM_TEST_TOKEN}", false},
+		{"$$${HAM_TEST_TOKEN}", "$secret", false},
+
+		// Only ${NAME} is expanded.
+		{"$HAM_TEST_TOKEN", "$HAM_TEST_TOKEN", false},
+		{"${1}", "${1}", false},
+		{"${HAM_TEST_MISSING}", "", true},
+		{"${HAM_TEST_TOKEN}${HAM_TEST_MISSING}", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := ExpandAnswerEnv(test.value)
+		if test.err {
+			if err == nil {
+				t.Errorf("ExpandAnswerEnv(%q) = %q, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ExpandAnswerEnv(%q) failed (%s)", test.value, err.Error())
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ExpandAnswerEnv(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestResolveAnswers(t *testing.T) {
+	keystore := writeTestFile(t, "keystore", "keystore\n")
+	password := writeTestFile(t, "password", "hunter2\r\n")
+	t.Setenv("HAM_TEST_DIR", filepath.Dir(password))
+
+	hf := &HAMFile{Args: []HAMArg{
+		{ID: "keystore", Type: "file"},
+		{ID: "password", Type: "secret"},
+	}}
+
+	got, err := hf.ResolveAnswers(Answers{
+		"keystore": "file:" + keystore,
+		"password": "file:${HAM_TEST_DIR}/password",
+		"unknown":  "file:" + password,
+		"literal":  "$$file:x",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Answers{
+		"keystore": keystore,
+		"password": "hunter2",
+		"unknown":  "hunter2",
+		"literal":  "$file:x",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveAnswers() = %v, want %v", got, want)
+	}
+
+	// A file argument is only a path, it's checked when validated.
+	missing := filepath.Join(t.TempDir(), "missing")
+	_, err = hf.ResolveAnswers(Answers{"keystore": "file:" + missing})
+	if err != nil {
+		t.Errorf("ResolveAnswers() of a missing file argument failed (%s)", err.Error())
+	}
+
+	_, err = hf.ResolveAnswers(Answers{"password": "file:" + missing})
+	if err == nil {
+		t.Errorf("ResolveAnswers() of a missing file did not fail")
+	}
+
+	_, err = hf.ResolveAnswers(Answers{"password": "${HAM_TEST_MISSING}"})
+	if err == nil {
+		t.Errorf("ResolveAnswers() of a missing variable did not fail")
+	}
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type HAMArg struct {
+	ID       string   `yaml:"id"`
+	Prompt   string   `yaml:"prompt"`
+	Required *bool    `yaml:"required,omitempty"`
+	Type     string   `yaml:"type"`
+	Help     string   `yaml:"help"`
+	Default  string   `yaml:"default"`
+	Validate string   `yaml:"validate"`
+	Options  []string `yaml:"options"`
+	Min      *int     `yaml:"min"`
+	Max      *int     `yaml:"max"`
+}
+
+var argTypes = []string{
+	"value",
+	"secret",
+	"file",
+	"dir",
+	"choice",
+	"bool",
+	"int",
+}
+
+// Returns the name of the environmental variable which holds
+// the value of the argument with the given id during the build.
+func ArgEnvName(id string) string {
+	name := strings.ToUpper(id)
+	name = strings.ReplaceAll(name, " ", "_")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func (arg *HAMArg) IsRequired() bool {
+	if arg.Required == nil {
+		return false
+	}
+	return *arg.Required
+}
+
+// Returns the lower cased type of the argument, arguments
+// without a type are plain values.
+func (arg *HAMArg) TypeName() string {
+	argType := strings.ToLower(arg.Type)
+	if argType == "" {
+		return "value"
+	}
+	return argType
+}
+
+// The variable type decides how a answer is transported to
+// the build server, files and directories are uploaded and
+// everything else is given as is.
+func (arg *HAMArg) VariableType() VariableType {
+	switch arg.TypeName() {
+	case "file":
+		return VARIABLE_TYPE_FILE_PATH
+	case "dir":
+		return VARIABLE_TYPE_DIR_PATH
+	case "secret":
+		return VARIABLE_TYPE_SECRET
+	}
+	return VARIABLE_TYPE_VALUE
+}
+
+func (arg *HAMArg) IsPath() bool {
+	ty := arg.VariableType()
+	return ty == VARIABLE_TYPE_FILE_PATH || ty == VARIABLE_TYPE_DIR_PATH
+}
+
+// Returns a short description of the type used when
+// asking the user.
+func (arg *HAMArg) Describe() string {
+	switch arg.TypeName() {
+	case "choice":
+		return "choice: " + strings.Join(arg.Options, ", ")
+	case "int":
+		if arg.Min != nil && arg.Max != nil {
+			return fmt.Sprintf("int: %d to %d", *arg.Min, *arg.Max)
+		} else if arg.Min != nil {
+			return fmt.Sprintf("int: %d or more", *arg.Min)
+		} else if arg.Max != nil {
+			return fmt.Sprintf("int: %d or less", *arg.Max)
+		}
+	}
+	return arg.TypeName()
+}
+
+// Checks if the argument itself is valid, this is to catch
+// mistakes by the recipe author early before asking anything.
+func (arg *HAMArg) Check() error {
+	if len(arg.ID) == 0 {
+		return errors.New("Recipe Argument without a ID")
+	}
+
+	known := false
+	for _, ty := range argTypes {
+		if ty == arg.TypeName() {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return errors.New(fmt.Sprintf("Unknown Type '%s' for Argument '%s'", arg.Type, arg.ID))
+	}
+
+	if arg.TypeName() == "choice" && len(arg.Options) == 0 {
+		return errors.New(fmt.Sprintf("Choice Argument '%s' has no Options", arg.ID))
+	}
+
+	if arg.Min != nil && arg.Max != nil && *arg.Min > *arg.Max {
+		return errors.New(fmt.Sprintf("Argument '%s' has min greater than max", arg.ID))
+	}
+
+	if len(arg.Validate) != 0 {
+		_, err := arg.validateRegex()
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid validate Regex for Argument '%s' (%s)", arg.ID, err.Error()))
+		}
+	}
+
+	// Paths are only meaningful on the machine of the user,
+	// the recipe is parsed on the build server too.
+	if len(arg.Default) != 0 && !arg.IsPath() {
+		_, err := arg.Normalize(arg.Default)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid default for Argument '%s' (%s)", arg.ID, err.Error()))
+		}
+	}
+
+	return nil
+}
+
+func (arg *HAMArg) validateRegex() (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + arg.Validate + ")$")
+}
+
+// Checks the given answer against the type of the argument and
+// returns it in the form it is given to the build. Empty answers
+// are returned as is, since only the caller knows if the
+// argument can be skipped.
+func (arg *HAMArg) Normalize(value string) (string, error) {
+	if len(value) == 0 {
+		return value, nil
+	}
+
+	switch arg.TypeName() {
+	case "bool":
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "true", "yes", "y", "on", "1":
+			value = "true"
+		case "false", "no", "n", "off", "0":
+			value = "false"
+		default:
+			return value, errors.New(fmt.Sprintf("'%s' is not a Bool", value))
+		}
+
+	case "int":
+		num, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return value, errors.New(fmt.Sprintf("'%s' is not a Integer", value))
+		}
+		if arg.Min != nil && num < *arg.Min {
+			return value, errors.New(fmt.Sprintf("%d is less than %d", num, *arg.Min))
+		}
+		if arg.Max != nil && num > *arg.Max {
+			return value, errors.New(fmt.Sprintf("%d is greater than %d", num, *arg.Max))
+		}
+		value = strconv.Itoa(num)
+
+	case "choice":
+		found := false
+		for _, option := range arg.Options {
+			if option == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return value, errors.New(fmt.Sprintf("'%s' is not one of %s", value, strings.Join(arg.Options, ", ")))
+		}
+
+	case "file", "dir":
+		info, err := os.Stat(value)
+		if err != nil {
+			return value, errors.New(fmt.Sprintf("'%s' does not exist", value))
+		}
+		if arg.TypeName() == "dir" && !info.IsDir() {
+			return value, errors.New(fmt.Sprintf("'%s' is not a Directory", value))
+		}
+		if arg.TypeName() == "file" && info.IsDir() {
+			return value, errors.New(fmt.Sprintf("'%s' is a Directory", value))
+		}
+	}
+
+	if len(arg.Validate) != 0 {
+		re, err := arg.validateRegex()
+		if err != nil {
+			return value, err
+		}
+		if !re.MatchString(value) {
+			if arg.VariableType() == VARIABLE_TYPE_SECRET {
+				return value, errors.New(fmt.Sprintf("Secret does not match %s", arg.Validate))
+			}
+			return value, errors.New(fmt.Sprintf("'%s' does not match %s", value, arg.Validate))
+		}
+	}
+
+	return value, nil
+}
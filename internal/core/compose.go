@@ -0,0 +1,496 @@
+package core
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// How deep includes and extends can be nested.
+	maxComposeDepth = 8
+)
+
+// ${{ args.name }}
+var templateRefRegex = regexp.MustCompile(`\$\{\{\s*args\.([A-Za-z0-9_-]+)\s*\}\}`)
+
+// A arg in the if of a step, which has no ${{ }}.
+var conditionArgRegex = regexp.MustCompile(`\bargs\.([A-Za-z0-9_-]+)`)
+
+// A reference to a base recipe or a YAML fragment, either a local
+// path relative to the file it is used in or a file in a git repo.
+// With gives the template arguments of the referenced file.
+type HAMRecipeRef struct {
+	Path string            `yaml:"path,omitempty"`
+	Git  string            `yaml:"git,omitempty"`
+	File string            `yaml:"file,omitempty"`
+	With map[string]string `yaml:"with,omitempty"`
+}
+
+// A reference can also be given as just a path.
+func (ref *HAMRecipeRef) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		ref.Path = value.Value
+		return nil
+	}
+
+	type plain HAMRecipeRef
+	return value.Decode((*plain)(ref))
+}
+
+func (ref *HAMRecipeRef) Location() string {
+	if len(ref.Git) != 0 {
+		if len(ref.File) == 0 {
+			return ref.Git
+		}
+		return ref.Git + "//" + ref.File
+	}
+	return ref.Path
+}
+
+// A recipe or fragment which is part of a composed recipe,
+// with the template arguments it was referenced with.
+type recipePiece struct {
+	hf     HAMFile
+	params map[string]string
+}
+
+type recipeComposer struct {
+	// Clones of git refs by their remote, so a repo used
+	// more than once is cloned only once.
+	clones  map[string]string
+	commits map[string]string
+
+	// Files currently being loaded, to catch cycles.
+	loading map[string]bool
+
+	pieces  []recipePiece
+	sources []string
+}
+
+func (c *recipeComposer) cleanup() {
+	for _, dir := range c.clones {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// Returns the path of the YAML file to use for a directory,
+// files are used as is.
+func composeFilePath(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !info.IsDir() {
+		return path, nil
+	}
+
+	fp, _, err := readHAMFileSource(path)
+	return fp, err
+}
+
+// Finds the file a reference points to, dir is the directory of
+// the file which has the reference and root is the directory it
+// must not escape from, empty if it can be anywhere.
+func (c *recipeComposer) locate(ref *HAMRecipeRef, dir string, root string) (string, string, string, error) {
+	if len(ref.Git) != 0 {
+		remote, err := ParseGitRemote(ref.Git)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		clone, ok := c.clones[ref.Git]
+		if !ok {
+			clone, err = os.MkdirTemp(os.TempDir(), "*-ham-include")
+			if err != nil {
+				return "", "", "", err
+			}
+			c.clones[ref.Git] = clone
+
+			commit, err := CloneGitRemote(remote, clone)
+			if err != nil {
+				return "", "", "", errors.New(fmt.Sprintf("Cannot Clone %s (%s)", ref.Git, err.Error()))
+			}
+			c.commits[ref.Git] = commit
+		}
+
+		fp, err := composeFilePath(filepath.Join(clone, filepath.FromSlash(ref.File)))
+		if err != nil {
+			return "", "", "", err
+		}
+
+		source := fmt.Sprintf("%s@%s//%s", remote.URL, c.commits[ref.Git], filepath.ToSlash(ref.File))
+		return fp, clone, source, nil
+	}
+
+	if len(ref.Path) == 0 {
+		return "", "", "", errors.New("Include or Extends needs a path or git")
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(ref.Path))
+	if len(root) != 0 {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			return "", "", "", errors.New(fmt.Sprintf("'%s' is Outside of it's Git Repo", ref.Path))
+		}
+	}
+
+	fp, err := composeFilePath(path)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return fp, root, ref.Path, nil
+}
+
+// Loads every recipe and fragment used by hf in the order they
+// are applied, the base recipe first and hf itself last.
+func (c *recipeComposer) collect(hf HAMFile, fp string, root string, params map[string]string, depth int) error {
+	if depth > maxComposeDepth {
+		return errors.New("Includes and Extends are Nested too Deep")
+	}
+
+	abs, err := filepath.Abs(fp)
+	if err != nil {
+		return err
+	}
+
+	if c.loading[abs] {
+		return errors.New(fmt.Sprintf("'%s' Includes or Extends Itself", fp))
+	}
+	c.loading[abs] = true
+	defer delete(c.loading, abs)
+
+	refs := []HAMRecipeRef{}
+	if hf.Extends != nil {
+		refs = append(refs, *hf.Extends)
+	}
+	refs = append(refs, hf.Include...)
+
+	for _, ref := range refs {
+		refFp, refRoot, source, err := c.locate(&ref, filepath.Dir(fp), root)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Cannot Load %s (%s)", ref.Location(), err.Error()))
+		}
+
+		content, err := os.ReadFile(refFp)
+		if err != nil {
+			return err
+		}
+
+		piece := HAMFile{}
+		err = yaml.Unmarshal(content, &piece)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Cannot Parse %s (%s)", ref.Location(), err.Error()))
+		}
+
+		// Arguments given to the reference can use the
+		// arguments of the file which has it.
+		with := map[string]string{}
+		for key, value := range ref.With {
+			with[key] = expandTemplateParams(value, params)
+		}
+
+		c.sources = append(c.sources, source)
+		err = c.collect(piece, refFp, refRoot, with, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.pieces = append(c.pieces, recipePiece{
+		hf:     hf,
+		params: params,
+	})
+	return nil
+}
+
+// Replaces the template arguments found in params, everything
+// else is kept as is.
+func expandTemplateParams(value string, params map[string]string) string {
+	return templateRefRegex.ReplaceAllStringFunc(value, func(ref string) string {
+		name := templateRefRegex.FindStringSubmatch(ref)[1]
+		if param, ok := params[name]; ok {
+			return param
+		}
+		return ref
+	})
+}
+
+// Replaces the args of a condition found in params with their
+// value, the others are looked up on the build server.
+func expandConditionParams(condition string, params map[string]string) string {
+	return conditionArgRegex.ReplaceAllStringFunc(condition, func(ref string) string {
+		name := conditionArgRegex.FindStringSubmatch(ref)[1]
+		param, ok := params[name]
+		if !ok {
+			return ref
+		}
+		if strings.Contains(param, "'") {
+			return "\"" + strings.ReplaceAll(param, "\"", "") + "\""
+		}
+		return "'" + param + "'"
+	})
+}
+
+// Expands all template arguments, those not given by params
+// must be arguments of the recipe and are replaced with their
+// environmental variable, so the value is given at build time.
+func (hf *HAMFile) expandTemplate(value string, params map[string]string) (string, error) {
+	value = expandTemplateParams(value, params)
+
+	var err error
+	expanded := templateRefRegex.ReplaceAllStringFunc(value, func(ref string) string {
+		name := templateRefRegex.FindStringSubmatch(ref)[1]
+		if hf.GetArg(name) == nil {
+			if err == nil {
+				err = errors.New(fmt.Sprintf("Unknown Template Argument '%s'", name))
+			}
+			return ref
+		}
+		return "${" + ArgEnvName(name) + "}"
+	})
+
+	return expanded, err
+}
+
+// Expands the template arguments in the steps of the recipe.
+func (hf *HAMFile) expandSteps(build []HAMBuildStep, postBuild []string, params map[string]string) ([]HAMBuildStep, []string, error) {
+	var err error
+
+	expandedBuild := make([]HAMBuildStep, len(build))
+	for i, step := range build {
+		expandedBuild[i] = step
+		expandedBuild[i].Title, err = hf.expandTemplate(step.Title, params)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		expandedBuild[i].Cmd, err = hf.expandTemplate(step.Cmd, params)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("Step '%s': %s", step.Title, err.Error()))
+		}
+
+		expandedBuild[i].WorkingDirectory, err = hf.expandTemplate(step.WorkingDirectory, params)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("Step '%s': %s", step.Title, err.Error()))
+		}
+
+		if len(step.Env) != 0 {
+			expandedBuild[i].Env = map[string]string{}
+			for name, value := range step.Env {
+				expandedBuild[i].Env[name], err = hf.expandTemplate(value, params)
+				if err != nil {
+					return nil, nil, errors.New(fmt.Sprintf("Step '%s': %s", step.Title, err.Error()))
+				}
+			}
+		}
+		expandedBuild[i].If = expandConditionParams(step.If, params)
+	}
+
+	expandedPostBuild := make([]string, len(postBuild))
+	for i, cmd := range postBuild {
+		expandedPostBuild[i], err = hf.expandTemplate(cmd, params)
+		if err != nil {
+			return nil, nil, errors.New("Post Build: " + err.Error())
+		}
+	}
+
+	return expandedBuild, expandedPostBuild, nil
+}
+
+// Expands the template arguments in the commands of a hook, name
+// tells which one in errors.
+func (hf *HAMFile) expandCommands(commands []string, params map[string]string, name string) ([]string, error) {
+	expanded := make([]string, len(commands))
+	for i, cmd := range commands {
+		var err error
+		expanded[i], err = hf.expandTemplate(cmd, params)
+		if err != nil {
+			return nil, errors.New(name + ": " + err.Error())
+		}
+	}
+	return expanded, nil
+}
+
+// Resolves extends and include of the recipe into a single recipe.
+// Later files win, a argument or step with the same id or name as
+// a earlier one replaces it in place. fp is the path of the ham.yml
+// of the recipe.
+func (hf *HAMFile) compose(fp string) error {
+	c := &recipeComposer{
+		clones:  map[string]string{},
+		commits: map[string]string{},
+		loading: map[string]bool{},
+	}
+	defer c.cleanup()
+
+	err := c.collect(*hf, fp, "", nil, 0)
+	if err != nil {
+		return err
+	}
+
+	args := []HAMArg{}
+	watch := []HAMWatch{}
+	notify := []HAMNotify{}
+	progress := []string{}
+	deps := HAMDependencies{}
+	for _, piece := range c.pieces {
+		if len(piece.hf.Title) != 0 {
+			hf.Title = piece.hf.Title
+		}
+		if len(piece.hf.Version) != 0 {
+			hf.Version = piece.hf.Version
+		}
+		if piece.hf.Matrix != nil {
+			hf.Matrix = piece.hf.Matrix
+		}
+		if piece.hf.Container != nil {
+			hf.Container = piece.hf.Container
+		}
+		for _, w := range piece.hf.Watch {
+			if !hasWatch(watch, w) {
+				watch = append(watch, w)
+			}
+		}
+		notify = append(notify, piece.hf.Notify...)
+		progress = append(progress, piece.hf.Progress...)
+		deps.append(piece.hf.Dependencies)
+
+		for _, arg := range piece.hf.Args {
+			replaced := false
+			for i := range args {
+				if args[i].ID == arg.ID {
+					args[i] = arg
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				args = append(args, arg)
+			}
+		}
+	}
+	hf.Args = args
+	hf.Watch = watch
+	hf.Notify = notify
+	hf.Progress = progress
+	hf.Dependencies = deps
+
+	build := []HAMBuildStep{}
+	postBuild := []string{}
+	hooks := HAMHooks{}
+	for _, piece := range c.pieces {
+		steps, post, err := hf.expandSteps(piece.hf.Build, piece.hf.PostBuild, piece.params)
+		if err != nil {
+			return err
+		}
+
+		for _, step := range steps {
+			replaced := false
+			for i := range build {
+				if build[i].Title == step.Title {
+					build[i] = step
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				build = append(build, step)
+			}
+		}
+		postBuild = append(postBuild, post...)
+
+		pieceHooks, err := hf.expandHooks(piece.hf.HAMHooks, piece.params)
+		if err != nil {
+			return err
+		}
+		hooks.append(pieceHooks)
+	}
+
+	hf.Build = build
+	hf.PostBuild = postBuild
+	hf.HAMHooks = hooks
+	hf.Extends = nil
+	hf.Include = nil
+	hf.Sources = c.sources
+
+	hf.SHA256Sum, err = hf.composedSHA256Sum(hf.SHA256Sum)
+	return err
+}
+
+// Returns true if the recipe was put together from other files.
+func (hf *HAMFile) IsComposed() bool {
+	return len(hf.Sources) != 0
+}
+
+// The sum of a composed recipe covers the recipe itself and the
+// result of the composition, so a change to any included file
+// is a new build.
+func (hf *HAMFile) composedSHA256Sum(recipeSum string) (string, error) {
+	resolved, err := hf.ResolvedYAML()
+	if err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "composed\x00%s\x00", recipeSum)
+	hasher.Write(resolved)
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// Returns the composed recipe as a single YAML file, which is what
+// the build server builds since it can't resolve includes itself.
+func (hf *HAMFile) ResolvedYAML() ([]byte, error) {
+	return yaml.Marshal(hf)
+}
+
+// Reads a recipe composed by NewHAMFile at the client, the recipe
+// at RecipePath is hashed again so the sum is only the same if the
+// recipe and the composition are the same.
+func NewResolvedHAMFile(RecipePath string, ResolvedPath string) (HAMFile, error) {
+	hf := HAMFile{}
+
+	_, source, err := readHAMFileSource(RecipePath)
+	if err != nil {
+		return hf, err
+	}
+
+	resolved, err := os.ReadFile(ResolvedPath)
+	if err != nil {
+		return hf, err
+	}
+
+	err = yaml.Unmarshal(resolved, &hf)
+	if err != nil {
+		return hf, err
+	}
+
+	if hf.Extends != nil || len(hf.Include) != 0 || !hf.IsComposed() {
+		return hf, errors.New("Resolved Recipe is not Composed Properly")
+	}
+
+	err = hf.hashRecipe(RecipePath, source)
+	if err != nil {
+		return hf, err
+	}
+
+	hf.SHA256Sum, err = hf.composedSHA256Sum(hf.SHA256Sum)
+	if err != nil {
+		return hf, err
+	}
+
+	err = hf.check()
+	if err != nil {
+		return hf, err
+	}
+
+	return hf, nil
+}
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Writes the files under a new directory and returns it.
+func writeTestRecipe(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func stepsOf(hf *HAMFile) map[string]string {
+	steps := map[string]string{}
+	for _, step := range hf.Build {
+		steps[step.Title] = step.Cmd
+	}
+	return steps
+}
+
+var composeBase = map[string]string{
+	"common/base.yml": `
+title: "Base"
+version: "1.0"
+args:
+  - id: jobs
+    prompt: "Jobs?"
+    type: int
+build:
+  - name: Sync
+    run: repo init -b ${{ args.branch }} && repo sync -j ${{ args.jobs }}
+  - name: Brunch
+    run: brunch ${{ args.device }}
+post_build:
+  - upload ${{ args.device }}
+`,
+	"common/device.yml": `
+extends:
+  path: base.yml
+  with:
+    branch: ${{ args.branch }}
+    device: ${{ args.device }}
+build:
+  - name: Sign
+    run: sign ${{ args.device }}
+    if: args.sign && args.release
+`,
+}
+
+func TestComposeNestedExtends(t *testing.T) {
+	files := map[string]string{
+		"recipe/ham.yml": `
+title: "Lineage OS 19.1 (Enchilada)"
+version: "2.0"
+hash: yml
+extends:
+  path: ../common/device.yml
+  with:
+    branch: lineage-19.1
+    device: enchilada
+    sign: "true"
+args:
+  - id: release
+    prompt: "Release?"
+    type: bool
+build:
+  - name: Brunch
+    run: brunch enchilada-userdebug
+  - name: Done
+    run: echo done
+`,
+	}
+	for name, content := range composeBase {
+		files[name] = content
+	}
+	dir := writeTestRecipe(t, files)
+
+	hf, err := NewHAMFile(filepath.Join(dir, "recipe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hf.Title != "Lineage OS 19.1 (Enchilada)" || hf.Version != "2.0" {
+		t.Errorf("Title and Version = %q %q", hf.Title, hf.Version)
+	}
+
+	// The base comes first, a step with the same name is replaced
+	// in place.
+	titles := []string{}
+	for _, step := range hf.Build {
+		titles = append(titles, step.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"Sync", "Brunch", "Sign", "Done"}) {
+		t.Errorf("Steps = %v", titles)
+	}
+
+	// jobs is not given, so it's the arg of the recipe at build
+	// time.
+	steps := stepsOf(&hf)
+	if steps["Sync"] != "repo init -b lineage-19.1 && repo sync -j ${JOBS}" {
+		t.Errorf("Sync = %q", steps["Sync"])
+	}
+	if steps["Brunch"] != "brunch enchilada-userdebug" || steps["Sign"] != "sign enchilada" {
+		t.Errorf("Steps = %v", steps)
+	}
+	if hf.Build[2].If != "'true' && args.release" {
+		t.Errorf("If of Sign = %q", hf.Build[2].If)
+	}
+	if !reflect.DeepEqual(hf.PostBuild, []string{"upload enchilada"}) {
+		t.Errorf("PostBuild = %v", hf.PostBuild)
+	}
+
+	ids := []string{}
+	for _, arg := range hf.Args {
+		ids = append(ids, arg.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"jobs", "release"}) {
+		t.Errorf("Args = %v", ids)
+	}
+
+	if !reflect.DeepEqual(hf.Sources, []string{"../common/device.yml", "base.yml"}) {
+		t.Errorf("Sources = %v", hf.Sources)
+	}
+	if !hf.IsComposed() || hf.Extends != nil || len(hf.Include) != 0 {
+		t.Errorf("Recipe is not resolved, %+v", hf)
+	}
+
+	// A change to a file it extends is a new recipe.
+	before := hf.SHA256Sum
+	err = os.WriteFile(filepath.Join(dir, "common", "base.yml"), []byte(strings.Replace(composeBase["common/base.yml"], "repo sync", "repo sync -c", 1)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hf, err = NewHAMFile(filepath.Join(dir, "recipe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hf.SHA256Sum == before {
+		t.Errorf("Sum did not change with the base recipe")
+	}
+}
+
+func TestComposeUnpassedArgs(t *testing.T) {
+	tests := []struct {
+		recipe string
+		want   string
+		err    bool
+	}{
+		// Given with, the value is used as is.
+		{"extends:\n  path: base.yml\n  with: {version: \"13\"}\n", "build 13", false},
+
+		// Not given, but a arg of the recipe, so it's env.
+		{"extends: base.yml\nargs:\n  - id: version\n    prompt: \"Version?\"\n", "build ${VERSION}", false},
+		{"extends: base.yml\nargs:\n  - id: version\n    prompt: \"Version?\"\nbuild:\n  - name: Build\n    run: build ${{args.version}}-${{ args.version }}\n", "build ${VERSION}-${VERSION}", false},
+
+		// Neither, which is a error.
+		{"extends: base.yml\n", "", true},
+		{"extends:\n  path: base.yml\n  with: {other: \"13\"}\n", "", true},
+	}
+
+	for _, test := range tests {
+		dir := writeTestRecipe(t, map[string]string{
+			"ham.yml":  "title: Test\nhash: yml\n" + test.recipe,
+			"base.yml": "build:\n  - name: Build\n    run: build ${{ args.version }}\n",
+		})
+
+		hf, err := NewHAMFile(dir)
+		if test.err {
+			if err == nil {
+				t.Errorf("NewHAMFile(%q) did not fail", test.recipe)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewHAMFile(%q) failed (%s)", test.recipe, err.Error())
+			continue
+		}
+		if got := stepsOf(&hf)["Build"]; got != test.want {
+			t.Errorf("NewHAMFile(%q) Build = %q, want %q", test.recipe, got, test.want)
+		}
+	}
+}
+
+func TestComposeIncludeOrder(t *testing.T) {
+	dir := writeTestRecipe(t, map[string]string{
+		"ham.yml": `
+title: Test
+hash: yml
+include:
+  - a.yml
+  - path: b.yml
+    with:
+      name: b
+on_failure:
+  - echo recipe
+`,
+		"a.yml": "build:\n  - name: A\n    run: echo a\non_failure:\n  - echo a\n",
+		"b.yml": "build:\n  - name: B\n    run: echo ${{ args.name }}\n  - name: A\n    run: echo replaced\n",
+	})
+
+	hf, err := NewHAMFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := stepsOf(&hf)
+	if len(hf.Build) != 2 || hf.Build[0].Title != "A" || steps["A"] != "echo replaced" || steps["B"] != "echo b" {
+		t.Errorf("Build = %+v", hf.Build)
+	}
+	if !reflect.DeepEqual(hf.OnFailure, []string{"echo a", "echo recipe"}) {
+		t.Errorf("OnFailure = %v", hf.OnFailure)
+	}
+}
+
+func TestComposeCycles(t *testing.T) {
+	tests := []map[string]string{
+		// Itself.
+		{
+			"ham.yml": "title: Test\nhash: yml\ninclude:\n  - ham.yml\n",
+		},
+		// Through another file.
+		{
+			"ham.yml": "title: Test\nhash: yml\ninclude:\n  - a.yml\n",
+			"a.yml":   "include:\n  - b.yml\n",
+			"b.yml":   "extends: a.yml\n",
+		},
+		// A directory is it's ham.yml.
+		{
+			"ham.yml":     "title: Test\nhash: yml\nextends: sub\n",
+			"sub/ham.yml": "extends: ..\n",
+		},
+		// Missing.
+		{
+			"ham.yml": "title: Test\nhash: yml\ninclude:\n  - missing.yml\n",
+		},
+		// Neither path nor git.
+		{
+			"ham.yml": "title: Test\nhash: yml\ninclude:\n  - with: {a: b}\n",
+		},
+	}
+
+	for i, files := range tests {
+		_, err := NewHAMFile(writeTestRecipe(t, files))
+		if err == nil {
+			t.Errorf("NewHAMFile() of case %d did not fail", i)
+		}
+	}
+
+	// The same file can be included twice, it's not a cycle.
+	dir := writeTestRecipe(t, map[string]string{
+		"ham.yml": "title: Test\nhash: yml\ninclude:\n  - path: a.yml\n    with: {n: \"1\"}\n  - path: a.yml\n    with: {n: \"2\"}\n",
+		"a.yml":   "post_build:\n  - echo ${{ args.n }}\n",
+	})
+	hf, err := NewHAMFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hf.PostBuild, []string{"echo 1", "echo 2"}) {
+		t.Errorf("PostBuild = %v", hf.PostBuild)
+	}
+}
+
+func TestComposeTooDeep(t *testing.T) {
+	files := map[string]string{
+		"ham.yml": "title: Test\nhash: yml\ninclude:\n  - 0.yml\n",
+	}
+	for i := 0; i <= maxComposeDepth; i++ {
+		files[fmt.Sprintf("%d.yml", i)] = fmt.Sprintf("include:\n  - %d.yml\n", i+1)
+	}
+	files[fmt.Sprintf("%d.yml", maxComposeDepth+1)] = "build: []\n"
+
+	_, err := NewHAMFile(writeTestRecipe(t, files))
+	if err == nil || !strings.Contains(err.Error(), "Nested too Deep") {
+		t.Errorf("NewHAMFile() nested too deep = %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The if of a build step, which is looked at on the build server
+// right before the step would run.
+//
+//	args.android_certs
+//	env.BUILD_TYPE == 'user' && !args.skip_sign
+//	matrix.device != 'enchilada' || (args.sign && args.release)
+//
+// args, env and matrix are strings, a value is false if it's empty,
+// 'false' or '0'. Strings are quoted with ' or ".
+type StepCondition struct {
+	source string
+	root   conditionNode
+	refs   []string
+}
+
+// Gives the value of args.name, env.NAME or matrix.name for the
+// scope and name, empty if it's not set.
+type ConditionLookup func(scope string, name string) string
+
+var conditionTokenRegex = regexp.MustCompile(`^\s*(&&|\|\||==|!=|!|\(|\)|'[^']*'|"[^"]*"|[A-Za-z0-9_.-]+)`)
+
+func ParseStepCondition(source string) (*StepCondition, error) {
+	tokens := []string{}
+	rest := source
+	for len(strings.TrimSpace(rest)) != 0 {
+		match := conditionTokenRegex.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, errors.New(fmt.Sprintf("Invalid Condition '%s' at '%s'", source, strings.TrimSpace(rest)))
+		}
+		tokens = append(tokens, match[1])
+		rest = rest[len(match[0]):]
+	}
+
+	parser := &conditionParser{source: source, tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos != len(tokens) {
+		return nil, errors.New(fmt.Sprintf("Invalid Condition '%s', Unexpected '%s'", source, tokens[parser.pos]))
+	}
+
+	return &StepCondition{
+		source: source,
+		root:   root,
+		refs:   parser.refs,
+	}, nil
+}
+
+// Returns the args, env and matrix values the condition looks at,
+// like args.android_certs.
+func (c *StepCondition) Refs() []string {
+	return c.refs
+}
+
+func (c *StepCondition) Eval(lookup ConditionLookup) bool {
+	return conditionTruthy(c.root.eval(lookup))
+}
+
+func (c *StepCondition) String() string {
+	return c.source
+}
+
+func conditionTruthy(value string) bool {
+	return len(value) != 0 && value != "false" && value != "0"
+}
+
+func conditionBool(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
+
+type conditionNode interface {
+	eval(lookup ConditionLookup) string
+}
+
+type conditionLiteral string
+
+func (n conditionLiteral) eval(lookup ConditionLookup) string {
+	return string(n)
+}
+
+type conditionRef struct {
+	scope string
+	name  string
+}
+
+func (n conditionRef) eval(lookup ConditionLookup) string {
+	return lookup(n.scope, n.name)
+}
+
+type conditionNot struct {
+	operand conditionNode
+}
+
+func (n conditionNot) eval(lookup ConditionLookup) string {
+	return conditionBool(!conditionTruthy(n.operand.eval(lookup)))
+}
+
+type conditionBinary struct {
+	op    string
+	left  conditionNode
+	right conditionNode
+}
+
+func (n conditionBinary) eval(lookup ConditionLookup) string {
+	switch n.op {
+	case "&&":
+		return conditionBool(conditionTruthy(n.left.eval(lookup)) && conditionTruthy(n.right.eval(lookup)))
+	case "||":
+		return conditionBool(conditionTruthy(n.left.eval(lookup)) || conditionTruthy(n.right.eval(lookup)))
+	case "==":
+		return conditionBool(n.left.eval(lookup) == n.right.eval(lookup))
+	}
+	return conditionBool(n.left.eval(lookup) != n.right.eval(lookup))
+}
+
+type conditionParser struct {
+	source string
+	tokens []string
+	pos    int
+	refs   []string
+}
+
+func (p *conditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *conditionParser) parseOr() (conditionNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "||" {
+		p.pos++
+		var right conditionNode
+		right, err = p.parseAnd()
+		left = conditionBinary{op: "||", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *conditionParser) parseAnd() (conditionNode, error) {
+	left, err := p.parseNot()
+	for err == nil && p.peek() == "&&" {
+		p.pos++
+		var right conditionNode
+		right, err = p.parseNot()
+		left = conditionBinary{op: "&&", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *conditionParser) parseNot() (conditionNode, error) {
+	if p.peek() == "!" {
+		p.pos++
+		operand, err := p.parseNot()
+		return conditionNot{operand: operand}, err
+	}
+	return p.parseCompare()
+}
+
+func (p *conditionParser) parseCompare() (conditionNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.peek()
+	if op != "==" && op != "!=" {
+		return left, nil
+	}
+	p.pos++
+	right, err := p.parsePrimary()
+	return conditionBinary{op: op, left: left, right: right}, err
+}
+
+func (p *conditionParser) parsePrimary() (conditionNode, error) {
+	token := p.peek()
+	p.pos++
+
+	switch {
+	case len(token) == 0:
+		return nil, errors.New(fmt.Sprintf("Invalid Condition '%s', Unexpected End", p.source))
+	case token == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New(fmt.Sprintf("Invalid Condition '%s', Missing ')'", p.source))
+		}
+		p.pos++
+		return node, nil
+	case token[0] == '\'' || token[0] == '"':
+		return conditionLiteral(token[1 : len(token)-1]), nil
+	case token == "true" || token == "false":
+		return conditionLiteral(token), nil
+	}
+
+	scope, name, found := strings.Cut(token, ".")
+	if found && len(name) != 0 && (scope == "args" || scope == "env" || scope == "matrix") {
+		p.refs = append(p.refs, token)
+		return conditionRef{scope: scope, name: name}, nil
+	}
+
+	if strings.Trim(token, "0123456789.") == "" {
+		return conditionLiteral(token), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Invalid Condition '%s', Unknown '%s' (use args.*, env.* or matrix.*)", p.source, token))
+}
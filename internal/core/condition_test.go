@@ -0,0 +1,117 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStepConditionEval(t *testing.T) {
+	values := map[string]map[string]string{
+		"args": {
+			"android_certs": "/ham-recipe/certs",
+			"sign":          "true",
+			"release":       "false",
+			"jobs":          "0",
+		},
+		"env": {
+			"BUILD_TYPE":  "userdebug",
+			"HAM_VARIANT": "enchilada-user",
+		},
+		"matrix": {
+			"device": "enchilada",
+		},
+	}
+	lookup := func(scope string, name string) string {
+		return values[scope][name]
+	}
+
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"args.android_certs", true},
+		{"args.missing", false},
+		{"args.release", false},
+		{"args.jobs", false},
+		{"!args.release", true},
+		{"!!args.sign", true},
+		{"env.BUILD_TYPE == 'userdebug'", true},
+		{`env.BUILD_TYPE == "user"`, false},
+		{"env.BUILD_TYPE != 'user'", true},
+		{"env.MISSING == ''", true},
+		{"matrix.device == 'enchilada'", true},
+		{"matrix.device != 'enchilada'", false},
+		{"args.sign && args.release", false},
+		{"args.sign || args.release", true},
+		{"args.release || args.jobs || matrix.device", true},
+
+		// && binds tighter than ||.
+		{"args.sign || args.release && args.jobs", true},
+		{"(args.sign || args.release) && args.jobs", false},
+		{"!(args.sign && args.release)", true},
+		{"env.BUILD_TYPE != 'user' && matrix.device != 'enchilada' || (args.sign && args.android_certs)", true},
+
+		// Literals compare as strings.
+		{"true", true},
+		{"false", false},
+		{"args.jobs == 0", true},
+		{"args.jobs == '0'", true},
+		{"args.sign == true", true},
+		{"'a b' == 'a b'", true},
+	}
+
+	for _, test := range tests {
+		condition, err := ParseStepCondition(test.source)
+		if err != nil {
+			t.Errorf("ParseStepCondition(%q) failed (%s)", test.source, err.Error())
+			continue
+		}
+		if got := condition.Eval(lookup); got != test.want {
+			t.Errorf("Eval(%q) = %v, want %v", test.source, got, test.want)
+		}
+		if condition.String() != test.source {
+			t.Errorf("String() = %q, want %q", condition.String(), test.source)
+		}
+	}
+}
+
+func TestStepConditionRefs(t *testing.T) {
+	condition, err := ParseStepCondition("env.BUILD_TYPE == 'user' && !args.skip_sign || matrix.device != args.device")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"env.BUILD_TYPE", "args.skip_sign", "matrix.device", "args.device"}
+	if !reflect.DeepEqual(condition.Refs(), want) {
+		t.Errorf("Refs() = %v, want %v", condition.Refs(), want)
+	}
+}
+
+func TestStepConditionInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"args",
+		"args.",
+		"vars.sign",
+		"sign",
+		"args.sign &&",
+		"|| args.sign",
+		"args.sign == ",
+		"!",
+		"(args.sign",
+		"args.sign)",
+		"args.sign args.release",
+		"args.sign = 'x'",
+		"args.sign & args.release",
+		"'unterminated",
+		"env.BUILD_TYPE == 'user' == 'x'",
+		"args.sign; rm -rf /",
+	}
+
+	for _, source := range tests {
+		_, err := ParseStepCondition(source)
+		if err == nil {
+			t.Errorf("ParseStepCondition(%q) did not fail", source)
+		}
+	}
+}
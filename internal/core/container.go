@@ -0,0 +1,66 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The container the steps of the recipe run in, a image to pull or
+// a Dockerfile in the recipe to build. It's given as just the image
+// too,
+//
+//	container: ubuntu:20.04
+type HAMContainer struct {
+	Image      string `yaml:"image,omitempty"`
+	Dockerfile string `yaml:"dockerfile,omitempty"`
+}
+
+// The directories of the build server which are the same in the
+// container.
+var CONTAINER_MOUNTS = []string{
+	"/ham-build",
+	"/ham-recipe",
+	"/ham-files",
+	"/ham-output",
+}
+
+func (c *HAMContainer) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		c.Image = value.Value
+		return nil
+	}
+
+	type plain HAMContainer
+	return value.Decode((*plain)(c))
+}
+
+// Returns the path of the Dockerfile at the build server.
+func (c *HAMContainer) DockerfilePath() string {
+	return filepath.Join("/ham-recipe", c.Dockerfile)
+}
+
+func (c *HAMContainer) check() error {
+	if len(c.Image) == 0 && len(c.Dockerfile) == 0 {
+		return errors.New("Container needs a Image or a Dockerfile")
+	}
+	if len(c.Image) != 0 && len(c.Dockerfile) != 0 {
+		return errors.New("Container can't have both a Image and a Dockerfile")
+	}
+
+	if strings.ContainsAny(c.Image, " \t\n") {
+		return errors.New(fmt.Sprintf("Invalid Container Image '%s'", c.Image))
+	}
+
+	// The Dockerfile is in /ham-recipe at the build server.
+	if len(c.Dockerfile) != 0 {
+		clean := filepath.Clean(c.Dockerfile)
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return errors.New(fmt.Sprintf("Container Dockerfile '%s' must be in the Recipe", c.Dockerfile))
+		}
+	}
+	return nil
+}
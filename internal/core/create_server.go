@@ -9,8 +9,9 @@ import (
 )
 
 // Change this if needed in the future when
-// Hetzner deprecates Ubuntu 20.04 LTS, or if it
-// is that time of the year.
+// Hetzner deprecates Ubuntu 24.04 LTS, or if it
+// is that time of the year. A new version needs
+// it's packages in DEFAULT_APT_PACKAGES.
 const (
 	TargetImage = "ubuntu-24.04"
 
@@ -19,7 +20,7 @@ const (
 	TargetLocation = "nbg1"
 )
 
-func CreateServer(client *hcloud.Client, server *hcloud.ServerType, serverName string) (*hcloud.Server, error) {
+func CreateServer(client *hcloud.Client, server *hcloud.ServerType, serverName string, labels map[string]string) (*hcloud.Server, error) {
 	// Get Server Image
 	serverImage, _, err := client.Image.Get(
 		context.Background(),
@@ -103,7 +104,7 @@ func CreateServer(client *hcloud.Client, server *hcloud.ServerType, serverName s
 		SSHKeys:          sshList,
 		Location:         location,
 		StartAfterCreate: &startAfterCreate,
-		Labels:           map[string]string{},
+		Labels:           labels,
 		PublicNet: &hcloud.ServerCreatePublicNet{
 			EnableIPv4: true,
 			EnableIPv6: false,
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shorthands for common schedules.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// A parsed cron expression with the usual five fields, minute,
+// hour, day of month, month and day of week.
+type CronSchedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+
+	// Like cron, when both days and weekdays are restricted a
+	// time matching either of them is fine.
+	anyDay     bool
+	anyWeekday bool
+}
+
+func parseCronField(field string, min int, max int) (map[int]bool, bool, error) {
+	values := map[int]bool{}
+	any := field == "*"
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return nil, false, errors.New(fmt.Sprintf("Invalid Step in '%s'", part))
+			}
+			step = s
+			part = part[:i]
+		}
+
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, false, errors.New(fmt.Sprintf("Invalid Value '%s'", part))
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, false, errors.New(fmt.Sprintf("Invalid Value '%s'", part))
+				}
+			} else if step != 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return nil, false, errors.New(fmt.Sprintf("'%s' is Out of Range %d-%d", part, min, max))
+		}
+
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, any, nil
+}
+
+// Parses a cron expression like "0 3 1 * *", the @daily style
+// shorthands are supported too.
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.New(fmt.Sprintf("Invalid Cron Expression '%s', Expected 5 Fields.", expr))
+	}
+
+	names := []string{"Minute", "Hour", "Day of Month", "Month", "Day of Week"}
+	ranges := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	parsed := make([]map[int]bool, 5)
+	anys := make([]bool, 5)
+	for i, field := range fields {
+		values, any, err := parseCronField(field, ranges[i][0], ranges[i][1])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid %s in Cron Expression (%s)", names[i], err.Error()))
+		}
+		parsed[i] = values
+		anys[i] = any
+	}
+
+	// Both 0 and 7 are sunday.
+	if parsed[4][7] {
+		parsed[4][0] = true
+	}
+
+	return &CronSchedule{
+		minutes:    parsed[0],
+		hours:      parsed[1],
+		days:       parsed[2],
+		months:     parsed[3],
+		weekdays:   parsed[4],
+		anyDay:     anys[2],
+		anyWeekday: anys[4],
+	}, nil
+}
+
+// Returns true if the schedule runs at the minute of t.
+func (c *CronSchedule) Matches(t time.Time) bool {
+	if !c.minutes[t.Minute()] || !c.hours[t.Hour()] || !c.months[int(t.Month())] {
+		return false
+	}
+
+	day := c.days[t.Day()]
+	weekday := c.weekdays[int(t.Weekday())]
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// Returns the first minute after t the schedule runs at, zero
+// if it never runs in the next five years.
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for next.Before(end) {
+		if c.Matches(next) {
+			return next
+		}
+		next = next.Add(time.Minute)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// The packages every AOSP build needs, by the Ubuntu version of the
+// build server. Package names change between releases, Ubuntu 24.04
+// has no libncurses5 and liblz4-tool anymore.
+var DEFAULT_APT_PACKAGES = map[string][]string{
+	"20.04": {
+		"bc", "bison", "build-essential", "ccache", "curl", "flex", "g++-multilib",
+		"gcc-multilib", "git", "gnupg", "gperf", "imagemagick", "lib32ncurses5-dev",
+		"lib32readline-dev", "lib32z1-dev", "libelf-dev", "liblz4-tool", "libncurses5",
+		"libncurses5-dev", "libsdl1.2-dev", "libssl-dev", "libxml2", "libxml2-utils",
+		"lzop", "pngcrush", "rsync", "schedtool", "squashfs-tools", "xsltproc", "zip",
+		"zlib1g-dev", "android-sdk-platform-tools", "git-lfs",
+	},
+	"22.04": {
+		"bc", "bison", "build-essential", "ccache", "curl", "flex", "g++-multilib",
+		"gcc-multilib", "git", "gnupg", "gperf", "imagemagick", "lib32ncurses5-dev",
+		"lib32readline-dev", "lib32z1-dev", "libelf-dev", "lz4", "libncurses5",
+		"libncurses5-dev", "libsdl1.2-dev", "libssl-dev", "libxml2", "libxml2-utils",
+		"lzop", "pngcrush", "rsync", "schedtool", "squashfs-tools", "xsltproc", "zip",
+		"zlib1g-dev", "android-sdk-platform-tools", "git-lfs",
+	},
+	"24.04": {
+		"bc", "bison", "build-essential", "ccache", "curl", "flex", "g++-multilib",
+		"gcc-multilib", "git", "gnupg", "gperf", "imagemagick", "lib32ncurses-dev",
+		"lib32readline-dev", "lib32z1-dev", "libelf-dev", "lz4", "libncurses6",
+		"libncurses-dev", "libsdl1.2-dev", "libssl-dev", "libxml2", "libxml2-utils",
+		"lzop", "pngcrush", "rsync", "schedtool", "squashfs-tools", "xsltproc", "zip",
+		"zlib1g-dev", "android-sdk-platform-tools", "git-lfs",
+	},
+}
+
+// Where the repo launcher is downloaded from, a pinned version is
+// repo-<version> in the same place.
+const REPO_LAUNCHER_URL = "https://storage.googleapis.com/git-repo-downloads/repo"
+
+var (
+	aptPackageRegex    = regexp.MustCompile(`^[a-z0-9][a-z0-9+.:~=-]*$`)
+	repoVersionRegex   = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+	repoSHA256SumRegex = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// What the build server installs before the steps of the recipe.
+type HAMDependencies struct {
+	// Installed with apt after the default packages, a version is
+	// given like in apt, ccache=4.9.1-1.
+	Apt []string `yaml:"apt,omitempty"`
+
+	// Only install the packages in Apt.
+	SkipDefault bool `yaml:"skip_default,omitempty"`
+
+	// apt upgrade before installing, which is the default.
+	Upgrade *bool `yaml:"upgrade,omitempty"`
+
+	// Version of the repo launcher, like 2.45, and it's sum. The
+	// latest launcher is used if not given.
+	Repo       string `yaml:"repo,omitempty"`
+	RepoSHA256 string `yaml:"repo_sha256,omitempty"`
+}
+
+// Returns the default packages for the Ubuntu version, a version
+// that is not known gets the packages of the newest one before it
+// or the oldest one there is. Without a version it's the newest.
+func DefaultAptPackages(version string) []string {
+	versions := make([]string, 0, len(DEFAULT_APT_PACKAGES))
+	for v := range DEFAULT_APT_PACKAGES {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return ubuntuVersion(versions[i]) < ubuntuVersion(versions[j])
+	})
+
+	chosen := versions[0]
+	for _, v := range versions {
+		if ubuntuVersion(version) == 0 || ubuntuVersion(v) <= ubuntuVersion(version) {
+			chosen = v
+		}
+	}
+	return append([]string{}, DEFAULT_APT_PACKAGES[chosen]...)
+}
+
+func ubuntuVersion(version string) float64 {
+	value, err := strconv.ParseFloat(version, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// Returns the packages to install on a server with the Ubuntu
+// version, without duplicates.
+func (deps *HAMDependencies) AptPackages(version string) []string {
+	packages := []string{}
+	if !deps.SkipDefault {
+		packages = DefaultAptPackages(version)
+	}
+
+	seen := map[string]bool{}
+	for _, pkg := range packages {
+		seen[pkg] = true
+	}
+	for _, pkg := range deps.Apt {
+		if !seen[pkg] {
+			seen[pkg] = true
+			packages = append(packages, pkg)
+		}
+	}
+	return packages
+}
+
+func (deps *HAMDependencies) ShouldUpgrade() bool {
+	return deps.Upgrade == nil || *deps.Upgrade
+}
+
+// Returns the URL of the repo launcher to install.
+func (deps *HAMDependencies) RepoURL() string {
+	if len(deps.Repo) == 0 {
+		return REPO_LAUNCHER_URL
+	}
+	return REPO_LAUNCHER_URL + "-" + deps.Repo
+}
+
+func (deps *HAMDependencies) check() error {
+	for _, pkg := range deps.Apt {
+		if !aptPackageRegex.MatchString(pkg) {
+			return errors.New(fmt.Sprintf("Invalid Apt Package '%s' in Dependencies", pkg))
+		}
+	}
+
+	if len(deps.Repo) != 0 && !repoVersionRegex.MatchString(deps.Repo) {
+		return errors.New(fmt.Sprintf("Invalid Repo Launcher Version '%s' in Dependencies", deps.Repo))
+	}
+
+	if len(deps.RepoSHA256) != 0 && !repoSHA256SumRegex.MatchString(deps.RepoSHA256) {
+		return errors.New("Invalid Repo Launcher SHA256 Sum in Dependencies")
+	}
+	return nil
+}
+
+// Adds the dependencies of a recipe composed into this one, later
+// recipes win for everything but the packages.
+func (deps *HAMDependencies) append(other HAMDependencies) {
+	for _, pkg := range other.Apt {
+		found := false
+		for _, have := range deps.Apt {
+			found = found || have == pkg
+		}
+		if !found {
+			deps.Apt = append(deps.Apt, pkg)
+		}
+	}
+
+	deps.SkipDefault = deps.SkipDefault || other.SkipDefault
+	if other.Upgrade != nil {
+		deps.Upgrade = other.Upgrade
+	}
+	if len(other.Repo) != 0 {
+		deps.Repo = other.Repo
+		deps.RepoSHA256 = other.RepoSHA256
+	}
+}
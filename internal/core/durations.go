@@ -0,0 +1,96 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/antony-jr/ham/internal/helpers"
+)
+
+// Seconds every step of a recipe took, by the name of the step.
+type StepDurations map[string]int64
+
+func getAllStepDurations() (map[string]StepDurations, error) {
+	all := map[string]StepDurations{}
+	path, err := helpers.DurationsFilePath()
+	if err != nil {
+		return all, err
+	}
+
+	source, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	} else if err != nil {
+		return all, err
+	}
+
+	err = json.Unmarshal(source, &all)
+	return all, err
+}
+
+// Returns how long the steps of the recipe took in it's previous
+// builds, recipes are told apart by their title so the durations
+// carry over to new versions of the recipe.
+func GetStepDurations(recipe string) (StepDurations, error) {
+	all, err := getAllStepDurations()
+	if err != nil {
+		return StepDurations{}, err
+	}
+
+	durations, ok := all[recipe]
+	if !ok {
+		return StepDurations{}, nil
+	}
+	return durations, nil
+}
+
+// Records how long the steps of a successful build took, averaged
+// with the previous builds so a single slow build does not throw
+// off the weights.
+func RecordStepDurations(recipe string, durations StepDurations) error {
+	all, err := getAllStepDurations()
+	if err != nil {
+		return err
+	}
+
+	recorded, ok := all[recipe]
+	if !ok {
+		recorded = StepDurations{}
+	}
+
+	for step, seconds := range durations {
+		if previous, ok := recorded[step]; ok {
+			seconds = (previous + seconds) / 2
+		}
+		recorded[step] = seconds
+	}
+	all[recipe] = recorded
+
+	source, err := json.MarshalIndent(all, "", "   ")
+	if err != nil {
+		return err
+	}
+
+	path, err := helpers.DurationsFilePath()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, source, 0600)
+}
+
+// Returns the weight of every step, the weight given in the recipe
+// wins over the minutes the step took before. Steps which were
+// never built before weigh a minute.
+func StepWeights(steps []HAMBuildStep, durations StepDurations) []float64 {
+	weights := make([]float64, len(steps))
+	for i, step := range steps {
+		weights[i] = 1
+		if step.Weight > 0 {
+			weights[i] = step.Weight
+		} else if seconds, ok := durations[step.Title]; ok && seconds > 0 {
+			weights[i] = float64(seconds) / 60.0
+		}
+	}
+	return weights
+}
@@ -0,0 +1,315 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+const (
+	// Personal access token used to clone private recipes
+	// over HTTPS.
+	GitTokenEnv = "HAM_GIT_TOKEN"
+
+	// Optional user name for the token, most hosts accept
+	// anything here.
+	GitUsernameEnv = "HAM_GIT_USERNAME"
+
+	// User of the official HAM Recipes.
+	CommunityUser = "ham-community"
+)
+
+// Hosts which can be used in the user@host/repo shorthand.
+var gitHostShorthands = map[string]string{
+	"gh": "github.com",
+	"gl": "gitlab.com",
+	"cb": "codeberg.org",
+}
+
+var (
+	// user@gh/repo[:branch][@rev]
+	shorthandRegex = regexp.MustCompile(`^([^@/:\s]+)@([A-Za-z]+)/([^@/:\s]+)(?::([^@\s]+))?(?:@([^@/:\s]+))?$`)
+
+	// scheme://host/path[:branch][@rev]
+	urlRegex = regexp.MustCompile(`^([a-z+]+://[^/\s]+/[^:@\s]+?)(?::([^@\s]+))?(?:@([^@/:\s]+))?$`)
+
+	// user@host.tld:path[:branch][@rev]
+	scpRegex = regexp.MustCompile(`^([A-Za-z0-9._-]+@[A-Za-z0-9.-]+\.[A-Za-z]+:[^:@\s]+?)(?::([^@\s]+))?(?:@([^@/:\s]+))?$`)
+
+	// Branches, tags and commits we are willing to hand to git,
+	// anything else is rejected before it gets near a shell.
+	gitRefRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+)
+
+// A git remote given by the user, with a optional branch to
+// clone and a optional tag or commit to pin to.
+type GitRemote struct {
+	URL    string
+	Branch string
+	Rev    string
+}
+
+func ParseGitRemote(remote string) (GitRemote, error) {
+	gr := GitRemote{}
+
+	if m := shorthandRegex.FindStringSubmatch(remote); m != nil {
+		host, ok := gitHostShorthands[strings.ToLower(m[2])]
+		if !ok {
+			return gr, errors.New(fmt.Sprintf("Unknown Git Host '%s', Use gh, gl or cb.", m[2]))
+		}
+
+		user := m[1]
+		if user == "~" {
+			user = CommunityUser
+		}
+
+		gr.URL = fmt.Sprintf("https://%s/%s/%s", host, user, m[3])
+		gr.Branch = m[4]
+		gr.Rev = m[5]
+	} else if m := urlRegex.FindStringSubmatch(remote); m != nil {
+		gr.URL, gr.Branch, gr.Rev = m[1], m[2], m[3]
+	} else if m := scpRegex.FindStringSubmatch(remote); m != nil {
+		gr.URL, gr.Branch, gr.Rev = m[1], m[2], m[3]
+	} else {
+		return gr, errors.New(fmt.Sprintf("Cannot Understand Recipe Location '%s'", remote))
+	}
+
+	if len(gr.Branch) != 0 && !gitRefRegex.MatchString(gr.Branch) {
+		return gr, errors.New(fmt.Sprintf("Invalid Git Branch '%s'", gr.Branch))
+	}
+
+	if len(gr.Rev) != 0 && !gitRefRegex.MatchString(gr.Rev) {
+		return gr, errors.New(fmt.Sprintf("Invalid Git Tag or Commit '%s'", gr.Rev))
+	}
+
+	return gr, nil
+}
+
+// Returns true if the remote is reached over SSH.
+func (gr GitRemote) IsSSH() bool {
+	return strings.HasPrefix(gr.URL, "ssh://") || scpRegex.MatchString(gr.URL)
+}
+
+// Returns the host of the remote, used to scope credentials.
+func (gr GitRemote) Host() string {
+	if m := scpRegex.FindStringSubmatch(gr.URL); m != nil {
+		host := strings.SplitN(m[1], "@", 2)[1]
+		return strings.SplitN(host, ":", 2)[0]
+	}
+
+	u, err := url.Parse(gr.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// Returns the token and user name to use with HTTPS remotes,
+// the token is empty if the user did not give one.
+func GitToken() (string, string) {
+	username := os.Getenv(GitUsernameEnv)
+	if len(username) == 0 {
+		username = "oauth2"
+	}
+	return os.Getenv(GitTokenEnv), username
+}
+
+// Returns the credentials to use with the remote. SSH remotes use
+// the SSH agent of the user and HTTPS remotes use the token from
+// the environment if given, nil means no authentication.
+func (gr GitRemote) Auth() (transport.AuthMethod, error) {
+	if gr.IsSSH() {
+		user := "git"
+		if m := scpRegex.FindStringSubmatch(gr.URL); m != nil {
+			user = strings.SplitN(m[1], "@", 2)[0]
+		} else if u, err := url.Parse(gr.URL); err == nil && u.User != nil {
+			user = u.User.Username()
+		}
+
+		auth, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, errors.New("Cannot use SSH Agent for Git (" + err.Error() + ")")
+		}
+		return auth, nil
+	}
+
+	token, username := GitToken()
+	if len(token) == 0 {
+		return nil, nil
+	}
+
+	return &http.BasicAuth{
+		Username: username,
+		Password: token,
+	}, nil
+}
+
+// Resolves a tag (lightweight or annotated), branch or a commit
+// hash which can be abbreviated to the commit it points to.
+func resolveGitRev(repo *git.Repository, rev string) (plumbing.Hash, error) {
+	tagRef, err := repo.Tag(rev)
+	if err == nil {
+		tagObj, err := repo.TagObject(tagRef.Hash())
+		if err == nil {
+			commit, err := tagObj.Commit()
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			return commit.Hash, nil
+		}
+		return tagRef.Hash(), nil
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return plumbing.ZeroHash, errors.New(fmt.Sprintf("Cannot Find Tag or Commit '%s' (%s)", rev, err.Error()))
+	}
+	return *hash, nil
+}
+
+// Clones the remote into dir, checks out the pinned tag or commit
+// if any and updates all submodules recursively. Returns the
+// commit which was checked out.
+func CloneGitRemote(gr GitRemote, dir string) (string, error) {
+	auth, err := gr.Auth()
+	if err != nil {
+		return "", err
+	}
+
+	opts := &git.CloneOptions{
+		URL:  gr.URL,
+		Auth: auth,
+	}
+	if gr.Branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(gr.Branch)
+	}
+	if gr.Rev == "" {
+		opts.RecurseSubmodules = git.DefaultSubmoduleRecursionDepth
+	}
+
+	repo, err := git.PlainClone(dir, false, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if gr.Rev != "" {
+		hash, err := resolveGitRev(repo, gr.Rev)
+		if err != nil {
+			return "", err
+		}
+
+		worktree, err := repo.Worktree()
+		if err != nil {
+			return "", err
+		}
+
+		err = worktree.Checkout(&git.CheckoutOptions{
+			Hash: hash,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		submodules, err := worktree.Submodules()
+		if err != nil {
+			return "", err
+		}
+
+		err = submodules.Update(&git.SubmoduleUpdateOptions{
+			Init:              true,
+			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+			Auth:              auth,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+
+	return head.Hash().String(), nil
+}
+
+// Returns the commit the remote would be cloned at without cloning
+// it, a pinned tag is looked up and a pinned commit is returned as
+// is.
+func RemoteGitCommit(gr GitRemote) (string, error) {
+	auth, err := gr.Auth()
+	if err != nil {
+		return "", err
+	}
+
+	// The refs are read from the upload pack session, the peeled
+	// annotated tags are left out of the refs a remote lists.
+	endpoint, err := transport.NewEndpoint(gr.URL)
+	if err != nil {
+		return "", err
+	}
+
+	gitClient, err := client.NewClient(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	session, err := gitClient.NewUploadPackSession(endpoint, auth)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	advertised, err := session.AdvertisedReferences()
+	if err != nil {
+		return "", err
+	}
+
+	refs, err := advertised.AllReferences()
+	if err != nil {
+		return "", err
+	}
+
+	hashes := map[plumbing.ReferenceName]plumbing.Hash{}
+	targets := map[plumbing.ReferenceName]plumbing.ReferenceName{}
+	for _, ref := range refs {
+		if ref.Type() == plumbing.SymbolicReference {
+			targets[ref.Name()] = ref.Target()
+		} else {
+			hashes[ref.Name()] = ref.Hash()
+		}
+	}
+
+	name := plumbing.HEAD
+	if gr.Rev != "" {
+		// Peeled annotated tags point to the commit itself.
+		tag := plumbing.NewTagReferenceName(gr.Rev)
+		if hash, ok := advertised.Peeled[tag.String()]; ok {
+			return hash.String(), nil
+		}
+		if hash, ok := hashes[tag]; ok {
+			return hash.String(), nil
+		}
+		return gr.Rev, nil
+	} else if gr.Branch != "" {
+		name = plumbing.NewBranchReferenceName(gr.Branch)
+	} else if target, ok := targets[name]; ok {
+		name = target
+	}
+
+	hash, ok := hashes[name]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("Cannot Find %s at %s", name.Short(), gr.URL))
+	}
+	return hash.String(), nil
+}
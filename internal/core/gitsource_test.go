@@ -0,0 +1,218 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+func TestParseGitRemote(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   GitRemote
+		err    bool
+	}{
+		{"antony-jr@gh/lineage", GitRemote{URL: "https://github.com/antony-jr/lineage"}, false},
+		{"antony-jr@gl/lineage", GitRemote{URL: "https://gitlab.com/antony-jr/lineage"}, false},
+		{"antony-jr@cb/lineage", GitRemote{URL: "https://codeberg.org/antony-jr/lineage"}, false},
+		{"antony-jr@GH/lineage", GitRemote{URL: "https://github.com/antony-jr/lineage"}, false},
+		{"~@gh/lineage", GitRemote{URL: "https://github.com/ham-community/lineage"}, false},
+		{"antony-jr@gh/lineage@v1.0", GitRemote{URL: "https://github.com/antony-jr/lineage", Rev: "v1.0"}, false},
+		{"antony-jr@gh/lineage:lineage-21", GitRemote{URL: "https://github.com/antony-jr/lineage", Branch: "lineage-21"}, false},
+		{"antony-jr@gh/lineage:feature/x@1a2b3c", GitRemote{URL: "https://github.com/antony-jr/lineage", Branch: "feature/x", Rev: "1a2b3c"}, false},
+		{"https://example.com/a/b.git", GitRemote{URL: "https://example.com/a/b.git"}, false},
+		{"https://example.com/a/b.git:dev@v2", GitRemote{URL: "https://example.com/a/b.git", Branch: "dev", Rev: "v2"}, false},
+		{"ssh://git@example.com/a/b.git@v2", GitRemote{URL: "ssh://git@example.com/a/b.git", Rev: "v2"}, false},
+		{"git@github.com:a/b.git", GitRemote{URL: "git@github.com:a/b.git"}, false},
+		{"git@github.com:a/b.git:dev@v2", GitRemote{URL: "git@github.com:a/b.git", Branch: "dev", Rev: "v2"}, false},
+		{"antony-jr@bb/lineage", GitRemote{}, true},
+		{"antony-jr@gh/lineage:-dev", GitRemote{}, true},
+		{"antony-jr@gh/lineage@.v1", GitRemote{}, true},
+		{"not a remote", GitRemote{}, true},
+		{"", GitRemote{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseGitRemote(test.remote)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseGitRemote(%q) = %+v, want an error", test.remote, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseGitRemote(%q) failed (%s)", test.remote, err.Error())
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseGitRemote(%q) = %+v, want %+v", test.remote, got, test.want)
+		}
+	}
+}
+
+func TestGitRemoteAuth(t *testing.T) {
+	t.Setenv(GitTokenEnv, "")
+	t.Setenv(GitUsernameEnv, "")
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	auth, err := GitRemote{URL: "https://github.com/a/b"}.Auth()
+	if err != nil || auth != nil {
+		t.Errorf("Auth() without a token = %v, %v, want no auth", auth, err)
+	}
+
+	t.Setenv(GitTokenEnv, "secret")
+	auth, err = GitRemote{URL: "https://github.com/a/b"}.Auth()
+	basic, ok := auth.(*http.BasicAuth)
+	if err != nil || !ok || basic.Username != "oauth2" || basic.Password != "secret" {
+		t.Errorf("Auth() with a token = %v, %v, want oauth2:secret", auth, err)
+	}
+
+	t.Setenv(GitUsernameEnv, "antony")
+	auth, _ = GitRemote{URL: "https://github.com/a/b"}.Auth()
+	basic, ok = auth.(*http.BasicAuth)
+	if !ok || basic.Username != "antony" {
+		t.Errorf("Auth() with a user name = %v, want antony", auth)
+	}
+
+	// SSH remotes never use the token, only the agent, which is
+	// not there.
+	for _, url := range []string{"git@github.com:a/b.git", "ssh://git@github.com/a/b.git"} {
+		gr := GitRemote{URL: url}
+		if !gr.IsSSH() {
+			t.Errorf("IsSSH(%q) = false", url)
+		}
+		if gr.Host() != "github.com" {
+			t.Errorf("Host(%q) = %q", url, gr.Host())
+		}
+		_, err = gr.Auth()
+		if err == nil {
+			t.Errorf("Auth(%q) without a SSH agent did not fail", url)
+		}
+	}
+}
+
+// Returns a repo with two commits on main, v1 as a lightweight tag
+// of the first and v2 as a annotated tag of the second.
+func newTestRepo(t *testing.T) (string, string, string) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := &object.Signature{Name: "ham", Email: "ham@example.com", When: time.Unix(0, 0)}
+	commit := func(content string) string {
+		err := os.WriteFile(filepath.Join(dir, "ham.yml"), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = worktree.Add("ham.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := worktree.Commit(content, &git.CommitOptions{Author: signature})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash.String()
+	}
+
+	first := commit("first")
+	second := commit("second")
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.CreateTag("v1", plumbing.NewHash(first), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.CreateTag("v2", head.Hash(), &git.CreateTagOptions{Tagger: signature, Message: "v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, first, second
+}
+
+func TestRemoteGitCommit(t *testing.T) {
+	t.Setenv(GitTokenEnv, "")
+	dir, first, second := newTestRepo(t)
+	url := "file://" + dir
+
+	tests := []struct {
+		remote GitRemote
+		want   string
+		err    bool
+	}{
+		// HEAD points to the default branch.
+		{GitRemote{URL: url}, second, false},
+		{GitRemote{URL: url, Branch: "master"}, second, false},
+		{GitRemote{URL: url, Branch: "missing"}, "", true},
+
+		// A lightweight tag points to the commit, a annotated one
+		// to the tag object, which is peeled.
+		{GitRemote{URL: url, Rev: "v1"}, first, false},
+		{GitRemote{URL: url, Rev: "v2"}, second, false},
+
+		// Anything else is taken as a commit.
+		{GitRemote{URL: url, Rev: first[:7]}, first[:7], false},
+		{GitRemote{URL: url, Branch: "master", Rev: first}, first, false},
+	}
+
+	for _, test := range tests {
+		got, err := RemoteGitCommit(test.remote)
+		if test.err {
+			if err == nil {
+				t.Errorf("RemoteGitCommit(%+v) = %s, want an error", test.remote, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("RemoteGitCommit(%+v) failed (%s)", test.remote, err.Error())
+			continue
+		}
+		if got != test.want {
+			t.Errorf("RemoteGitCommit(%+v) = %s, want %s", test.remote, got, test.want)
+		}
+	}
+}
+
+func TestCloneGitRemote(t *testing.T) {
+	t.Setenv(GitTokenEnv, "")
+	dir, first, second := newTestRepo(t)
+	url := "file://" + dir
+
+	tests := []struct {
+		remote GitRemote
+		want   string
+	}{
+		{GitRemote{URL: url}, second},
+		{GitRemote{URL: url, Rev: "v1"}, first},
+		{GitRemote{URL: url, Rev: "v2"}, second},
+		{GitRemote{URL: url, Rev: first[:7]}, first},
+	}
+
+	for _, test := range tests {
+		got, err := CloneGitRemote(test.remote, t.TempDir())
+		if err != nil {
+			t.Errorf("CloneGitRemote(%+v) failed (%s)", test.remote, err.Error())
+			continue
+		}
+		if got != test.want {
+			t.Errorf("CloneGitRemote(%+v) = %s, want %s", test.remote, got, test.want)
+		}
+	}
+}
@@ -6,66 +6,291 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/antony-jr/ham/internal/helpers"
 	"gopkg.in/yaml.v3"
 )
 
-type HAMFile struct {
-	Title     string `yaml:"title"`
-	Version   string `yaml:"version"`
-	SHA256Sum string
-	Args      []struct {
-		ID       string `yaml:"id"`
-		Prompt   string `yaml:"prompt"`
-		Required *bool  `yaml:"required",omitempty`
-		Type     string `yaml:"type"`
-	}
+type HAMBuildStep struct {
+	Title string `yaml:"name"`
+	Cmd   string `yaml:"run"`
+
+	// How much of the build the step is, roughly the minutes it
+	// takes. Learned from previous builds if not given.
+	Weight float64 `yaml:"weight,omitempty"`
+
+	// The step is skipped if the condition is false, see
+	// StepCondition.
+	If string `yaml:"if,omitempty"`
 
-	Build []struct {
-		Title string `yaml:"name"`
-		Cmd   string `yaml:"run"`
+	// Steps share a single bash, so a cd or export of a step is
+	// there for the next. A step with a working directory, env or
+	// shell runs in a subshell and changes nothing for the others.
+	WorkingDirectory string            `yaml:"working_directory,omitempty"`
+	Shell            string            `yaml:"shell,omitempty"`
+	Env              map[string]string `yaml:"env,omitempty"`
+}
+
+// Step shells, bash is the one shared by all steps.
+const (
+	STEP_SHELL_BASH = "bash"
+	STEP_SHELL_SH   = "sh"
+)
+
+var stepEnvNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Returns the condition of the step, nil if it always runs.
+func (step *HAMBuildStep) Condition() (*StepCondition, error) {
+	if len(strings.TrimSpace(step.If)) == 0 {
+		return nil, nil
 	}
+	return ParseStepCondition(step.If)
+}
 
-	PostBuild []string `yaml:"post_build"`
+// Returns true if the step runs in a subshell of it's own.
+func (step *HAMBuildStep) Isolated() bool {
+	return len(step.WorkingDirectory) != 0 || len(step.Env) != 0 || step.Shell == STEP_SHELL_SH
 }
 
-func NewHAMFile(RecipePath string) (HAMFile, error) {
+// How the SHA256 sum of a recipe is computed, the sum names
+// the build server and the build status labels.
+const (
+	// Hash every file in the recipe directory.
+	HASH_MODE_TREE = "tree"
 
-	hf := HAMFile{}
+	// Hash only the ham.yml file, this was the only mode in
+	// older versions of ham.
+	HASH_MODE_YML = "yml"
+)
 
+type HAMFile struct {
+	Title     string         `yaml:"title"`
+	Version   string         `yaml:"version"`
+	HashMode  string         `yaml:"hash"`
+	SHA256Sum string         `yaml:"-"`
+	Extends   *HAMRecipeRef  `yaml:"extends,omitempty"`
+	Include   []HAMRecipeRef `yaml:"include,omitempty"`
+	Sources   []string       `yaml:"sources,omitempty"`
+	Matrix    *HAMMatrix     `yaml:"matrix,omitempty"`
+	Watch     []HAMWatch     `yaml:"watch,omitempty"`
+	Notify    []HAMNotify    `yaml:"notify,omitempty"`
+	Progress  []string       `yaml:"progress,omitempty"`
+
+	Dependencies HAMDependencies `yaml:"dependencies,omitempty"`
+	Container    *HAMContainer   `yaml:"container,omitempty"`
+	Args         []HAMArg        `yaml:"args"`
+	Build        []HAMBuildStep  `yaml:"build"`
+	PostBuild    []string        `yaml:"post_build"`
+	HAMHooks     `yaml:",inline"`
+}
+
+// Returns the path and contents of the ham.yaml or ham.yml
+// file of the recipe.
+func readHAMFileSource(RecipePath string) (string, []byte, error) {
 	fp := fmt.Sprintf("%s%cham.yaml", RecipePath, os.PathSeparator)
 	exists, err := helpers.FileExists(fp)
 	if err != nil {
-		return hf, err
+		return fp, nil, err
 	}
 
 	if !exists {
 		fp = fmt.Sprintf("%s%cham.yml", RecipePath, os.PathSeparator)
 		exists, err = helpers.FileExists(fp)
 		if err != nil {
-			return hf, err
+			return fp, nil, err
 		}
 
 		if !exists {
-			return hf, errors.New("YAML File Not Found")
+			return fp, nil, errors.New("YAML File Not Found")
 		}
 	}
 
 	source, err := ioutil.ReadFile(fp)
+	return fp, source, err
+}
+
+// Computes the SHA256 sum of the recipe itself with the hash
+// mode of the recipe, source is the contents of it's ham.yml.
+func (hf *HAMFile) hashRecipe(RecipePath string, source []byte) error {
+	var err error
+
+	switch hf.HashMode {
+	case "", HASH_MODE_TREE:
+		hf.HashMode = HASH_MODE_TREE
+		hf.SHA256Sum, err = HashRecipeTree(RecipePath)
+		if err != nil {
+			return err
+		}
+	case HASH_MODE_YML, "yaml":
+		hf.HashMode = HASH_MODE_YML
+		hasher := sha256.New()
+		hasher.Write(source)
+		hash := hasher.Sum(nil)
+		hf.SHA256Sum = fmt.Sprintf("%x", hash)
+	default:
+		return errors.New(fmt.Sprintf("Unknown Hash Mode '%s', Use %s or %s.", hf.HashMode, HASH_MODE_TREE, HASH_MODE_YML))
+	}
+
+	return nil
+}
+
+func (hf *HAMFile) check() error {
+	for i := range hf.Args {
+		err := hf.Args[i].Check()
+		if err != nil {
+			return err
+		}
+	}
+
+	if hf.Matrix != nil {
+		err := hf.Matrix.Check()
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range hf.Watch {
+		_, err := hf.Watch[i].Remote()
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid Watch %s (%s)", hf.Watch[i].Git, err.Error()))
+		}
+	}
+
+	for _, step := range hf.Build {
+		err := hf.checkStep(step)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range hf.Notify {
+		err := hf.Notify[i].Check()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := hf.ProgressPatterns()
 	if err != nil {
-		return hf, err
+		return err
+	}
+
+	err = hf.HAMHooks.check()
+	if err != nil {
+		return err
+	}
+
+	err = hf.Dependencies.check()
+	if err != nil {
+		return err
 	}
 
-	hasher := sha256.New()
-	hasher.Write(source)
-	hash := hasher.Sum(nil)
-	hf.SHA256Sum = fmt.Sprintf("%x", hash)
+	if hf.Container != nil {
+		err = hf.Container.check()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = hf.Variants()
+	return err
+}
+
+func (hf *HAMFile) checkStep(step HAMBuildStep) error {
+	if step.Weight < 0 {
+		return errors.New(fmt.Sprintf("Weight of Step '%s' can't be Negative", step.Title))
+	}
+
+	if step.Shell != "" && step.Shell != STEP_SHELL_BASH && step.Shell != STEP_SHELL_SH {
+		return errors.New(fmt.Sprintf("Unknown Shell '%s' for Step '%s', it's bash or sh", step.Shell, step.Title))
+	}
+
+	for name := range step.Env {
+		if !stepEnvNameRegex.MatchString(name) {
+			return errors.New(fmt.Sprintf("Invalid Env '%s' for Step '%s'", name, step.Title))
+		}
+	}
+
+	condition, err := step.Condition()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Step '%s': %s", step.Title, err.Error()))
+	}
+	if condition == nil {
+		return nil
+	}
+
+	// Args and matrix keys are known, so a typo fails now and
+	// not on the build server.
+	for _, ref := range condition.Refs() {
+		scope, name, _ := strings.Cut(ref, ".")
+		switch scope {
+		case "args":
+			if hf.GetArg(name) == nil {
+				return errors.New(fmt.Sprintf("Step '%s': Unknown Argument '%s' in Condition", step.Title, name))
+			}
+		case "matrix":
+			known := false
+			if hf.Matrix != nil {
+				for _, key := range hf.Matrix.Keys {
+					known = known || key == name
+				}
+			}
+			if !known {
+				return errors.New(fmt.Sprintf("Step '%s': Unknown Matrix Key '%s' in Condition", step.Title, name))
+			}
+		}
+	}
+	return nil
+}
+
+func NewHAMFile(RecipePath string) (HAMFile, error) {
+
+	hf := HAMFile{}
+
+	fp, source, err := readHAMFileSource(RecipePath)
+	if err != nil {
+		return hf, err
+	}
 
 	err = yaml.Unmarshal(source, &hf)
 	if err != nil {
 		return hf, err
 	}
 
+	err = hf.hashRecipe(RecipePath, source)
+	if err != nil {
+		return hf, err
+	}
+
+	if hf.Extends != nil || len(hf.Include) != 0 {
+		err = hf.compose(fp)
+	} else {
+		hf.Build, hf.PostBuild, err = hf.expandSteps(hf.Build, hf.PostBuild, nil)
+		if err == nil {
+			hf.HAMHooks, err = hf.expandHooks(hf.HAMHooks, nil)
+		}
+	}
+	if err != nil {
+		return hf, err
+	}
+
+	err = hf.check()
+	if err != nil {
+		return hf, err
+	}
+
 	return hf, nil
 }
+
+// Returns the argument with the given id, nil
+// if the recipe does not ask for it.
+func (hf *HAMFile) GetArg(id string) *HAMArg {
+	for i := range hf.Args {
+		if hf.Args[i].ID == id {
+			return &hf.Args[i]
+		}
+	}
+	return nil
+}
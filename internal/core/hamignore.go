@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+const (
+	// File at the root of a recipe listing files which are
+	// not part of the recipe, same syntax as .gitignore.
+	HAMIgnoreFile = ".hamignore"
+
+	gitIgnoreFile = ".gitignore"
+)
+
+// Decides which files in a recipe directory are part of the
+// recipe.
+type RecipeIgnore struct {
+	matcher gitignore.Matcher
+}
+
+func readIgnorePatterns(path string, domain []string) ([]gitignore.Pattern, error) {
+	patterns := []gitignore.Pattern{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return patterns, nil
+		}
+		return patterns, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+
+	return patterns, scanner.Err()
+}
+
+// Reads every .gitignore in the recipe, and the .hamignore at
+// the root of the recipe which takes priority over them.
+func NewRecipeIgnore(dir string) (*RecipeIgnore, error) {
+	patterns := []gitignore.Pattern{}
+
+	walker := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if info.Name() == ".git" && path != dir {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		domain := []string{}
+		if rel != "." {
+			domain = strings.Split(filepath.ToSlash(rel), "/")
+		}
+
+		found, err := readIgnorePatterns(filepath.Join(path, gitIgnoreFile), domain)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, found...)
+		return nil
+	}
+
+	err := filepath.Walk(dir, walker)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := readIgnorePatterns(filepath.Join(dir, HAMIgnoreFile), nil)
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(patterns, found...)
+
+	return &RecipeIgnore{
+		matcher: gitignore.NewMatcher(patterns),
+	}, nil
+}
+
+// Returns true if the given path relative to the root of the
+// recipe is not part of the recipe. Version control directories
+// are always ignored and the recipe file itself never is.
+func (ri *RecipeIgnore) Ignored(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(rel)
+	if rel == "." || rel == "" {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	if parts[len(parts)-1] == ".git" {
+		return true
+	}
+
+	if rel == "ham.yml" || rel == "ham.yaml" {
+		return false
+	}
+
+	return ri.matcher.Match(parts, isDir)
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Hooks which are not done by then are stopped, so they can't keep
+// the server from being destroyed.
+const DEFAULT_HOOK_TIMEOUT = 10
+
+// Commands run in /ham-build when the build ends, after post_build.
+// on_failure runs when the build failed, on_cancel when it was halted
+// and always runs after those no matter how the build ended.
+type HAMHooks struct {
+	OnFailure []string `yaml:"on_failure,omitempty"`
+	OnCancel  []string `yaml:"on_cancel,omitempty"`
+	Always    []string `yaml:"always,omitempty"`
+
+	// Minutes each hook is given, DEFAULT_HOOK_TIMEOUT if not set.
+	HookTimeout int `yaml:"hook_timeout,omitempty"`
+}
+
+// Returns how long a single hook can run.
+func (hooks *HAMHooks) Timeout() time.Duration {
+	minutes := hooks.HookTimeout
+	if minutes <= 0 {
+		minutes = DEFAULT_HOOK_TIMEOUT
+	}
+	return time.Minute * time.Duration(minutes)
+}
+
+func (hooks *HAMHooks) check() error {
+	if hooks.HookTimeout < 0 {
+		return errors.New(fmt.Sprintf("Hook Timeout can't be Negative (%d)", hooks.HookTimeout))
+	}
+
+	// The hooks run once for the whole build, not for each variant
+	// of the matrix.
+	for _, cmd := range append(append(append([]string{}, hooks.OnFailure...), hooks.OnCancel...), hooks.Always...) {
+		if matrixRefRegex.MatchString(cmd) {
+			return errors.New(fmt.Sprintf("Matrix Values can't be used in Hooks (%s)", cmd))
+		}
+	}
+	return nil
+}
+
+// Expands the template arguments in every hook.
+func (hf *HAMFile) expandHooks(hooks HAMHooks, params map[string]string) (HAMHooks, error) {
+	var err error
+	expanded := HAMHooks{HookTimeout: hooks.HookTimeout}
+
+	expanded.OnFailure, err = hf.expandCommands(hooks.OnFailure, params, "On Failure")
+	if err != nil {
+		return expanded, err
+	}
+	expanded.OnCancel, err = hf.expandCommands(hooks.OnCancel, params, "On Cancel")
+	if err != nil {
+		return expanded, err
+	}
+	expanded.Always, err = hf.expandCommands(hooks.Always, params, "Always")
+	return expanded, err
+}
+
+// Adds the hooks of a recipe composed into this one, they run after
+// the ones before. The timeout of the later recipe wins.
+func (hooks *HAMHooks) append(other HAMHooks) {
+	hooks.OnFailure = append(hooks.OnFailure, other.OnFailure...)
+	hooks.OnCancel = append(hooks.OnCancel, other.OnCancel...)
+	hooks.Always = append(hooks.Always, other.Always...)
+	if other.HookTimeout != 0 {
+		hooks.HookTimeout = other.HookTimeout
+	}
+}
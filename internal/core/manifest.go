@@ -0,0 +1,114 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/antony-jr/ham/internal/helpers"
+)
+
+// Every successful build writes this to it's output, next to what
+// it built.
+const MANIFEST_FILE_NAME = "ham-manifest.json"
+
+// Describes how a build was made, so it can be made again and it's
+// output can be told apart from a build of something else.
+type BuildManifest struct {
+	Recipe    ManifestRecipe     `json:"recipe"`
+	Variant   string             `json:"variant,omitempty"`
+	Matrix    map[string]string  `json:"matrix,omitempty"`
+	Ham       ManifestHam        `json:"ham"`
+	Server    ManifestServer     `json:"server"`
+	Container *ManifestContainer `json:"container,omitempty"`
+
+	// Versions of every package installed where the steps ran, in
+	// the container if the recipe has one.
+	Apt map[string]string `json:"apt"`
+
+	// The output of repo manifest -r for every repo checkout in
+	// /ham-build, by it's directory.
+	RepoManifests map[string]string `json:"repo_manifests,omitempty"`
+
+	// Names of the environmental variables of the build, their
+	// values can be secrets.
+	Env []string `json:"env"`
+
+	Steps []ManifestStep `json:"steps"`
+
+	// SHA256 sums of the files in the output, by their path in it.
+	Outputs map[string]string `json:"outputs"`
+
+	Finished time.Time `json:"finished"`
+}
+
+type ManifestRecipe struct {
+	Title         string `json:"title"`
+	Version       string `json:"version"`
+	SHA256Sum     string `json:"sha256"`
+	TreeSHA256Sum string `json:"tree_sha256,omitempty"`
+	GitCommit     string `json:"git_commit,omitempty"`
+}
+
+type ManifestHam struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+}
+
+// The container the steps ran in, the image as given in the recipe
+// and what it was at the time of the build.
+type ManifestContainer struct {
+	Runtime    string `json:"runtime,omitempty"`
+	Image      string `json:"image,omitempty"`
+	Dockerfile string `json:"dockerfile,omitempty"`
+	ImageID    string `json:"image_id,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+}
+
+type ManifestServer struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Image    string `json:"image,omitempty"`
+	Location string `json:"location,omitempty"`
+	Ubuntu   string `json:"ubuntu,omitempty"`
+}
+
+// A step and the seconds it took, skipped steps took none.
+type ManifestStep struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Seconds int64  `json:"seconds"`
+}
+
+// Returns where the manifest of the variant is at the build server,
+// variants write to their own output.
+func ManifestPath(variant string) string {
+	return filepath.Join("/ham-output", variant, MANIFEST_FILE_NAME)
+}
+
+// Keeps the manifest of a build downloaded by ham get next to the
+// log of the build, returns where it's kept.
+func RecordBuildManifest(serverName string, variant string, source []byte) (string, error) {
+	manifest := BuildManifest{}
+	err := json.Unmarshal(source, &manifest)
+	if err != nil {
+		return "", err
+	}
+
+	finished := manifest.Finished
+	if finished.IsZero() {
+		finished = time.Now()
+	}
+
+	path, err := helpers.BuildManifestPath(serverName, variant, finished)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, source, 0600)
+}
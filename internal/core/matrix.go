@@ -0,0 +1,271 @@
+package core
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ${{ matrix.name }}
+	matrixRefRegex = regexp.MustCompile(`\$\{\{\s*matrix\.([A-Za-z0-9_-]+)\s*\}\}`)
+
+	// Matrix values name variants, their outputs and logs.
+	matrixValueRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// The matrix of a recipe, every combination of the values is
+// built as a variant of the recipe. Keys keep the order they
+// are given in.
+type HAMMatrix struct {
+	Keys   []string
+	Values map[string][]string
+}
+
+func (m *HAMMatrix) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return errors.New("matrix must be a map of lists")
+	}
+
+	m.Keys = []string{}
+	m.Values = map[string][]string{}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key := value.Content[i].Value
+		values := []string{}
+		err := value.Content[i+1].Decode(&values)
+		if err != nil {
+			return errors.New(fmt.Sprintf("matrix.%s must be a list (%s)", key, err.Error()))
+		}
+
+		if _, ok := m.Values[key]; ok {
+			return errors.New(fmt.Sprintf("matrix.%s is given more than once", key))
+		}
+
+		m.Keys = append(m.Keys, key)
+		m.Values[key] = values
+	}
+
+	return nil
+}
+
+func (m HAMMatrix) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{
+		Kind: yaml.MappingNode,
+	}
+
+	for _, key := range m.Keys {
+		values := &yaml.Node{}
+		err := values.Encode(m.Values[key])
+		if err != nil {
+			return nil, err
+		}
+
+		node.Content = append(node.Content, &yaml.Node{
+			Kind:  yaml.ScalarNode,
+			Value: key,
+		}, values)
+	}
+
+	return node, nil
+}
+
+func (m *HAMMatrix) Check() error {
+	for _, key := range m.Keys {
+		if !matrixValueRegex.MatchString(key) {
+			return errors.New(fmt.Sprintf("Invalid Matrix Key '%s'", key))
+		}
+
+		if len(m.Values[key]) == 0 {
+			return errors.New(fmt.Sprintf("matrix.%s has no Values", key))
+		}
+
+		for _, value := range m.Values[key] {
+			if !matrixValueRegex.MatchString(value) {
+				return errors.New(fmt.Sprintf("Invalid Value '%s' for matrix.%s, Only Letters, Numbers, '.', '_' and '-' are Allowed.", value, key))
+			}
+		}
+	}
+	return nil
+}
+
+// A single combination of the matrix values. Every variant has
+// it's own sum, which names it's build state label and logs.
+type HAMVariant struct {
+	Name      string
+	Values    map[string]string
+	SHA256Sum string
+	Build     []HAMBuildStep
+	PostBuild []string
+}
+
+// Returns the environmental variables which are set for the
+// build of the variant.
+func (v *HAMVariant) Env() []string {
+	env := []string{}
+	if len(v.Name) == 0 {
+		return env
+	}
+
+	env = append(env,
+		fmt.Sprintf("HAM_VARIANT=%s", v.Name),
+		fmt.Sprintf("HAM_OUTPUT=/ham-output/%s", v.Name))
+	keys := make([]string, 0, len(v.Values))
+	for key := range v.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		env = append(env, fmt.Sprintf("HAM_MATRIX_%s=%s", ArgEnvName(key), v.Values[key]))
+	}
+	return env
+}
+
+func expandMatrix(value string, values map[string]string) (string, error) {
+	var err error
+	expanded := matrixRefRegex.ReplaceAllStringFunc(value, func(ref string) string {
+		name := matrixRefRegex.FindStringSubmatch(ref)[1]
+		matrixValue, ok := values[name]
+		if !ok {
+			if err == nil {
+				err = errors.New(fmt.Sprintf("Unknown Matrix Key '%s'", name))
+			}
+			return ref
+		}
+		return matrixValue
+	})
+
+	return expanded, err
+}
+
+func (hf *HAMFile) newVariant(values map[string]string, names []string) (HAMVariant, error) {
+	v := HAMVariant{
+		Name:      strings.Join(names, "-"),
+		Values:    values,
+		SHA256Sum: hf.SHA256Sum,
+		Build:     make([]HAMBuildStep, len(hf.Build)),
+		PostBuild: make([]string, len(hf.PostBuild)),
+	}
+
+	if len(names) != 0 {
+		hasher := sha256.New()
+		fmt.Fprintf(hasher, "variant\x00%s\x00", hf.SHA256Sum)
+		for _, key := range hf.Matrix.Keys {
+			fmt.Fprintf(hasher, "%s=%s\x00", key, values[key])
+		}
+		v.SHA256Sum = fmt.Sprintf("%x", hasher.Sum(nil))
+	}
+
+	var err error
+	for i, step := range hf.Build {
+		v.Build[i] = step
+		v.Build[i].Title, err = expandMatrix(step.Title, values)
+		if err != nil {
+			return v, err
+		}
+
+		v.Build[i].Cmd, err = expandMatrix(step.Cmd, values)
+		if err != nil {
+			return v, errors.New(fmt.Sprintf("Step '%s': %s", step.Title, err.Error()))
+		}
+
+		v.Build[i].WorkingDirectory, err = expandMatrix(step.WorkingDirectory, values)
+		if err != nil {
+			return v, errors.New(fmt.Sprintf("Step '%s': %s", step.Title, err.Error()))
+		}
+
+		// The env of the step is the same map for every variant
+		// unless it's copied.
+		if len(step.Env) != 0 {
+			v.Build[i].Env = map[string]string{}
+			for name, value := range step.Env {
+				v.Build[i].Env[name], err = expandMatrix(value, values)
+				if err != nil {
+					return v, errors.New(fmt.Sprintf("Step '%s': %s", step.Title, err.Error()))
+				}
+			}
+		}
+	}
+
+	for i, cmd := range hf.PostBuild {
+		v.PostBuild[i], err = expandMatrix(cmd, values)
+		if err != nil {
+			return v, errors.New("Post Build: " + err.Error())
+		}
+	}
+
+	return v, nil
+}
+
+// Returns every variant of the recipe in the order of the matrix,
+// a recipe without a matrix has a single variant with no name and
+// the sum of the recipe.
+func (hf *HAMFile) Variants() ([]HAMVariant, error) {
+	combinations := []map[string]string{{}}
+	names := [][]string{{}}
+
+	if hf.Matrix != nil {
+		for _, key := range hf.Matrix.Keys {
+			nextCombinations := []map[string]string{}
+			nextNames := [][]string{}
+			for i, combination := range combinations {
+				for _, value := range hf.Matrix.Values[key] {
+					next := map[string]string{}
+					for k, v := range combination {
+						next[k] = v
+					}
+					next[key] = value
+
+					nextCombinations = append(nextCombinations, next)
+					nextNames = append(nextNames, append(append([]string{}, names[i]...), value))
+				}
+			}
+			combinations = nextCombinations
+			names = nextNames
+		}
+	}
+
+	variants := []HAMVariant{}
+	seen := map[string]bool{}
+	for i, combination := range combinations {
+		v, err := hf.newVariant(combination, names[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[v.Name] {
+			return nil, errors.New(fmt.Sprintf("Matrix has the Variant '%s' more than once", v.Name))
+		}
+		seen[v.Name] = true
+
+		variants = append(variants, v)
+	}
+
+	return variants, nil
+}
+
+// Returns the variant with the given name.
+func (hf *HAMFile) Variant(name string) (HAMVariant, error) {
+	variants, err := hf.Variants()
+	if err != nil {
+		return HAMVariant{}, err
+	}
+
+	for _, v := range variants {
+		if v.Name == name {
+			return v, nil
+		}
+	}
+
+	return HAMVariant{}, errors.New(fmt.Sprintf("Recipe has no Variant '%s'", name))
+}
+
+// Returns true if the recipe builds more than one variant.
+func (hf *HAMFile) HasMatrix() bool {
+	return hf.Matrix != nil && len(hf.Matrix.Keys) != 0
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func newMatrixHAMFile(t *testing.T, matrix string) *HAMFile {
+	hf := &HAMFile{
+		SHA256Sum: "recipe-sum",
+		Build: []HAMBuildStep{
+			{
+				Title:            "Build ${{ matrix.device }}",
+				Cmd:              "brunch ${{ matrix.device }}-${{matrix.type}}",
+				WorkingDirectory: "out/${{ matrix.device }}",
+				Env:              map[string]string{"TYPE": "${{ matrix.type }}"},
+			},
+		},
+		PostBuild: []string{"upload ${{ matrix.device }}"},
+	}
+
+	if len(matrix) != 0 {
+		hf.Matrix = &HAMMatrix{}
+		err := yaml.Unmarshal([]byte(matrix), hf.Matrix)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return hf
+}
+
+func TestVariants(t *testing.T) {
+	hf := newMatrixHAMFile(t, "device: [enchilada, fajita]\ntype: [user, userdebug]\n")
+	variants, err := hf.Variants()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first key changes the slowest.
+	names := []string{}
+	for _, v := range variants {
+		names = append(names, v.Name)
+	}
+	want := []string{"enchilada-user", "enchilada-userdebug", "fajita-user", "fajita-userdebug"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("Variants() = %v, want %v", names, want)
+	}
+
+	v := variants[1]
+	if !reflect.DeepEqual(v.Values, map[string]string{"device": "enchilada", "type": "userdebug"}) {
+		t.Errorf("Values = %v", v.Values)
+	}
+	step := v.Build[0]
+	if step.Title != "Build enchilada" || step.Cmd != "brunch enchilada-userdebug" || step.WorkingDirectory != "out/enchilada" {
+		t.Errorf("Build[0] = %+v", step)
+	}
+	if step.Env["TYPE"] != "userdebug" {
+		t.Errorf("Build[0].Env = %v", step.Env)
+	}
+	if v.PostBuild[0] != "upload enchilada" {
+		t.Errorf("PostBuild = %v", v.PostBuild)
+	}
+
+	// The env of a step is not shared between variants, and the
+	// recipe keeps it's refs.
+	if variants[0].Build[0].Env["TYPE"] != "user" || hf.Build[0].Env["TYPE"] != "${{ matrix.type }}" {
+		t.Errorf("Env is shared, %v and %v", variants[0].Build[0].Env, hf.Build[0].Env)
+	}
+}
+
+func TestVariantSums(t *testing.T) {
+	hf := newMatrixHAMFile(t, "device: [enchilada, fajita]\ntype: [user, userdebug]\n")
+	first, err := hf.Variants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := hf.Variants()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]string{}
+	for i, v := range first {
+		if v.SHA256Sum != second[i].SHA256Sum {
+			t.Errorf("Sum of %s changed from %s to %s", v.Name, v.SHA256Sum, second[i].SHA256Sum)
+		}
+		if len(v.SHA256Sum) != 64 || v.SHA256Sum == hf.SHA256Sum {
+			t.Errorf("Sum of %s is %q", v.Name, v.SHA256Sum)
+		}
+		if other, ok := seen[v.SHA256Sum]; ok {
+			t.Errorf("%s and %s have the same Sum", v.Name, other)
+		}
+		seen[v.SHA256Sum] = v.Name
+	}
+
+	// The sum depends on the recipe too.
+	hf.SHA256Sum = "other-sum"
+	other, err := hf.Variants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other[0].SHA256Sum == first[0].SHA256Sum {
+		t.Errorf("Sum of %s does not change with the recipe", other[0].Name)
+	}
+
+	// Without a matrix it's the recipe.
+	hf = newMatrixHAMFile(t, "")
+	hf.Build = nil
+	hf.PostBuild = nil
+	single, err := hf.Variants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(single) != 1 || single[0].Name != "" || single[0].SHA256Sum != hf.SHA256Sum {
+		t.Errorf("Variants() without a matrix = %+v", single)
+	}
+}
+
+func TestVariantsDuplicateNames(t *testing.T) {
+	tests := []string{
+		"device: [enchilada, enchilada]\n",
+		"device: [a-b, a]\ntype: [c, b-c]\n",
+	}
+
+	for _, matrix := range tests {
+		hf := newMatrixHAMFile(t, matrix)
+		hf.Build = nil
+		hf.PostBuild = nil
+		_, err := hf.Variants()
+		if err == nil {
+			t.Errorf("Variants() of %q did not fail", matrix)
+		}
+	}
+
+	err := yaml.Unmarshal([]byte("device: [a]\ndevice: [b]\n"), &HAMMatrix{})
+	if err == nil {
+		t.Errorf("A key given twice did not fail")
+	}
+}
+
+func TestVariantsUnknownKey(t *testing.T) {
+	tests := []func(hf *HAMFile){
+		func(hf *HAMFile) { hf.Build[0].Title = "${{ matrix.version }}" },
+		func(hf *HAMFile) { hf.Build[0].Cmd = "brunch ${{ matrix.version }}" },
+		func(hf *HAMFile) { hf.Build[0].WorkingDirectory = "${{ matrix.version }}" },
+		func(hf *HAMFile) { hf.Build[0].Env["TYPE"] = "${{ matrix.version }}" },
+		func(hf *HAMFile) { hf.PostBuild[0] = "upload ${{ matrix.version }}" },
+	}
+
+	for i, change := range tests {
+		hf := newMatrixHAMFile(t, "device: [enchilada]\ntype: [user]\n")
+		change(hf)
+		_, err := hf.Variants()
+		if err == nil {
+			t.Errorf("Variants() with a unknown key in case %d did not fail", i)
+		}
+	}
+}
+
+func TestVariantEnv(t *testing.T) {
+	v := HAMVariant{
+		Name: "enchilada-user",
+		Values: map[string]string{
+			"type":       "user",
+			"device":     "enchilada",
+			"build-tool": "soong",
+		},
+	}
+
+	want := []string{
+		"HAM_VARIANT=enchilada-user",
+		"HAM_OUTPUT=/ham-output/enchilada-user",
+		"HAM_MATRIX_BUILD_TOOL=soong",
+		"HAM_MATRIX_DEVICE=enchilada",
+		"HAM_MATRIX_TYPE=user",
+	}
+	for i := 0; i < 5; i++ {
+		if got := v.Env(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Env() = %v, want %v", got, want)
+		}
+	}
+
+	if env := (&HAMVariant{}).Env(); len(env) != 0 {
+		t.Errorf("Env() without a name = %v", env)
+	}
+}
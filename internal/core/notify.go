@@ -0,0 +1,312 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events of a build sent to the notify sinks of the recipe, all of
+// them come from the build daemon on the build server.
+const (
+	EVENT_SERVER_CREATED = "server_created"
+	EVENT_INIT_DONE      = "init_done"
+	EVENT_STEP_STARTED   = "step_started"
+	EVENT_STEP_FINISHED  = "step_finished"
+	EVENT_FAILED         = "failed"
+	EVENT_SUCCEEDED      = "succeeded"
+	EVENT_CANCELLED      = "cancelled"
+	EVENT_DESTROYED      = "destroyed"
+)
+
+var buildEvents = []string{
+	EVENT_SERVER_CREATED,
+	EVENT_INIT_DONE,
+	EVENT_STEP_STARTED,
+	EVENT_STEP_FINISHED,
+	EVENT_FAILED,
+	EVENT_SUCCEEDED,
+	EVENT_CANCELLED,
+	EVENT_DESTROYED,
+}
+
+// A sink for the events of the build, only one of webhook, telegram
+// or command is given. ${VAR} in any of them is replaced with the
+// environment of the build, like the args of the recipe.
+type HAMNotify struct {
+	// URL which gets the event as a JSON body with POST.
+	Webhook string `yaml:"webhook,omitempty"`
+
+	// URL template which is fetched with GET, {event}, {message},
+	// {recipe}, {server}, {variant} and {step} are replaced.
+	Telegram string `yaml:"telegram,omitempty"`
+
+	// Shell command which gets the event in HAM_EVENT_* variables
+	// and as JSON in stdin.
+	Command string `yaml:"command,omitempty"`
+
+	// Events to send, all of them if not given.
+	Events []string `yaml:"events,omitempty"`
+}
+
+func (n *HAMNotify) Check() error {
+	given := 0
+	for _, sink := range []string{n.Webhook, n.Telegram, n.Command} {
+		if len(sink) != 0 {
+			given++
+		}
+	}
+	if given != 1 {
+		return errors.New("Notify needs one of webhook, telegram or command")
+	}
+
+	for _, sink := range []string{n.Webhook, n.Telegram} {
+		if len(sink) != 0 && !strings.HasPrefix(sink, "https://") && !strings.HasPrefix(sink, "http://") {
+			return errors.New("Notify URL must start with https:// or http://")
+		}
+	}
+
+	for _, event := range n.Events {
+		known := false
+		for _, e := range buildEvents {
+			known = known || e == event
+		}
+		if !known {
+			return errors.New(fmt.Sprintf("Unknown Notify Event '%s', Use one of %s", event, strings.Join(buildEvents, ", ")))
+		}
+	}
+	return nil
+}
+
+func (n *HAMNotify) wants(event string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+	for _, e := range n.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type BuildEvent struct {
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Recipe    string    `json:"recipe"`
+	Version   string    `json:"version"`
+	Sum       string    `json:"sum"`
+	Server    string    `json:"server"`
+	Variant   string    `json:"variant,omitempty"`
+	Step      string    `json:"step,omitempty"`
+	StepIndex int       `json:"step_index,omitempty"`
+	Steps     int       `json:"steps,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// A line for humans, used as the {message} of telegram.
+func (e *BuildEvent) Text() string {
+	text := fmt.Sprintf("%s v%s (%s)", e.Recipe, e.Version, e.Server)
+	if len(e.Variant) != 0 {
+		text += " [" + e.Variant + "]"
+	}
+	text += ": " + strings.ReplaceAll(e.Event, "_", " ")
+	if len(e.Step) != 0 {
+		text += fmt.Sprintf(" %d/%d %s", e.StepIndex, e.Steps, e.Step)
+	}
+	if len(e.Message) != 0 {
+		text += ", " + e.Message
+	}
+	return text
+}
+
+// Sends the events of a build to the notify sinks of the recipe in
+// the order they happened. Sending never blocks the build and a sink
+// that fails is only logged.
+type Notifier struct {
+	sinks  []HAMNotify
+	env    map[string]string
+	base   BuildEvent
+	events chan BuildEvent
+	done   chan bool
+	log    io.Writer
+
+	// Notify and Close can be called from any goroutine.
+	mutex  sync.Mutex
+	closed bool
+}
+
+// env is used for ${VAR} in the sinks, the environment of the process
+// is used for the rest. Dropped events and failed sinks are written
+// to log.
+func NewNotifier(hf *HAMFile, server string, env map[string]string, log io.Writer) *Notifier {
+	if log == nil {
+		log = os.Stdout
+	}
+
+	n := &Notifier{
+		log:   log,
+		sinks: hf.Notify,
+		env:   env,
+		base: BuildEvent{
+			Recipe:  hf.Title,
+			Version: hf.Version,
+			Sum:     hf.SHA256Sum,
+			Server:  server,
+		},
+		events: make(chan BuildEvent, 64),
+		done:   make(chan bool),
+	}
+
+	go n.run()
+	return n
+}
+
+// Sends the event, the details of the build are filled in.
+func (n *Notifier) Notify(e BuildEvent) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.closed || len(n.sinks) == 0 {
+		return
+	}
+
+	e.Time = time.Now().UTC()
+	e.Recipe = n.base.Recipe
+	e.Version = n.base.Version
+	e.Sum = n.base.Sum
+	e.Server = n.base.Server
+
+	// A sink which is down should not hold the build, the
+	// event is dropped when too many are waiting.
+	select {
+	case n.events <- e:
+	default:
+		fmt.Fprintf(n.log, "Dropped %s Notification, too many are Waiting\n", e.Event)
+	}
+}
+
+// Waits for the events sent so far to be delivered, but not for
+// longer than a minute.
+func (n *Notifier) Close() {
+	n.mutex.Lock()
+	if n.closed {
+		n.mutex.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.events)
+	n.mutex.Unlock()
+
+	select {
+	case <-n.done:
+	case <-time.After(time.Minute):
+		fmt.Fprintln(n.log, "Gave up Sending Notifications")
+	}
+}
+
+func (n *Notifier) run() {
+	for e := range n.events {
+		for i := range n.sinks {
+			sink := &n.sinks[i]
+			if !sink.wants(e.Event) {
+				continue
+			}
+
+			err := n.send(sink, e)
+			if err != nil {
+				fmt.Fprintf(n.log, "Cannot Notify %s (%s)\n", e.Event, err.Error())
+			}
+		}
+	}
+	close(n.done)
+}
+
+func (n *Notifier) expand(s string) string {
+	return os.Expand(s, func(name string) string {
+		if value, ok := n.env[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	})
+}
+
+func (n *Notifier) send(sink *HAMNotify, e BuildEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Timeout: time.Second * time.Duration(30),
+	}
+
+	if len(sink.Webhook) != 0 {
+		resp, err := client.Post(n.expand(sink.Webhook), "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return errors.New("Webhook Responded with " + resp.Status)
+		}
+		return nil
+	}
+
+	if len(sink.Telegram) != 0 {
+		placeholders := strings.NewReplacer(
+			"{event}", url.QueryEscape(e.Event),
+			"{message}", url.QueryEscape(e.Text()),
+			"{recipe}", url.QueryEscape(e.Recipe),
+			"{server}", url.QueryEscape(e.Server),
+			"{variant}", url.QueryEscape(e.Variant),
+			"{step}", url.QueryEscape(e.Step),
+		)
+
+		resp, err := client.Get(placeholders.Replace(n.expand(sink.Telegram)))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return errors.New("Telegram Responded with " + resp.Status)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", sink.Command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = os.Environ()
+	for name, value := range n.env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	cmd.Env = append(cmd.Env,
+		"HAM_EVENT="+e.Event,
+		"HAM_EVENT_MESSAGE="+e.Message,
+		"HAM_EVENT_TEXT="+e.Text(),
+		"HAM_EVENT_SERVER="+e.Server,
+		"HAM_EVENT_VARIANT="+e.Variant,
+		"HAM_EVENT_STEP="+e.Step,
+	)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Command Failed (%s) %s", err.Error(), strings.TrimSpace(string(out))))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Progress lines of the AOSP build, which are always looked for.
+// Soong prints "[ 42% 12345/29876] ..." and plain Ninja prints
+// "[12345/29876] ...".
+var DEFAULT_PROGRESS_PATTERNS = []string{
+	`^\[\s*(?P<percent>\d+)% (?P<done>\d+)/(?P<total>\d+)\]`,
+	`^\[(?P<done>\d+)/(?P<total>\d+)\]`,
+}
+
+var progressAnsiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// The progress of the command a step is running, like the number
+// of files compiled so far.
+type StepProgress struct {
+	Percent float64 `json:"percent"`
+	Done    int64   `json:"done,omitempty"`
+	Total   int64   `json:"total,omitempty"`
+}
+
+// Returns the patterns of the recipe followed by the default ones. A
+// pattern needs a percent group, or done and total groups.
+func (hf *HAMFile) ProgressPatterns() ([]*regexp.Regexp, error) {
+	patterns := []*regexp.Regexp{}
+	for _, source := range append(hf.Progress, DEFAULT_PROGRESS_PATTERNS...) {
+		pattern, err := regexp.Compile(source)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid Progress Pattern %s (%s)", source, err.Error()))
+		}
+
+		hasPercent := pattern.SubexpIndex("percent") >= 0
+		hasCount := pattern.SubexpIndex("done") >= 0 && pattern.SubexpIndex("total") >= 0
+		if !hasPercent && !hasCount {
+			return nil, errors.New(fmt.Sprintf("Progress Pattern %s needs a (?P<percent>) group or (?P<done>) and (?P<total>) groups", source))
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// Returns the progress in the line of output, if any of the patterns
+// match it.
+func ParseProgress(patterns []*regexp.Regexp, line string) (StepProgress, bool) {
+	progress := StepProgress{}
+	line = progressAnsiRegex.ReplaceAllString(line, "")
+
+	for _, pattern := range patterns {
+		match := pattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		group := func(name string) string {
+			i := pattern.SubexpIndex(name)
+			if i < 0 {
+				return ""
+			}
+			return match[i]
+		}
+
+		progress.Done, _ = strconv.ParseInt(group("done"), 10, 64)
+		progress.Total, _ = strconv.ParseInt(group("total"), 10, 64)
+
+		percent, err := strconv.ParseFloat(group("percent"), 64)
+		if err != nil {
+			if progress.Total <= 0 {
+				continue
+			}
+			percent = float64(progress.Done) * 100 / float64(progress.Total)
+		}
+		if percent < 0 || percent > 100 {
+			continue
+		}
+
+		progress.Percent = percent
+		return progress, true
+	}
+	return progress, false
+}
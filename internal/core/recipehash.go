@@ -0,0 +1,113 @@
+package core
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A file which is part of a recipe.
+type RecipeFile struct {
+	// Slash separated path relative to the root of the recipe.
+	Rel  string
+	Path string
+	Info os.FileInfo
+}
+
+// Lists every file and directory which is part of the recipe
+// sorted by their relative path, so the listing does not depend
+// on the machine it is done on. Files ignored with .hamignore or
+// .gitignore and version control directories are never part of
+// the recipe.
+func ListRecipeFiles(dir string) ([]RecipeFile, error) {
+	ignore, err := NewRecipeIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []RecipeFile{}
+
+	walker := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		if ignore.Ignored(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		files = append(files, RecipeFile{
+			Rel:  filepath.ToSlash(rel),
+			Path: path,
+			Info: info,
+		})
+		return nil
+	}
+
+	// Walk uses Lstat, so symlinks are listed as is and
+	// never followed.
+	err = filepath.Walk(dir, walker)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Rel < files[j].Rel
+	})
+
+	return files, nil
+}
+
+// Computes a SHA256 hash over every file in the recipe directory,
+// the relative path, the kind of the file and it's contents are
+// hashed.
+func HashRecipeTree(dir string) (string, error) {
+	files, err := ListRecipeFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	for _, rf := range files {
+		if rf.Info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(rf.Path)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(hasher, "link\x00%s\x00%s\x00", rf.Rel, target)
+			continue
+		}
+
+		if !rf.Info.Mode().IsRegular() {
+			continue
+		}
+
+		fmt.Fprintf(hasher, "file\x00%s\x00%d\x00", rf.Rel, rf.Info.Size())
+		file, err := os.Open(rf.Path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(hasher, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
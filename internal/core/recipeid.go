@@ -0,0 +1,129 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// Hetzner label keys used to record which recipe a build
+// server was started from.
+const (
+	LabelRecipeTree    = "ham-recipe-tree"
+	LabelRecipeCommit  = "ham-recipe-commit"
+	LabelRecipeVersion = "ham-recipe-version"
+
+	// Hetzner does not allow label values longer than this.
+	maxLabelValueLen = 63
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Identifies the exact recipe a build was started from, so
+// we never attach to a build server running a different
+// version of the recipe with the same name.
+type RecipeIdentity struct {
+	Version       string `json:"version"`
+	TreeSHA256Sum string `json:"tree_sha256"`
+	GitCommit     string `json:"git_commit"`
+}
+
+func NewRecipeIdentity(dir string, hf *HAMFile) (RecipeIdentity, error) {
+	id := RecipeIdentity{
+		Version: hf.Version,
+	}
+
+	// Even when the recipe is named by it's ham.yml, the
+	// identity always covers every file.
+	if hf.HashMode == HASH_MODE_TREE && !hf.IsComposed() {
+		id.TreeSHA256Sum = hf.SHA256Sum
+	} else {
+		tree, err := HashRecipeTree(dir)
+		if err != nil {
+			return id, err
+		}
+		id.TreeSHA256Sum = tree
+	}
+
+	// Not every recipe is a git repo.
+	repo, err := git.PlainOpen(dir)
+	if err == nil {
+		head, err := repo.Head()
+		if err == nil {
+			id.GitCommit = head.Hash().String()
+		}
+	}
+
+	return id, nil
+}
+
+func labelValue(value string) string {
+	value = invalidLabelChars.ReplaceAllString(value, "_")
+	if len(value) > maxLabelValueLen {
+		value = value[:maxLabelValueLen]
+	}
+	return strings.Trim(value, "._-")
+}
+
+// Returns the identity as Hetzner labels, values are cut to fit
+// the limits of Hetzner so the full hash is not stored.
+func (id RecipeIdentity) Labels() map[string]string {
+	labels := map[string]string{
+		LabelRecipeTree:    labelValue(id.TreeSHA256Sum),
+		LabelRecipeVersion: labelValue(id.Version),
+	}
+
+	if len(id.GitCommit) != 0 {
+		labels[LabelRecipeCommit] = labelValue(id.GitCommit)
+	}
+
+	return labels
+}
+
+// Compares the identity with the labels of a build server and
+// returns a error describing every difference, servers created
+// by older versions of ham have no labels and are reported as
+// unknown.
+func (id RecipeIdentity) CheckLabels(labels map[string]string) error {
+	tree, ok := labels[LabelRecipeTree]
+	if !ok {
+		return errors.New("Build Server has no Recipe Identity (Created by a older HAM?)")
+	}
+
+	problems := []string{}
+	expected := id.Labels()
+
+	if tree != expected[LabelRecipeTree] {
+		problems = append(problems, fmt.Sprintf("Recipe Files: %s (Server) != %s (Local)",
+			shortLabel(tree), shortLabel(expected[LabelRecipeTree])))
+	}
+
+	if labels[LabelRecipeVersion] != expected[LabelRecipeVersion] {
+		problems = append(problems, fmt.Sprintf("Version: %s (Server) != %s (Local)",
+			labels[LabelRecipeVersion], expected[LabelRecipeVersion]))
+	}
+
+	if labels[LabelRecipeCommit] != expected[LabelRecipeCommit] {
+		problems = append(problems, fmt.Sprintf("Git Commit: %s (Server) != %s (Local)",
+			shortLabel(labels[LabelRecipeCommit]), shortLabel(expected[LabelRecipeCommit])))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(problems, "\n"))
+}
+
+func shortLabel(value string) string {
+	if len(value) == 0 {
+		return "None"
+	}
+	if len(value) > 12 {
+		return value[:12]
+	}
+	return value
+}
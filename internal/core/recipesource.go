@@ -0,0 +1,113 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// A recipe given by the user on the command line, either
+// a local directory or a git remote which is cloned into a
+// temporary directory.
+type RecipeSource struct {
+	Dir       string
+	UsedGit   bool
+	GitURL    string
+	GitBranch string
+	GitRev    string
+	GitCommit string
+}
+
+// Returns true if the recipe source string is not a
+// local directory and has to be fetched with git.
+func IsRemoteRecipe(src string) bool {
+	_, err := os.Stat(src)
+	return os.IsNotExist(err)
+}
+
+func NewLocalRecipeSource(dir string) *RecipeSource {
+	return &RecipeSource{
+		Dir: dir,
+	}
+}
+
+// Clones the given git remote string into a new
+// temporary directory, the caller must call Remove
+// when done with the recipe.
+func CloneRecipeSource(src string) (*RecipeSource, error) {
+	remote, err := ParseGitRemote(src)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(os.TempDir(), "*-ham-recipe")
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := CloneGitRemote(remote, dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &RecipeSource{
+		Dir:       dir,
+		UsedGit:   true,
+		GitURL:    remote.URL,
+		GitBranch: remote.Branch,
+		GitRev:    remote.Rev,
+		GitCommit: commit,
+	}, nil
+}
+
+// Returns the git remote this recipe was cloned from.
+func (rs *RecipeSource) Remote() GitRemote {
+	return GitRemote{
+		URL:    rs.GitURL,
+		Branch: rs.GitBranch,
+		Rev:    rs.GitRev,
+	}
+}
+
+// Returns the git remote of the recipe if src is the name of
+// a recipe in the registry, any other location is returned as is.
+func ResolveRecipeLocation(src string, registry string) (string, *RegistryEntry, error) {
+	if !IsRegistryName(src) {
+		return src, nil, nil
+	}
+
+	r, err := FetchRegistry(registry)
+	if err != nil {
+		return src, nil, err
+	}
+
+	entry := r.Find(src)
+	if entry == nil {
+		return src, nil, errors.New(fmt.Sprintf("Recipe '%s' Does not Exist Locally or in the Registry (Try ham search)", src))
+	}
+
+	return entry.Repo, entry, nil
+}
+
+// Opens a recipe from a local directory, a git remote or the
+// registry.
+func OpenRecipeSource(src string) (*RecipeSource, error) {
+	src, _, err := ResolveRecipeLocation(src, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if IsRemoteRecipe(src) {
+		return CloneRecipeSource(src)
+	}
+	return NewLocalRecipeSource(src), nil
+}
+
+// Removes the temporary clone if any, local recipes
+// are never touched.
+func (rs *RecipeSource) Remove() {
+	if rs.UsedGit {
+		_ = os.RemoveAll(rs.Dir)
+	}
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Returns the total size of all regular files in the recipe.
+func RecipeFilesSize(files []RecipeFile) int64 {
+	size := int64(0)
+	for _, rf := range files {
+		if rf.Info.Mode().IsRegular() {
+			size += rf.Info.Size()
+		}
+	}
+	return size
+}
+
+// Writes the given recipe files to w as a tar archive, file modes
+// and symlinks are kept. Everything is owned by root since that
+// is who runs the build.
+func WriteRecipeTar(files []RecipeFile, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	for _, rf := range files {
+		link := ""
+		if rf.Info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(rf.Path)
+			if err != nil {
+				return err
+			}
+			link = target
+		} else if !rf.Info.IsDir() && !rf.Info.Mode().IsRegular() {
+			// Sockets, devices and such are never part
+			// of a recipe.
+			continue
+		}
+
+		header, err := tar.FileInfoHeader(rf.Info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = rf.Rel
+		if rf.Info.IsDir() {
+			header.Name += "/"
+		}
+		header.Uid = 0
+		header.Gid = 0
+		header.Uname = "root"
+		header.Gname = "root"
+		header.Format = tar.FormatPAX
+
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if !rf.Info.Mode().IsRegular() {
+			continue
+		}
+
+		file, err := os.Open(rf.Path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// Lists the recipe files like ListRecipeFiles but also includes
+// the .git directory if any, so the listing is a complete checkout
+// of the recipe which still works with git. The .git directory is
+// never part of the hash so this does not change the identity of
+// the recipe.
+func ListCheckoutFiles(dir string) ([]RecipeFile, error) {
+	files, err := ListRecipeFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	gitDir := filepath.Join(dir, ".git")
+	if _, err := os.Lstat(gitDir); os.IsNotExist(err) {
+		return files, nil
+	}
+
+	walker := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, RecipeFile{
+			Rel:  filepath.ToSlash(rel),
+			Path: path,
+			Info: info,
+		})
+		return nil
+	}
+
+	err = filepath.Walk(gitDir, walker)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Rel < files[j].Rel
+	})
+
+	return files, nil
+}
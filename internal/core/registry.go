@@ -0,0 +1,194 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// The index of the community recipes, served with the website
+	// from website/static/registry.
+	DefaultRegistryURL = "https://antonyjr.in/ham/registry/index.json"
+
+	// Overrides the default registry, can be a URL or a path to
+	// a local index file.
+	RegistryEnv = "HAM_REGISTRY"
+
+	// Version of the index format this HAM understands.
+	RegistryIndexVersion = 1
+)
+
+// A recipe listed in a registry index, Repo is any git recipe
+// location accepted by ham get.
+type RegistryEntry struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Device      string `json:"device"`
+	OS          string `json:"os"`
+	OSVersion   string `json:"os_version"`
+	Maintainer  string `json:"maintainer"`
+	Repo        string `json:"repo"`
+	Description string `json:"description,omitempty"`
+}
+
+// A list of recipes, served as a JSON file from any static host.
+type Registry struct {
+	Version int             `json:"version"`
+	Recipes []RegistryEntry `json:"recipes"`
+}
+
+// Returns the registry location to use, the given location if
+// any, else the one from the environment or the default.
+func RegistryLocation(location string) string {
+	if len(location) != 0 {
+		return location
+	}
+
+	if env := os.Getenv(RegistryEnv); len(env) != 0 {
+		return env
+	}
+
+	return DefaultRegistryURL
+}
+
+func readRegistrySource(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.ReadFile(strings.TrimPrefix(location, "file://"))
+	}
+
+	client := &http.Client{
+		Timeout: time.Second * time.Duration(30),
+	}
+
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("Registry Returned %s", resp.Status))
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// Reads the registry index from a URL or a local file.
+func FetchRegistry(location string) (*Registry, error) {
+	location = RegistryLocation(location)
+
+	source, err := readRegistrySource(location)
+	if err != nil {
+		return nil, errors.New("Cannot Read Registry " + location + " (" + err.Error() + ")")
+	}
+
+	registry := &Registry{}
+	err = json.Unmarshal(source, registry)
+	if err != nil {
+		return nil, errors.New("Cannot Parse Registry " + location + " (" + err.Error() + ")")
+	}
+
+	err = registry.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// Checks that every entry has a unique name and a usable repo.
+func (r *Registry) Check() error {
+	if r.Version != RegistryIndexVersion {
+		return errors.New(fmt.Sprintf("Unsupported Registry Version %d, Please Update HAM.", r.Version))
+	}
+
+	seen := map[string]bool{}
+	for _, entry := range r.Recipes {
+		if len(entry.Name) == 0 {
+			return errors.New("Registry has a Recipe without a Name")
+		}
+
+		if seen[entry.Name] {
+			return errors.New(fmt.Sprintf("Registry has the Recipe '%s' more than once", entry.Name))
+		}
+		seen[entry.Name] = true
+
+		_, err := ParseGitRemote(entry.Repo)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Registry Recipe '%s': %s", entry.Name, err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// Returns the recipe with the exact name, nil if there is none.
+func (r *Registry) Find(name string) *RegistryEntry {
+	for i := range r.Recipes {
+		if r.Recipes[i].Name == name {
+			return &r.Recipes[i]
+		}
+	}
+	return nil
+}
+
+// Returns the recipes whose name, device codename, title or OS
+// contains every word of the query, ignoring case. Recipes made
+// for exactly the device queried come first.
+func (r *Registry) Search(query string) []RegistryEntry {
+	words := strings.Fields(strings.ToLower(query))
+	results := []RegistryEntry{}
+
+	for _, entry := range r.Recipes {
+		haystack := strings.ToLower(strings.Join([]string{
+			entry.Name,
+			entry.Device,
+			entry.Title,
+			entry.OS,
+			entry.OSVersion,
+			entry.Maintainer,
+		}, " "))
+
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(haystack, word) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			results = append(results, entry)
+		}
+	}
+
+	exact := strings.ToLower(strings.TrimSpace(query))
+	sort.SliceStable(results, func(i, j int) bool {
+		iExact := strings.ToLower(results[i].Device) == exact
+		jExact := strings.ToLower(results[j].Device) == exact
+		if iExact != jExact {
+			return iExact
+		}
+		return results[i].Name < results[j].Name
+	})
+
+	return results
+}
+
+// Returns true if the recipe location can only be a registry
+// name, that is it's not a local directory or a git remote.
+func IsRegistryName(src string) bool {
+	if !IsRemoteRecipe(src) {
+		return false
+	}
+
+	_, err := ParseGitRemote(src)
+	return err != nil
+}
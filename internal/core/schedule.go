@@ -0,0 +1,229 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/antony-jr/ham/internal/helpers"
+)
+
+// A recipe built again and again by ham daemon, whenever the cron
+// expression matches and the recipe changed since the last
+// successful build.
+type Schedule struct {
+	ID       int    `json:"id"`
+	Recipe   string `json:"recipe"`
+	Cron     string `json:"cron"`
+	Answers  string `json:"answers,omitempty"`
+	Registry string `json:"registry,omitempty"`
+	Parallel bool   `json:"parallel,omitempty"`
+
+	// Runs ham get --if-changed every time instead of
+	// skipping when the recipe did not change.
+	IfChanged bool `json:"if_changed,omitempty"`
+
+	// The revision of the recipe which was last built
+	// successfully, see Revision.
+	LastRevision string    `json:"last_revision,omitempty"`
+	LastRun      time.Time `json:"last_run,omitempty"`
+	LastStatus   string    `json:"last_status,omitempty"`
+}
+
+// A single run of a schedule, kept in the schedule log.
+type ScheduleRun struct {
+	Schedule int       `json:"schedule"`
+	Recipe   string    `json:"recipe"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Revision string    `json:"revision,omitempty"`
+
+	// skipped, successful or failed
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+
+	// Path of the output of ham get for the run.
+	Output string `json:"output,omitempty"`
+}
+
+func GetSchedules() ([]Schedule, error) {
+	schedules := []Schedule{}
+	path, err := helpers.SchedulesFilePath()
+	if err != nil {
+		return schedules, err
+	}
+
+	source, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return schedules, nil
+	} else if err != nil {
+		return schedules, err
+	}
+
+	err = json.Unmarshal(source, &schedules)
+	if err != nil {
+		return schedules, errors.New("Cannot Parse Schedules File (" + err.Error() + ")")
+	}
+
+	return schedules, nil
+}
+
+func WriteSchedules(schedules []Schedule) error {
+	source, err := json.MarshalIndent(schedules, "", "   ")
+	if err != nil {
+		return err
+	}
+
+	path, err := helpers.SchedulesFilePath()
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, source, 0600)
+	if err != nil {
+		return errors.New("Cannot Write Schedules File")
+	}
+	return nil
+}
+
+// Adds the schedule with a new id, which is returned.
+func AddSchedule(schedule Schedule) (int, error) {
+	_, err := ParseCron(schedule.Cron)
+	if err != nil {
+		return 0, err
+	}
+
+	schedules, err := GetSchedules()
+	if err != nil {
+		return 0, err
+	}
+
+	schedule.ID = 1
+	for _, s := range schedules {
+		if s.ID >= schedule.ID {
+			schedule.ID = s.ID + 1
+		}
+	}
+
+	schedules = append(schedules, schedule)
+	return schedule.ID, WriteSchedules(schedules)
+}
+
+func RemoveSchedule(id int) error {
+	schedules, err := GetSchedules()
+	if err != nil {
+		return err
+	}
+
+	kept := []Schedule{}
+	for _, s := range schedules {
+		if s.ID != id {
+			kept = append(kept, s)
+		}
+	}
+
+	if len(kept) == len(schedules) {
+		return errors.New(fmt.Sprintf("No Schedule with ID %d", id))
+	}
+	return WriteSchedules(kept)
+}
+
+// Writes the state of the last run of the schedule, the schedule
+// is read again since it might have changed while it was run.
+func UpdateScheduleRun(run ScheduleRun) error {
+	schedules, err := GetSchedules()
+	if err != nil {
+		return err
+	}
+
+	for i := range schedules {
+		if schedules[i].ID != run.Schedule {
+			continue
+		}
+
+		schedules[i].LastRun = run.Started
+		schedules[i].LastStatus = run.Status
+		if run.Status == "successful" {
+			schedules[i].LastRevision = run.Revision
+		}
+		return WriteSchedules(schedules)
+	}
+
+	// Removed while it was run.
+	return nil
+}
+
+// Returns what the recipe of the schedule is at right now, the
+// commit for git recipes and the sum of the recipe for local ones.
+// Nothing is cloned for git recipes.
+func (s *Schedule) Revision() (string, error) {
+	src, _, err := ResolveRecipeLocation(s.Recipe, s.Registry)
+	if err != nil {
+		return "", err
+	}
+
+	if IsRemoteRecipe(src) {
+		gr, err := ParseGitRemote(src)
+		if err != nil {
+			return "", err
+		}
+		return RemoteGitCommit(gr)
+	}
+
+	hf, err := NewHAMFile(src)
+	if err != nil {
+		return "", err
+	}
+	return "sum:" + hf.SHA256Sum, nil
+}
+
+func AppendScheduleLog(run ScheduleRun) error {
+	path, err := helpers.ScheduleLogPath()
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.New("Cannot Write Schedule Log")
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Returns the logged runs, oldest first.
+func ReadScheduleLog() ([]ScheduleRun, error) {
+	runs := []ScheduleRun{}
+	path, err := helpers.ScheduleLogPath()
+	if err != nil {
+		return runs, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return runs, nil
+	} else if err != nil {
+		return runs, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		run := ScheduleRun{}
+		if json.Unmarshal(scanner.Bytes(), &run) == nil {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, scanner.Err()
+}
@@ -6,6 +6,7 @@ const (
 	VARIABLE_TYPE_VALUE VariableType = iota
 	VARIABLE_TYPE_FILE_PATH
 	VARIABLE_TYPE_SECRET
+	VARIABLE_TYPE_DIR_PATH
 )
 
 type Variable struct {
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/antony-jr/ham/internal/helpers"
+)
+
+// A git remote the build uses, like a manifest or a device tree,
+// which is checked for new commits by ham get --if-changed.
+type HAMWatch struct {
+	Git    string `yaml:"git"`
+	Branch string `yaml:"branch,omitempty"`
+}
+
+// Returns the remote to watch, the branch given separately wins
+// over one given in the location.
+func (w *HAMWatch) Remote() (GitRemote, error) {
+	remote, err := ParseGitRemote(w.Git)
+	if err != nil {
+		return remote, err
+	}
+
+	if len(w.Branch) != 0 {
+		if !gitRefRegex.MatchString(w.Branch) {
+			return remote, errors.New("Invalid Branch " + w.Branch)
+		}
+		remote.Branch = w.Branch
+	}
+	return remote, nil
+}
+
+// The name the head of the watch is recorded with.
+func (w *HAMWatch) Key() string {
+	if len(w.Branch) != 0 {
+		return w.Git + "#" + w.Branch
+	}
+	return w.Git
+}
+
+func hasWatch(watch []HAMWatch, w HAMWatch) bool {
+	for _, other := range watch {
+		if other.Key() == w.Key() {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the current commit of every watched remote, nothing is
+// cloned.
+func (hf *HAMFile) WatchedHeads() (map[string]string, error) {
+	heads := map[string]string{}
+	for i := range hf.Watch {
+		remote, err := hf.Watch[i].Remote()
+		if err != nil {
+			return nil, err
+		}
+
+		commit, err := RemoteGitCommit(remote)
+		if err != nil {
+			return nil, errors.New("Cannot Check " + hf.Watch[i].Key() + " (" + err.Error() + ")")
+		}
+		heads[hf.Watch[i].Key()] = commit
+	}
+	return heads, nil
+}
+
+// The heads of the watched remotes a build was started with.
+type WatchRecord struct {
+	Heads map[string]string `json:"heads"`
+	Built time.Time         `json:"built"`
+}
+
+func getWatchRecords() (map[string]WatchRecord, error) {
+	records := map[string]WatchRecord{}
+	path, err := helpers.WatchFilePath()
+	if err != nil {
+		return records, err
+	}
+
+	source, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	} else if err != nil {
+		return records, err
+	}
+
+	err = json.Unmarshal(source, &records)
+	return records, err
+}
+
+// Returns the heads recorded for the last successful build of the
+// build server, nil if there is none.
+func GetWatchRecord(serverName string) (*WatchRecord, error) {
+	records, err := getWatchRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	record, ok := records[serverName]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// Records the heads of the watched remotes for a successful build
+// of the build server.
+func RecordWatchedHeads(serverName string, heads map[string]string) error {
+	records, err := getWatchRecords()
+	if err != nil {
+		return err
+	}
+
+	records[serverName] = WatchRecord{
+		Heads: heads,
+		Built: time.Now(),
+	}
+
+	source, err := json.MarshalIndent(records, "", "   ")
+	if err != nil {
+		return err
+	}
+
+	path, err := helpers.WatchFilePath()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, source, 0600)
+}
+
+// Returns the label of the ham-ssh-key the build server keeps the
+// heads of it's last successful build in, so they are known even
+// when no ham get saw the build finish.
+func WatchHeadsLabel(serverName string) string {
+	return serverName + "-heads"
+}
+
+// Returns a sum of the heads which fits in a label, a label can't
+// hold every commit.
+func WatchHeadsSum(heads map[string]string) string {
+	keys := make([]string, 0, len(heads))
+	for key := range heads {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hasher := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hasher, "%s=%s\n", key, heads[key])
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil))[:40]
+}
+
+// Returns true if the heads are the same as the recorded ones.
+func (r *WatchRecord) Same(heads map[string]string) bool {
+	if len(r.Heads) != len(heads) {
+		return false
+	}
+
+	for key, commit := range heads {
+		if r.Heads[key] != commit {
+			return false
+		}
+	}
+	return true
+}
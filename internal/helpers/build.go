@@ -13,6 +13,10 @@ import (
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
+// Starts a line in the log of a build which tells the step that
+// starts after it, so logs can be split by step.
+const LOG_STEP_MARKER = "::ham-step:: "
+
 // Short SHA256 Sum is used since sha256 sum is simply too
 // long for a server name in Hetzner.
 // It's concatenation of first 7 characters and last 7 characters
@@ -84,7 +88,7 @@ func TryDeleteServer(client *hcloud.Client, serverName string, maxTries int, int
 
 			for {
 				err := DeleteVolume(vclient, serverName)
-				if err == nil {
+				if err == nil || err.Error() == "Volume Not Found" {
 					break
 				}
 				fmt.Println("Volume Destroy Error: ", err.Error())
@@ -97,6 +101,8 @@ func TryDeleteServer(client *hcloud.Client, serverName string, maxTries int, int
 					return errors.New("Cannot Destroy Remote Volume. " + err.Error())
 				}
 			}
+
+			return nil
 		}
 
 		delTries++
@@ -106,8 +112,6 @@ func TryDeleteServer(client *hcloud.Client, serverName string, maxTries int, int
 			return errors.New("Cannot Destroy Remote Server. " + delErr.Error())
 		}
 	}
-
-	return nil
 }
 
 func DeleteServer(sclient *hcloud.ServerClient, serverName string) error {
@@ -242,7 +246,10 @@ func ReadVarsJsonFile(path string) (map[string]string, error) {
 	}
 
 	for key, value := range result {
-		ret[key] = value.(interface{}).(string)
+		ret[key], err = StringifyValue(key, value)
+		if err != nil {
+			return ret, err
+		}
 	}
 
 	return ret, nil
@@ -0,0 +1,111 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// How ham talks to the user, the TUIs are only used when there is
+// a terminal to show them.
+const (
+	CI_OFF = iota
+
+	// Plain lines, one for every event.
+	CI_PLAIN
+
+	// A JSON object on each line, for scripts.
+	CI_JSON
+)
+
+var ciMode = CI_OFF
+
+func SetCIMode(mode int) {
+	ciMode = mode
+}
+
+func CIMode() int {
+	return ciMode
+}
+
+// Returns true if TUIs can't be used.
+func IsCI() bool {
+	return ciMode != CI_OFF
+}
+
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// Returns the mode to use for the given flags, without any flag
+// the plain mode is used when stdin or stdout is not a terminal.
+func DetectCIMode(ci bool, jsonLines bool) int {
+	if jsonLines {
+		return CI_JSON
+	}
+
+	if ci || !IsTerminal(os.Stdin) || !IsTerminal(os.Stdout) {
+		return CI_PLAIN
+	}
+	return CI_OFF
+}
+
+// Returns true if --ci or --json is in the arguments, for what is
+// printed before the flags are parsed.
+func HasCIFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		name, value, _ := strings.Cut(arg, "=")
+		if (name == "--ci" || name == "--json") && value != "false" {
+			return true
+		}
+	}
+	return false
+}
+
+// Prints a event in CI mode. In JSON mode it's a single line with
+// the time, the event, the message and the fields, in plain mode
+// it's the message followed by the fields.
+func CIEvent(event string, message string, fields map[string]interface{}) {
+	if ciMode == CI_JSON {
+		line := map[string]interface{}{}
+		for key, value := range fields {
+			line[key] = value
+		}
+		line["time"] = time.Now().UTC().Format(time.RFC3339)
+		line["event"] = event
+		if len(message) != 0 {
+			line["message"] = message
+		}
+
+		out, err := json.Marshal(line)
+		if err != nil {
+			return
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	if len(message) != 0 {
+		parts = append(parts, message)
+	}
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", key, fields[key]))
+	}
+	fmt.Printf("[%s] %s\n", event, strings.Join(parts, " "))
+}
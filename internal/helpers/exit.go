@@ -0,0 +1,46 @@
+package helpers
+
+// Exit codes other than 1, which is used for every other error.
+const (
+	// ham get --if-changed found nothing to build.
+	EXIT_NO_CHANGES = 3
+
+	// How tracking the remote build ended in ham get, one
+	// for each SSH shell code.
+	EXIT_CANNOT_GET_CLIENT  = 10
+	EXIT_CANNOT_GET_SESSION = 11
+	EXIT_CANNOT_CONNECT     = 12
+	EXIT_MALFORMED_JSON     = 13
+	EXIT_BUILD_FAILED       = 14
+
+	// The build was stopped with ham halt.
+	EXIT_BUILD_CANCELLED = 15
+)
+
+// A error which makes ham exit with the given code, the message is
+// only shown as a fatal error if Fatal is set.
+type ExitError struct {
+	Code    int
+	Message string
+	Fatal   bool
+}
+
+func (e *ExitError) Error() string {
+	return e.Message
+}
+
+func NewExitError(code int, message string) error {
+	return &ExitError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// Wraps err so ham exits with the given code.
+func NewFatalExitError(code int, err error) error {
+	return &ExitError{
+		Code:    code,
+		Message: err.Error(),
+		Fatal:   true,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 )
 
 func FileExists(FilePath string) (bool, error) {
@@ -26,3 +27,104 @@ func ConfigFilePath() (string, error) {
 
 	return fmt.Sprintf("%s%c.ham.json", homedir, os.PathSeparator), nil
 }
+
+func SchedulesFilePath() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%c.ham.schedules.json", homedir, os.PathSeparator), nil
+}
+
+// Scheduled runs are logged here, one JSON object on each line.
+func ScheduleLogPath() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%c.ham.schedules.log", homedir, os.PathSeparator), nil
+}
+
+// The output of every scheduled run is kept in this directory.
+func ScheduleRunsDir() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%c.ham.schedules.runs", homedir, os.PathSeparator), nil
+}
+
+// Heads of the watched remotes at the last successful build of
+// every recipe are kept here.
+func WatchFilePath() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%c.ham.watch.json", homedir, os.PathSeparator), nil
+}
+
+// How long every step of a recipe took in it's previous builds,
+// used to weigh the steps of the next build.
+func DurationsFilePath() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%c.ham.durations.json", homedir, os.PathSeparator), nil
+}
+
+// The logs of builds streamed by ham get are kept here, one for
+// every build server.
+func BuildLogsDir() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%c.ham.logs", homedir, os.PathSeparator), nil
+}
+
+func BuildLogPath(serverName string) (string, error) {
+	dir, err := BuildLogsDir()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%c%s.log", dir, os.PathSeparator, serverName), nil
+}
+
+// The manifest of a successful build is kept next to it's log, one
+// for every build of the server and variant.
+func BuildManifestPath(serverName string, variant string, finished time.Time) (string, error) {
+	dir, err := BuildLogsDir()
+	if err != nil {
+		return "", err
+	}
+
+	name := serverName
+	if len(variant) != 0 {
+		name += "-" + variant
+	}
+	return fmt.Sprintf("%s%c%s-%s.manifest.json", dir, os.PathSeparator, name, finished.UTC().Format("20060102T150405Z")), nil
+}
+
+// Formats a byte count in a human readable way, like 1.5 MiB.
+func HumanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
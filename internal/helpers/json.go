@@ -3,7 +3,9 @@ package helpers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
 )
 
 func DumpJsonFile(obj map[string]string, path string) error {
@@ -30,3 +32,26 @@ func DumpJsonString(obj map[string]string, path string) (string, error) {
 
 	return string(json), nil
 }
+
+// Converts a scalar value decoded from JSON or YAML into
+// a string, lists and objects are not allowed as values.
+func StringifyValue(key string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+
+	return "", errors.New(fmt.Sprintf("Value of '%s' must be a String, Number or Bool.", key))
+}
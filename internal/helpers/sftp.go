@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
@@ -47,3 +48,47 @@ func SFTPCopyFileToRemote(client *sftp.Client, dest string, source string) error
 
 	return nil
 }
+
+func SFTPWriteFileToRemote(client *sftp.Client, dest string, data []byte) error {
+	f, err := client.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
+
+// Copies the directory at source with all of it's contents
+// to dest at the remote, dest is created if it does not exist.
+func SFTPCopyDirToRemote(client *sftp.Client, dest string, source string) error {
+	walker := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		remotePath := filepath.ToSlash(filepath.Join(dest, rel))
+
+		if info.IsDir() {
+			return client.MkdirAll(remotePath)
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		err = SFTPCopyFileToRemote(client, remotePath, path)
+		if err != nil {
+			return err
+		}
+
+		return client.Chmod(remotePath, info.Mode().Perm())
+	}
+
+	return filepath.Walk(source, walker)
+}
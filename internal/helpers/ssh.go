@@ -1,6 +1,10 @@
 package helpers
 
-import "golang.org/x/crypto/ssh"
+import (
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
 
 func GetSSHFingerprint(pubkey string) (string, error) {
 	pubKeyBytes := []byte(pubkey)
@@ -12,3 +16,9 @@ func GetSSHFingerprint(pubkey string) (string, error) {
 
 	return ssh.FingerprintLegacyMD5(pk), nil
 }
+
+// Quotes the string so it is passed as a single argument
+// when used in a remote shell command.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}